@@ -0,0 +1,93 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BadKeyMode decides how the args of the log calls and of Logger.With
+// that are neither key-value pairs nor Attrs are handled, which usually
+// means a key was forgotten.
+type BadKeyMode int32
+
+const (
+	// BadKeySilent writes them with the BadKey key, which is the default.
+	BadKeySilent BadKeyMode = iota
+	// BadKeyWarn also writes a warning to os.Stderr,
+	// once per call site, with the file and line of the call.
+	BadKeyWarn
+	// BadKeyPanic panics instead, e.g. in tests.
+	BadKeyPanic
+)
+
+var (
+	badKeyMode   atomic.Int32
+	badKeyWarned sync.Map // the pcs of the call sites already warned about
+)
+
+// SetBadKeyMode sets how the misused args are handled, BadKeySilent by default.
+func SetBadKeyMode(mode BadKeyMode) {
+	badKeyMode.Store(int32(mode))
+}
+
+// checkBadKeys applies the BadKeyMode to the args of a call.
+// skip is the number of frames of runtime.Callers to skip to reach
+// the caller, as seen from the function calling checkBadKeys.
+func checkBadKeys(args []any, skip int) {
+	mode := BadKeyMode(badKeyMode.Load())
+	if mode == BadKeySilent {
+		return
+	}
+	index := badKeyIndex(args)
+	if index < 0 {
+		return
+	}
+
+	var pcs [1]uintptr
+	// skip this function too
+	runtime.Callers(skip+1, pcs[:])
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	msg := fmt.Sprintf("wslog: arg %d (%v) of the call at %s:%d has no key", index, args[index], frame.File, frame.Line)
+	if mode == BadKeyPanic {
+		panic(msg)
+	}
+	if _, warned := badKeyWarned.LoadOrStore(pcs[0], struct{}{}); !warned {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
+
+// badKeyIndex returns the index of the first arg that argsToAttr
+// writes with the BadKey key, or -1 if there is none.
+func badKeyIndex(args []any) int {
+	for i := 0; i < len(args); {
+		switch args[i].(type) {
+		case string:
+			if i == len(args)-1 {
+				return i
+			}
+			i += 2
+		case Attr:
+			i++
+		default:
+			return i
+		}
+	}
+	return -1
+}
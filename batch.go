@@ -0,0 +1,239 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	httpMinBackoff = 500 * time.Millisecond
+	httpMaxBackoff = 10 * time.Second
+)
+
+// batcher collects the items of a handler, shared among all its clones,
+// and flushes them in the background once there are maxItems items or
+// maxBytes bytes, or wait has elapsed. A zero limit is unlimited,
+// and a non-positive wait only flushes full batches.
+// While a batch is flushing, add blocks until it is done, unless maxQueue
+// is set, in which case the items keep queueing up to maxQueue bytes,
+// and the items exceeding it are dropped.
+// The errors of background flushes are passed to reportError.
+type batcher[T any] struct {
+	name     string // the name of the handler in errors, e.g. loki
+	maxItems int
+	maxBytes int
+	maxQueue int
+	flush    func(items []T) error
+
+	mu     sync.Mutex
+	items  []T
+	size   int
+	closed bool
+
+	batches chan []T
+	kick    chan struct{} // signals a full batch with maxQueue set
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newBatcher[T any](name string, maxItems, maxBytes, maxQueue int, wait time.Duration, flush func(items []T) error) *batcher[T] {
+	b := &batcher[T]{
+		name:     name,
+		maxItems: maxItems,
+		maxBytes: maxBytes,
+		maxQueue: maxQueue,
+		flush:    flush,
+		batches:  make(chan []T),
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run(wait)
+	return b
+}
+
+// add adds item of size bytes to the batch. A full batch is handed to
+// the background goroutine, which blocks add while it is still flushing
+// the previous batch, unless maxQueue is set.
+func (b *batcher[T]) add(item T, size int) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return fmt.Errorf("%s handler is closed", b.name)
+	}
+	if b.maxQueue > 0 && b.size+size > b.maxQueue {
+		b.mu.Unlock()
+		return fmt.Errorf("%s queue is full, dropped an item of %d bytes", b.name, size)
+	}
+	b.items = append(b.items, item)
+	b.size += size
+	var batch []T
+	if b.full() {
+		if b.maxQueue > 0 {
+			// the background goroutine takes the batch once
+			// it is done with the previous one
+			select {
+			case b.kick <- struct{}{}:
+			default:
+			}
+		} else {
+			batch = b.take()
+		}
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		select {
+		case b.batches <- batch:
+		case <-b.stop:
+			return b.flush(batch)
+		}
+	}
+	return nil
+}
+
+// full reports whether the batch is full, the caller must hold b.mu.
+func (b *batcher[T]) full() bool {
+	return b.maxItems > 0 && len(b.items) >= b.maxItems || b.maxBytes > 0 && b.size >= b.maxBytes
+}
+
+// take returns the batched items and starts a new batch,
+// the caller must hold b.mu.
+func (b *batcher[T]) take() []T {
+	batch := b.items
+	b.items = nil
+	b.size = 0
+	return batch
+}
+
+func (b *batcher[T]) run(wait time.Duration) {
+	defer b.wg.Done()
+
+	// a non-positive wait never flushes on time, NewTicker would panic
+	var tick <-chan time.Time
+	if wait > 0 {
+		ticker := time.NewTicker(wait)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		var batch []T
+		select {
+		case batch = <-b.batches:
+		case <-b.kick:
+			b.mu.Lock()
+			if b.full() {
+				batch = b.take()
+			}
+			b.mu.Unlock()
+		case <-tick:
+			b.mu.Lock()
+			batch = b.take()
+			b.mu.Unlock()
+		case <-b.stop:
+			return
+		}
+		for len(batch) > 0 {
+			if err := b.flush(batch); err != nil {
+				reportError(err)
+			}
+			batch = nil
+			if b.maxQueue > 0 {
+				// the batch filled up while flushing
+				b.mu.Lock()
+				if b.full() {
+					batch = b.take()
+				}
+				b.mu.Unlock()
+			}
+		}
+	}
+}
+
+// flushPending flushes the pending items from the calling goroutine.
+func (b *batcher[T]) flushPending() error {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return b.flush(batch)
+}
+
+// close stops the background goroutine and flushes the pending items.
+func (b *batcher[T]) close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.stop)
+	b.wg.Wait()
+	return b.flushPending()
+}
+
+// postWithRetry posts body to url, retrying network errors, 429 and 5xx
+// responses up to retries times with an exponential backoff.
+// It returns the body of the successful response.
+func postWithRetry(client *http.Client, url string, header http.Header, body []byte, retries int) ([]byte, error) {
+	backoff := httpMinBackoff
+	for attempt := 0; ; attempt++ {
+		resp, retry, err := post(client, url, header, body)
+		if err == nil {
+			return resp, nil
+		}
+		if !retry || attempt >= retries {
+			return nil, err
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, httpMaxBackoff)
+	}
+}
+
+// post posts body to url, and returns the body of the response,
+// or reports whether a failure can be retried.
+func post(client *http.Client, url string, header http.Header, body []byte) ([]byte, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		b, err := io.ReadAll(resp.Body)
+		return b, err != nil, err
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.New(resp.Status + ": " + string(bytes.TrimSpace(msg)))
+	return nil, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,290 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BinaryAttrsKey is the key of the attrs of a record encoded by NewBinaryHandler.
+const BinaryAttrsKey = "attrs"
+
+// maxBinaryFrameSize bounds the frames read by BinaryDecoder.
+const maxBinaryFrameSize = 64 << 20
+
+// Codec encodes the values of the records written by NewBinaryHandler,
+// see the msgpack and cbor packages.
+type Codec interface {
+	// AppendValue appends the encoding of the resolved value v to b.
+	// Groups are encoded as maps with string keys, and KindAny values as strings.
+	AppendValue(b []byte, v Value) []byte
+	// DecodeValue decodes the value at the start of b,
+	// and returns the number of bytes read. Maps are decoded as groups.
+	DecodeValue(b []byte) (Value, int, error)
+}
+
+// RecordData is a record decoded by BinaryDecoder.
+type RecordData struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Source  *slog.Source // nil if the record has no source
+	Attrs   []Attr
+}
+
+// NewBinaryHandler creates a Handler that writes each record to w as a
+// map encoded by codec, prefixed with its length as a 4-byte big-endian
+// unsigned integer. The map has the keys TimeKey, LevelKey, MessageKey,
+// SourceKey with the function, file and line if AddSource is set, and
+// BinaryAttrsKey with the attrs, where groups are nested maps.
+// Use BinaryDecoder to read the records back.
+func NewBinaryHandler(w io.Writer, codec Codec, opts *HandlerOptions) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	return &binaryHandler{
+		w:     w,
+		codec: codec,
+		opts:  *opts,
+		mu:    new(sync.Mutex),
+		attrs: make([][]Attr, 1),
+	}
+}
+
+type binaryHandler struct {
+	w     io.Writer
+	codec Codec
+	opts  HandlerOptions
+	mu    *sync.Mutex
+
+	groups []string
+	// resolved attrs of WithAttrs per open group,
+	// the first ones are the top-level attrs
+	attrs [][]Attr
+}
+
+func (h *binaryHandler) clone() *binaryHandler {
+	return &binaryHandler{
+		w:      h.w,
+		codec:  h.codec,
+		opts:   h.opts,
+		mu:     h.mu, // mutex shared among all clones of this handler
+		groups: slices.Clip(h.groups),
+		attrs:  slices.Clip(slices.Clone(h.attrs)),
+	}
+}
+
+func (h *binaryHandler) NeedsSource() bool {
+	return h.opts.AddSource
+}
+
+func (h *binaryHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *binaryHandler) Handle(_ context.Context, record Record) error {
+	// the attrs of the innermost group, then wrap them in the open groups
+	depth := len(h.groups)
+	attrs := slices.Clone(h.attrs[depth])
+	record.Attrs(func(attr Attr) bool {
+		attrs = h.appendAttr(attrs, h.groups, attr)
+		return true
+	})
+	for i := depth; i > 0; i-- {
+		outer := slices.Clone(h.attrs[i-1])
+		if len(attrs) > 0 {
+			outer = append(outer, slog.Attr{Key: h.groups[i-1], Value: slog.GroupValue(attrs...)})
+		}
+		attrs = outer
+	}
+
+	fields := make([]Attr, 0, 5)
+	if !record.Time.IsZero() {
+		fields = append(fields, slog.Time(TimeKey, record.Time.Round(0)))
+	}
+	fields = append(fields,
+		slog.Int64(LevelKey, int64(record.Level)),
+		slog.String(MessageKey, record.Message),
+	)
+	// source, records constructed without a caller have no PC
+	if h.opts.AddSource && record.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{record.PC})
+		f, _ := fs.Next()
+		fields = append(fields, slog.Group(SourceKey,
+			slog.String("function", f.Function),
+			slog.String("file", f.File),
+			slog.Int("line", f.Line),
+		))
+	}
+	if len(attrs) > 0 {
+		fields = append(fields, slog.Attr{Key: BinaryAttrsKey, Value: slog.GroupValue(attrs...)})
+	}
+
+	b := h.codec.AppendValue(make([]byte, 4, 256), slog.GroupValue(fields...))
+	size := len(b) - 4
+	if uint64(size) > math.MaxUint32 {
+		return fmt.Errorf("record of %d bytes is too large", size)
+	}
+	binary.BigEndian.PutUint32(b, uint32(size))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b)
+	return err
+}
+
+func (h *binaryHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	depth := len(cp.groups)
+	resolved := slices.Clip(cp.attrs[depth])
+	for _, attr := range attrs {
+		resolved = cp.appendAttr(resolved, cp.groups, attr)
+	}
+	cp.attrs[depth] = resolved
+	return cp
+}
+
+func (h *binaryHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	cp.attrs = append(cp.attrs, nil)
+	return cp
+}
+
+// appendAttr appends a to attrs, with ReplaceAttr applied and its values resolved.
+func (h *binaryHandler) appendAttr(attrs []Attr, groups []string, a Attr) []Attr {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return attrs
+	}
+
+	if a.Value.Kind() != KindGroup {
+		return append(attrs, a)
+	}
+	// Inline a group with an empty key.
+	if a.Key == "" {
+		for _, ga := range a.Value.Group() {
+			attrs = h.appendAttr(attrs, groups, ga)
+		}
+		return attrs
+	}
+	g2 := append(slices.Clip(groups), a.Key)
+	var group []Attr
+	for _, ga := range a.Value.Group() {
+		group = h.appendAttr(group, g2, ga)
+	}
+	// Output only non-empty groups.
+	if len(group) == 0 {
+		return attrs
+	}
+	return append(attrs, slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)})
+}
+
+// BinaryDecoder reads the records written by NewBinaryHandler.
+type BinaryDecoder struct {
+	r     io.Reader
+	codec Codec
+	buf   []byte
+}
+
+// NewBinaryDecoder creates a BinaryDecoder reading the records
+// encoded by codec from r.
+func NewBinaryDecoder(r io.Reader, codec Codec) *BinaryDecoder {
+	return &BinaryDecoder{r: r, codec: codec}
+}
+
+// Decode reads the next record, it returns io.EOF at the end of the stream.
+func (d *BinaryDecoder) Decode() (RecordData, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(d.r, header[:]); err != nil {
+		return RecordData{}, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxBinaryFrameSize {
+		return RecordData{}, fmt.Errorf("record of %d bytes is too large", size)
+	}
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+	frame := d.buf[:size]
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return RecordData{}, err
+	}
+
+	v, n, err := d.codec.DecodeValue(frame)
+	if err != nil {
+		return RecordData{}, err
+	}
+	if n != len(frame) || v.Kind() != KindGroup {
+		return RecordData{}, errors.New("invalid record")
+	}
+
+	var rd RecordData
+	for _, field := range v.Group() {
+		switch field.Key {
+		case TimeKey:
+			if field.Value.Kind() == KindTime {
+				rd.Time = field.Value.Time()
+			}
+		case LevelKey:
+			if field.Value.Kind() == KindInt64 {
+				rd.Level = Level(field.Value.Int64())
+			}
+		case MessageKey:
+			rd.Message = field.Value.String()
+		case SourceKey:
+			if field.Value.Kind() == KindGroup {
+				rd.Source = new(slog.Source)
+				for _, a := range field.Value.Group() {
+					switch a.Key {
+					case "function":
+						rd.Source.Function = a.Value.String()
+					case "file":
+						rd.Source.File = a.Value.String()
+					case "line":
+						if a.Value.Kind() == KindInt64 {
+							rd.Source.Line = int(a.Value.Int64())
+						}
+					}
+				}
+			}
+		case BinaryAttrsKey:
+			if field.Value.Kind() == KindGroup {
+				rd.Attrs = field.Value.Group()
+			}
+		}
+	}
+	return rd, nil
+}
@@ -0,0 +1,329 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cbor implements the CBOR (RFC 8949) wslog.Codec of wslog.NewBinaryHandler.
+package cbor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+const (
+	// TagTime is the tag of the RFC 3339 time strings.
+	TagTime = 0
+	// TagDuration is the tag of the durations, which are maps
+	// of the seconds at key 1 and of the nanoseconds at key -9.
+	TagDuration = 1002
+)
+
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorString = 3
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+var errShort = errors.New("cbor: unexpected end of data")
+
+// Codec encodes the values as CBOR. CBOR has no distinct signed type,
+// so the non-negative integers are decoded as KindInt64 unless they
+// overflow it, whatever their encoded kind. The times are written as
+// RFC 3339 strings with their offset.
+type Codec struct{}
+
+var _ wslog.Codec = Codec{}
+
+// Decode reads the next record written by wslog.NewBinaryHandler with Codec from r,
+// it returns io.EOF at the end of the stream. It reads no more than the record.
+func Decode(r io.Reader) (wslog.RecordData, error) {
+	return wslog.NewBinaryDecoder(r, Codec{}).Decode()
+}
+
+func (Codec) AppendValue(b []byte, v wslog.Value) []byte {
+	switch v.Kind() {
+	case wslog.KindString:
+		return appendString(b, v.String())
+	case wslog.KindInt64:
+		return appendInt(b, v.Int64())
+	case wslog.KindUint64:
+		return appendHead(b, majorUint, v.Uint64())
+	case wslog.KindFloat64:
+		b = append(b, majorSimple<<5|27)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v.Float64()))
+	case wslog.KindBool:
+		if v.Bool() {
+			return append(b, majorSimple<<5|21)
+		}
+		return append(b, majorSimple<<5|20)
+	case wslog.KindDuration:
+		d := v.Duration()
+		sec, nsec := int64(d/time.Second), int64(d%time.Second)
+		if nsec < 0 {
+			sec, nsec = sec-1, nsec+int64(time.Second)
+		}
+		b = appendHead(b, majorTag, TagDuration)
+		b = appendHead(b, majorMap, 2)
+		b = appendInt(b, 1)
+		b = appendInt(b, sec)
+		b = appendInt(b, -9)
+		return appendInt(b, nsec)
+	case wslog.KindTime:
+		b = appendHead(b, majorTag, TagTime)
+		return appendString(b, v.Time().Format(time.RFC3339Nano))
+	case wslog.KindGroup:
+		attrs := v.Group()
+		b = appendHead(b, majorMap, uint64(len(attrs)))
+		for _, a := range attrs {
+			b = appendString(b, a.Key)
+			b = Codec{}.AppendValue(b, a.Value.Resolve())
+		}
+		return b
+	default:
+		return appendString(b, v.String())
+	}
+}
+
+// appendHead appends the initial bytes of an item of major type with argument n.
+func appendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), n)
+	}
+}
+
+func appendInt(b []byte, i int64) []byte {
+	if i < 0 {
+		return appendHead(b, majorNegInt, uint64(-1-i))
+	}
+	return appendHead(b, majorUint, uint64(i))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendHead(b, majorString, uint64(len(s))), s...)
+}
+
+// DecodeValue decodes the items written by AppendValue, and the byte
+// strings, null, undefined, and the half and single precision floats.
+// Arrays, indefinite lengths, and the unknown tags aren't supported.
+func (Codec) DecodeValue(b []byte) (wslog.Value, int, error) {
+	if len(b) == 0 {
+		return wslog.Value{}, 0, errShort
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	if major == majorSimple {
+		return decodeSimple(b, info)
+	}
+	n, i, err := decodeArgument(b, info)
+	if err != nil {
+		return wslog.Value{}, 0, err
+	}
+
+	switch major {
+	case majorUint:
+		if n > math.MaxInt64 {
+			return slog.Uint64Value(n), i, nil
+		}
+		return slog.Int64Value(int64(n)), i, nil
+	case majorNegInt:
+		if n > math.MaxInt64 {
+			return wslog.Value{}, 0, errors.New("cbor: negative integer overflows int64")
+		}
+		return slog.Int64Value(-1 - int64(n)), i, nil
+	case majorBytes, majorString:
+		if uint64(len(b)-i) < n {
+			return wslog.Value{}, 0, errShort
+		}
+		end := i + int(n)
+		return slog.StringValue(string(b[i:end])), end, nil
+	case majorMap:
+		return decodeMap(b, i, n)
+	case majorTag:
+		if n == TagDuration {
+			d, size, err := decodeDuration(b[i:])
+			return slog.DurationValue(d), i + size, err
+		}
+		v, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		v, err = decodeTag(n, v)
+		return v, i + size, err
+	}
+	return wslog.Value{}, 0, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+// decodeArgument decodes the argument of the initial byte of b with
+// additional info, and returns the offset of the following data.
+func decodeArgument(b []byte, info byte) (uint64, int, error) {
+	if info < 24 {
+		return uint64(info), 1, nil
+	}
+	if info > 27 {
+		return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+	}
+	size := 1 << (info - 24)
+	if len(b) < 1+size {
+		return 0, 0, errShort
+	}
+	var n uint64
+	for _, c := range b[1 : 1+size] {
+		n = n<<8 | uint64(c)
+	}
+	return n, 1 + size, nil
+}
+
+func decodeSimple(b []byte, info byte) (wslog.Value, int, error) {
+	switch info {
+	case 20:
+		return slog.BoolValue(false), 1, nil
+	case 21:
+		return slog.BoolValue(true), 1, nil
+	case 22, 23:
+		return slog.AnyValue(nil), 1, nil
+	case 25, 26, 27:
+		n, i, err := decodeArgument(b, info)
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		var f float64
+		switch info {
+		case 25:
+			f = halfToFloat(uint16(n))
+		case 26:
+			f = float64(math.Float32frombits(uint32(n)))
+		default:
+			f = math.Float64frombits(n)
+		}
+		return slog.Float64Value(f), i, nil
+	}
+	return wslog.Value{}, 0, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// halfToFloat converts the IEEE 754 half precision float h.
+func halfToFloat(h uint16) float64 {
+	exp, frac := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(frac, -24)
+	case 0x1f:
+		f = math.Inf(1)
+		if frac != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(frac+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+func decodeMap(b []byte, i int, n uint64) (wslog.Value, int, error) {
+	// each entry takes at least 2 bytes
+	if uint64(len(b)-i)/2 < n {
+		return wslog.Value{}, 0, errShort
+	}
+	attrs := make([]wslog.Attr, 0, n)
+	for j := uint64(0); j < n; j++ {
+		key, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		if key.Kind() != wslog.KindString {
+			return wslog.Value{}, 0, errors.New("cbor: map key is not a string")
+		}
+		i += size
+		value, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		i += size
+		attrs = append(attrs, slog.Attr{Key: key.String(), Value: value})
+	}
+	return slog.GroupValue(attrs...), i, nil
+}
+
+func decodeTag(tag uint64, v wslog.Value) (wslog.Value, error) {
+	switch tag {
+	case TagTime:
+		if v.Kind() == wslog.KindString {
+			t, err := time.Parse(time.RFC3339Nano, v.String())
+			if err != nil {
+				return wslog.Value{}, fmt.Errorf("cbor: invalid time: %w", err)
+			}
+			return slog.TimeValue(t), nil
+		}
+	}
+	return wslog.Value{}, fmt.Errorf("cbor: unsupported tag %d", tag)
+}
+
+// decodeDuration decodes the map of a duration, whose keys are integers.
+func decodeDuration(b []byte) (time.Duration, int, error) {
+	errInvalid := errors.New("cbor: invalid duration")
+	if len(b) == 0 {
+		return 0, 0, errShort
+	}
+	if b[0]>>5 != majorMap {
+		return 0, 0, errInvalid
+	}
+	n, i, err := decodeArgument(b, b[0]&0x1f)
+	if err != nil {
+		return 0, 0, err
+	}
+	var d time.Duration
+	for j := uint64(0); j < n; j++ {
+		key, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return 0, 0, err
+		}
+		i += size
+		value, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return 0, 0, err
+		}
+		i += size
+		if key.Kind() != wslog.KindInt64 || value.Kind() != wslog.KindInt64 {
+			return 0, 0, errInvalid
+		}
+		switch key.Int64() {
+		case 1:
+			d += time.Duration(value.Int64()) * time.Second
+		case -9:
+			d += time.Duration(value.Int64())
+		}
+	}
+	return d, i, nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+func TestCodec_Value(t *testing.T) {
+	values := []wslog.Value{
+		slog.StringValue(""),
+		slog.StringValue("hello"),
+		slog.StringValue(strings.Repeat("x", 40)),
+		slog.StringValue(strings.Repeat("x", 300)),
+		slog.StringValue(strings.Repeat("x", 70000)),
+		slog.Int64Value(0),
+		slog.Int64Value(-32),
+		slog.Int64Value(-33),
+		slog.Int64Value(math.MaxInt16),
+		slog.Int64Value(math.MinInt32),
+		slog.Int64Value(math.MaxInt64),
+		slog.Int64Value(math.MinInt64),
+		slog.Uint64Value(0),
+		slog.Uint64Value(math.MaxUint32),
+		slog.Uint64Value(math.MaxUint64),
+		slog.Float64Value(3.25),
+		slog.Float64Value(math.Inf(-1)),
+		slog.BoolValue(true),
+		slog.BoolValue(false),
+		slog.DurationValue(-1500 * time.Millisecond),
+		slog.TimeValue(time.Date(2023, 8, 18, 1, 2, 3, 4, time.UTC)),
+		slog.TimeValue(time.Date(1960, 1, 1, 0, 0, 0, 999, time.UTC)),
+		slog.GroupValue(),
+		slog.GroupValue(slog.Int("a", 1), slog.Group("g", slog.String("b", "c"))),
+	}
+	for _, v := range values {
+		b := Codec{}.AppendValue(nil, v)
+		got, n, err := Codec{}.DecodeValue(b)
+		if err != nil {
+			t.Fatalf("DecodeValue(%v) error: %v", v, err)
+		}
+		if n != len(b) {
+			t.Errorf("DecodeValue(%v) read %d bytes, want %d", v, n, len(b))
+		}
+		// the uints are decoded as ints unless they overflow
+		if v.Kind() == wslog.KindUint64 && v.Uint64() <= math.MaxInt64 {
+			v = slog.Int64Value(int64(v.Uint64()))
+		}
+		if got.Kind() != v.Kind() || !got.Equal(v) {
+			t.Errorf("DecodeValue() = %v (%v), want %v (%v)", got, got.Kind(), v, v.Kind())
+		}
+		if _, _, err := (Codec{}).DecodeValue(b[:len(b)-1]); err == nil {
+			t.Errorf("DecodeValue(%v) of truncated data succeeded", v)
+		}
+	}
+}
+
+func TestBinaryHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := wslog.NewBinaryHandler(&buf, Codec{}, &wslog.HandlerOptions{AddSource: true})
+	ts := time.Date(2023, 8, 18, 1, 2, 3, 4, time.UTC)
+	logger := slog.New(h).With("app", "test").WithGroup("req")
+	logger.Warn("hello",
+		"string", "s",
+		"int", -1,
+		"uint", uint64(2),
+		"float", 0.5,
+		"bool", true,
+		"duration", time.Second,
+		"time", ts,
+		"any", []int{1, 2},
+		slog.Group("nested", slog.Group("inner", "key", "value")),
+	)
+	slog.New(h).Info("second")
+
+	rd, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd.Message != "hello" || rd.Level != slog.LevelWarn || rd.Time.IsZero() {
+		t.Errorf("Decode() = %+v", rd)
+	}
+	if rd.Source == nil || !strings.HasSuffix(rd.Source.File, "cbor_test.go") || rd.Source.Line == 0 {
+		t.Errorf("Decode() source = %+v", rd.Source)
+	}
+	want := []wslog.Attr{
+		slog.String("app", "test"),
+		slog.Group("req",
+			slog.String("string", "s"),
+			slog.Int("int", -1),
+			slog.Int64("uint", 2),
+			slog.Float64("float", 0.5),
+			slog.Bool("bool", true),
+			slog.Duration("duration", time.Second),
+			slog.Time("time", ts),
+			slog.String("any", "[1 2]"),
+			slog.Group("nested", slog.Group("inner", "key", "value")),
+		),
+	}
+	if !slog.GroupValue(rd.Attrs...).Equal(slog.GroupValue(want...)) {
+		t.Errorf("Decode() attrs = %v, want %v", rd.Attrs, want)
+	}
+
+	rd, err = Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rd.Message != "second" || rd.Level != slog.LevelInfo || rd.Attrs != nil {
+		t.Errorf("Decode() = %+v", rd)
+	}
+	if _, err := Decode(&buf); !errors.Is(err, io.EOF) {
+		t.Errorf("Decode() error = %v, want io.EOF", err)
+	}
+}
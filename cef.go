@@ -0,0 +1,217 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CEFSignatureIDKey is the key of the top-level attr used as the
+// signature ID of a CEF event, which is the level name if missing.
+const CEFSignatureIDKey = "signature_id"
+
+// CEFDevice identifies the device writing the CEF events.
+type CEFDevice struct {
+	Vendor  string
+	Product string
+	Version string
+}
+
+// NewCEFHandler creates a Handler that writes each record to w as a line of
+// the ArcSight Common Event Format, e.g.
+// `CEF:0|vendor|product|1.0|ERROR|disk full|8|rt=1692320523000 path=/data`.
+// The message is the name of the event, the level maps to its severity,
+// and the time and the attrs are written as extensions, whose group-qualified
+// keys are joined by `_` and stripped of the characters other than letters,
+// digits and `_`. The source isn't written, CEF has no extension for it.
+func NewCEFHandler(w io.Writer, device CEFDevice, opts *HandlerOptions) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	var prefix bytes.Buffer
+	prefix.WriteString("CEF:0|")
+	for _, field := range []string{device.Vendor, device.Product, device.Version} {
+		prefix.WriteString(cefHeaderEscaper.Replace(field))
+		prefix.WriteByte('|')
+	}
+	return &cefHandler{
+		w:      w,
+		opts:   *opts,
+		mu:     new(sync.Mutex),
+		prefix: prefix.Bytes(),
+	}
+}
+
+var (
+	// cefHeaderEscaper escapes the header fields, newlines aren't allowed.
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+	// cefExtensionEscaper escapes the extension values.
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+)
+
+type cefHandler struct {
+	w      io.Writer
+	opts   HandlerOptions
+	mu     *sync.Mutex
+	prefix []byte // the header fields of the device
+
+	groups      []string
+	signatureID string // signature ID of WithAttrs
+	extensions  []byte // extensions of WithAttrs
+}
+
+func (h *cefHandler) clone() *cefHandler {
+	return &cefHandler{
+		w:           h.w,
+		opts:        h.opts,
+		mu:          h.mu, // mutex shared among all clones of this handler
+		prefix:      h.prefix,
+		groups:      slices.Clip(h.groups),
+		signatureID: h.signatureID,
+		extensions:  slices.Clip(h.extensions),
+	}
+}
+
+func (h *cefHandler) NeedsSource() bool {
+	return false
+}
+
+func (h *cefHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *cefHandler) Handle(_ context.Context, record Record) error {
+	signatureID := h.signatureID
+	extensions := slices.Clone(h.extensions)
+	if !record.Time.IsZero() {
+		extensions = appendCEFExtension(extensions, "rt", strconv.FormatInt(record.Time.UnixMilli(), 10))
+	}
+	record.Attrs(func(attr Attr) bool {
+		extensions = h.appendAttr(extensions, &signatureID, h.groups, attr)
+		return true
+	})
+	if signatureID == "" {
+		signatureID = record.Level.String()
+	}
+
+	buf := bytes.NewBuffer(slices.Clip(h.prefix))
+	buf.WriteString(cefHeaderEscaper.Replace(signatureID))
+	buf.WriteByte('|')
+	buf.WriteString(cefHeaderEscaper.Replace(record.Message))
+	buf.WriteByte('|')
+	buf.WriteString(strconv.Itoa(cefSeverity(record.Level)))
+	buf.WriteByte('|')
+	buf.Write(extensions)
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf.Bytes())
+	return err
+}
+
+func (h *cefHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	for _, attr := range attrs {
+		cp.extensions = cp.appendAttr(cp.extensions, &cp.signatureID, cp.groups, attr)
+	}
+	return cp
+}
+
+func (h *cefHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	return cp
+}
+
+// appendAttr appends a as an extension to b, or sets it as the signatureID.
+func (h *cefHandler) appendAttr(b []byte, signatureID *string, groups []string, a Attr) []byte {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return b
+	}
+
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			b = h.appendAttr(b, signatureID, g2, ga)
+		}
+		return b
+	}
+
+	if len(groups) == 0 && a.Key == CEFSignatureIDKey {
+		*signatureID = a.Value.String()
+		return b
+	}
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, "_") + "_" + key
+	}
+	return appendCEFExtension(b, cefKey(key), a.Value.String())
+}
+
+func appendCEFExtension(b []byte, key, value string) []byte {
+	if key == "" {
+		return b
+	}
+	if len(b) > 0 {
+		b = append(b, ' ')
+	}
+	b = append(b, key...)
+	b = append(b, '=')
+	return append(b, cefExtensionEscaper.Replace(value)...)
+}
+
+// cefKey strips the characters of key other than letters, digits and `_`.
+func cefKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return -1
+	}, key)
+}
+
+// cefSeverity maps level to the CEF severity from 0 to 10.
+func cefSeverity(level Level) int {
+	switch {
+	case level < LevelInfo:
+		return 1
+	case level < LevelWarn:
+		return 3
+	case level < LevelError:
+		return 6
+	case level < LevelError+4:
+		return 8
+	default:
+		return 10
+	}
+}
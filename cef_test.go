@@ -0,0 +1,66 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestCEFHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewCEFHandler(&buf, CEFDevice{Vendor: "Security", Product: "threat|manager", Version: "1.0"}, nil)
+	h = h.WithAttrs([]Attr{slog.String(CEFSignatureIDKey, "100")}).WithGroup("http")
+
+	r := slog.NewRecord(time.UnixMilli(1692320523000), LevelError, "worm successfully stopped", 0)
+	r.AddAttrs(slog.Group("req", slog.String("src-ip", "10.0.0.1")), slog.Int("status", 500))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `CEF:0|Security|threat\|manager|1.0|100|worm successfully stopped|8|rt=1692320523000 http_req_srcip=10.0.0.1 http_status=500` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+}
+
+// The escaping examples of the CEF specification.
+func TestCEFHandler_Escaping(t *testing.T) {
+	tests := []struct {
+		msg  string
+		attr Attr
+		want string
+	}{
+		{`detect|something`, slog.String("act", "blocked a | dog"), `CEF:0|v|p|1|INFO|detect\|something|3|act=blocked a | dog` + "\n"},
+		{`detect\something`, slog.String("file", `c:\Program Files\ArcSight`), `CEF:0|v|p|1|INFO|detect\\something|3|file=c:\\Program Files\\ArcSight` + "\n"},
+		{`detect=something`, slog.String("act", "blocked a = dog"), `CEF:0|v|p|1|INFO|detect=something|3|act=blocked a \= dog` + "\n"},
+		{"detect\nsomething", slog.String("msg", "Detected a threat.\n No action needed."), `CEF:0|v|p|1|INFO|detect something|3|msg=Detected a threat.\n No action needed.` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		h := NewCEFHandler(&buf, CEFDevice{Vendor: "v", Product: "p", Version: "1"}, nil)
+		r := slog.NewRecord(time.Time{}, LevelInfo, tt.msg, 0)
+		r.AddAttrs(tt.attr)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Handle() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	netMinBackoff = 100 * time.Millisecond
+	netMaxBackoff = 30 * time.Second
+)
+
+// netConn is the connection of a network handler, shared among all
+// its clones, which is redialed with an exponential backoff after
+// a failed write.
+type netConn struct {
+	name string // the name of the remote in errors, e.g. syslog
+	dial func() (net.Conn, error)
+
+	mu       sync.Mutex
+	conn     net.Conn
+	closed   bool
+	backoff  time.Duration
+	nextDial time.Time
+}
+
+// connect dials the remote if there is no connection yet.
+func (c *netConn) connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return nil
+	}
+	return c.reconnect()
+}
+
+// write calls fn with the connection, if it fails the connection is
+// redialed and fn is retried once.
+func (c *netConn) write(fn func(conn net.Conn) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return fmt.Errorf("%s connection is closed", c.name)
+	}
+	if c.conn != nil {
+		if err := fn(c.conn); err == nil {
+			return nil
+		}
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	if err := c.reconnect(); err != nil {
+		return err
+	}
+	return fn(c.conn)
+}
+
+// reconnect redials the remote, failing fast until the
+// backoff of the previous failed dial has elapsed.
+func (c *netConn) reconnect() error {
+	if now := time.Now(); now.Before(c.nextDial) {
+		return fmt.Errorf("%s reconnect backoff until %s", c.name, c.nextDial.Format(time.RFC3339))
+	}
+	conn, err := c.dial()
+	if err != nil {
+		if c.backoff == 0 {
+			c.backoff = netMinBackoff
+		} else {
+			c.backoff = min(c.backoff*2, netMaxBackoff)
+		}
+		c.nextDial = time.Now().Add(c.backoff)
+		return fmt.Errorf("can't connect to %s: %w", c.name, err)
+	}
+	c.conn = conn
+	c.backoff = 0
+	c.nextDial = time.Time{}
+	return nil
+}
+
+func (c *netConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *netConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// isStreamConn reports whether conn is a stream connection,
+// whose messages need framing.
+func isStreamConn(conn net.Conn) bool {
+	switch conn.(type) {
+	case *net.UDPConn:
+		return false
+	case *net.UnixConn:
+		return conn.LocalAddr().Network() == "unix"
+	}
+	return true
+}
@@ -16,14 +16,22 @@ package wslog
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
 type loggerKey struct{}
 
-// WithContext returns a new context with the provided logger.
+// WithContext returns a new context with the provided logger,
+// and its correlation ID if set by Logger.WithCorrelationID.
 // Use in combination with logger.With(key, value) for great effect.
 func WithContext(ctx context.Context, logger *Logger) context.Context {
+	if id := logger.CorrelationID(); id != "" {
+		ctx = context.WithValue(ctx, correlationIDKey{}, id)
+	}
 	return context.WithValue(ctx, loggerKey{}, logger)
 }
 
@@ -42,3 +50,141 @@ func FromContext(ctx context.Context) *Logger {
 func FromRequest(r *http.Request) *Logger {
 	return FromContext(r.Context())
 }
+
+type contextAttr struct {
+	key     any
+	attrKey string
+}
+
+var (
+	contextAttrsMu sync.Mutex                    // serializes the registrations
+	contextAttrs   atomic.Pointer[[]contextAttr] // replaced on each registration
+)
+
+// RegisterContextAttr registers a context key whose value is added as an
+// attribute with the key attrKey to the records logged with the context,
+// by any Logger including the default one, e.g. the request ID set by a
+// middleware. Registering a key again replaces its attrKey.
+// Nothing is added if the context has no value for the key.
+func RegisterContextAttr(key any, attrKey string) {
+	contextAttrsMu.Lock()
+	defer contextAttrsMu.Unlock()
+
+	var attrs []contextAttr
+	if p := contextAttrs.Load(); p != nil {
+		attrs = slices.Clone(*p)
+	}
+	index := slices.IndexFunc(attrs, func(ca contextAttr) bool { return ca.key == key })
+	if index < 0 {
+		attrs = append(attrs, contextAttr{key: key, attrKey: attrKey})
+	} else {
+		attrs[index].attrKey = attrKey
+	}
+	contextAttrs.Store(&attrs)
+}
+
+// addContextAttrs adds the values of ctx for the keys of RegisterContextAttr to r.
+func addContextAttrs(ctx context.Context, r *Record) {
+	p := contextAttrs.Load()
+	if p == nil || ctx == emptyCtx {
+		return
+	}
+	for _, ca := range *p {
+		if v := ctx.Value(ca.key); v != nil {
+			r.AddAttrs(slog.Any(ca.attrKey, v))
+		}
+	}
+}
+
+type levelKey struct{}
+
+// WithLevel returns a new context with the provided level override,
+// e.g. to trace a single request at LevelDebug. The handlers of this
+// package emit the records at or above the override logged with the
+// context, even if their minimum level is higher. A middleware can force
+// LevelDebug for the requests with an `X-Debug: 1` header:
+//
+//	if r.Header.Get("X-Debug") == "1" {
+//		r = r.WithContext(wslog.WithLevel(r.Context(), wslog.LevelDebug))
+//	}
+func WithLevel(ctx context.Context, level Level) context.Context {
+	return context.WithValue(ctx, levelKey{}, level)
+}
+
+// ContextWithLevel is an alias of WithLevel, returning a new context
+// with the provided level override.
+func ContextWithLevel(ctx context.Context, level Level) context.Context {
+	return WithLevel(ctx, level)
+}
+
+// LevelFromContext retrieves the level override set by WithLevel.
+func LevelFromContext(ctx context.Context) (Level, bool) {
+	level, ok := ctx.Value(levelKey{}).(Level)
+	return level, ok
+}
+
+// LevelEnabled reports whether level is at or above the minimum level
+// of leveler, LevelInfo if nil, or the level override of ctx set by
+// WithLevel. The override only lowers the minimum level, and a nil ctx
+// has none. Custom handlers can call it from Enabled to opt in to the
+// overrides, like the handlers of this package.
+func LevelEnabled(ctx context.Context, level Level, leveler Leveler) bool {
+	return levelEnabled(ctx, level, leveler)
+}
+
+// levelEnabled implements LevelEnabled.
+func levelEnabled(ctx context.Context, level Level, leveler Leveler) bool {
+	minLevel := LevelInfo
+	if leveler != nil {
+		minLevel = leveler.Level()
+	}
+	return level >= minLevel || overrideEnabled(ctx, level)
+}
+
+// overrideEnabled reports whether level is at or above the level override of ctx.
+func overrideEnabled(ctx context.Context, level Level) bool {
+	if ctx == nil {
+		return false
+	}
+	override, ok := LevelFromContext(ctx)
+	return ok && level >= override
+}
+
+// NewContextLevelHandler returns a Handler that enables the records of
+// h at or above the level override of the context set by WithLevel.
+// h must not check the level of the records in Handle, like the slog handlers.
+func NewContextLevelHandler(h Handler) Handler {
+	return &contextLevelHandler{handler: h}
+}
+
+type contextLevelHandler struct {
+	handler Handler
+}
+
+func (h *contextLevelHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	handler, ok := handlerWithOptions(h.handler, mutate)
+	if !ok {
+		return nil, false
+	}
+	return &contextLevelHandler{handler: handler}, true
+}
+
+func (h *contextLevelHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *contextLevelHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level) || overrideEnabled(ctx, level)
+}
+
+func (h *contextLevelHandler) Handle(ctx context.Context, record Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *contextLevelHandler) WithAttrs(attrs []Attr) Handler {
+	return &contextLevelHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *contextLevelHandler) WithGroup(name string) Handler {
+	return &contextLevelHandler{handler: h.handler.WithGroup(name)}
+}
@@ -0,0 +1,56 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationIDKey is the key used for the correlation ID of a Logger,
+// see Logger.WithCorrelationID.
+const CorrelationIDKey = "correlation_id"
+
+// NewCorrelationID generates the IDs of Logger.WithCorrelationID,
+// 16 random hex characters by default.
+// It can be replaced, e.g. for deterministic tests.
+var NewCorrelationID = func() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a Logger that adds an attribute with the key
+// CorrelationIDKey and a new ID of NewCorrelationID to each record.
+// WithContext also stores the ID in the context, see CorrelationIDFromContext.
+func (l *Logger) WithCorrelationID() *Logger {
+	id := NewCorrelationID()
+	c := l.With(CorrelationIDKey, id)
+	c.correlationID = id
+	return c
+}
+
+// CorrelationID returns the ID set by WithCorrelationID.
+func (l *Logger) CorrelationID() string { return l.correlationID }
+
+// CorrelationIDFromContext retrieves the correlation ID of the logger
+// stored by WithContext, or an empty string if there is none.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
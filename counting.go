@@ -0,0 +1,108 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// NewCountingHandler returns a CountingHandler that counts
+// the records of each level and the errors of h.
+func NewCountingHandler(h Handler) *CountingHandler {
+	return &CountingHandler{handler: h, counters: new(counters)}
+}
+
+// CountingHandler is a Handler that counts the records passed to another
+// Handler, e.g. to alert on error-log rates. The handlers derived by
+// WithAttrs and WithGroup share the counters of their parent.
+type CountingHandler struct {
+	handler  Handler
+	counters *counters
+}
+
+type counters struct {
+	levels sync.Map // map[Level]*atomic.Uint64
+	errors atomic.Uint64
+}
+
+func (c *counters) level(level Level) *atomic.Uint64 {
+	if v, ok := c.levels.Load(level); ok {
+		return v.(*atomic.Uint64)
+	}
+	v, _ := c.levels.LoadOrStore(level, new(atomic.Uint64))
+	return v.(*atomic.Uint64)
+}
+
+// Counts returns the number of records handled for each level.
+func (h *CountingHandler) Counts() map[Level]uint64 {
+	counts := make(map[Level]uint64)
+	h.counters.levels.Range(func(key, value any) bool {
+		counts[key.(Level)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return counts
+}
+
+// Errors returns the number of errors returned by the wrapped handler.
+func (h *CountingHandler) Errors() uint64 {
+	return h.counters.errors.Load()
+}
+
+// Collect calls fn with the name and count of each level, and with
+// the name `errors` and the number of errors, in a form that can be
+// exported directly as metric labels, e.g. by a prometheus.Collector.
+// wslog doesn't provide one to avoid depending on Prometheus.
+func (h *CountingHandler) Collect(fn func(name string, count uint64)) {
+	for level, count := range h.Counts() {
+		fn(level.String(), count)
+	}
+	fn("errors", h.Errors())
+}
+
+// Reset sets all counters to zero.
+func (h *CountingHandler) Reset() {
+	h.counters.levels.Range(func(_, value any) bool {
+		value.(*atomic.Uint64).Store(0)
+		return true
+	})
+	h.counters.errors.Store(0)
+}
+
+func (h *CountingHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *CountingHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *CountingHandler) Handle(ctx context.Context, record Record) error {
+	h.counters.level(record.Level).Add(1)
+	err := h.handler.Handle(ctx, record)
+	if err != nil {
+		h.counters.errors.Add(1)
+	}
+	return err
+}
+
+func (h *CountingHandler) WithAttrs(attrs []Attr) Handler {
+	return &CountingHandler{handler: h.handler.WithAttrs(attrs), counters: h.counters}
+}
+
+func (h *CountingHandler) WithGroup(name string) Handler {
+	return &CountingHandler{handler: h.handler.WithGroup(name), counters: h.counters}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCountingHandler(t *testing.T) {
+	h := NewCountingHandler(NewLogfmtHandler(io.Discard, &HandlerOptions{Level: LevelDebug}))
+	l := NewLogger(h)
+	derived := l.With("a", 1).WithGroup("g")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Info("info")
+			derived.Info("info")
+			derived.Error("error")
+		}()
+	}
+	wg.Wait()
+	l.Debug("debug")
+
+	want := map[Level]uint64{LevelDebug: 1, LevelInfo: 8, LevelError: 4}
+	if got := h.Counts(); !maps.Equal(got, want) {
+		t.Errorf("Counts() = %v, want %v", got, want)
+	}
+	if got := h.Errors(); got != 0 {
+		t.Errorf("Errors() = %d, want 0", got)
+	}
+
+	collected := make(map[string]uint64)
+	h.Collect(func(name string, count uint64) { collected[name] = count })
+	if want := map[string]uint64{"DEBUG": 1, "INFO": 8, "ERROR": 4, "errors": 0}; !maps.Equal(collected, want) {
+		t.Errorf("Collect() = %v, want %v", collected, want)
+	}
+
+	h.Reset()
+	if want := map[Level]uint64{LevelDebug: 0, LevelInfo: 0, LevelError: 0}; !maps.Equal(h.Counts(), want) {
+		t.Errorf("Counts() after Reset() = %v, want %v", h.Counts(), want)
+	}
+}
+
+func TestCountingHandler_Errors(t *testing.T) {
+	h := NewCountingHandler(errHandler{slog.NewTextHandler(io.Discard, nil)})
+	record := slog.NewRecord(time.Time{}, LevelWarn, "msg", 0)
+	for i := 0; i < 3; i++ {
+		if err := h.Handle(context.Background(), record); err != io.ErrShortWrite {
+			t.Fatalf("Handle() error = %v, want %v", err, io.ErrShortWrite)
+		}
+	}
+	// the derived handler wraps the slog handler, which doesn't fail
+	if err := h.WithAttrs([]Attr{slog.Int("a", 1)}).Handle(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed records are counted too
+	if got := h.Counts()[LevelWarn]; got != 4 {
+		t.Errorf("Counts()[LevelWarn] = %d, want 4", got)
+	}
+	if got := h.Errors(); got != 3 {
+		t.Errorf("Errors() = %d, want 3", got)
+	}
+}
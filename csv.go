@@ -0,0 +1,194 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewCSVHandler creates a Handler that writes a header row of columns to w,
+// followed by one RFC 4180 row per record.
+// A column is either one of the built-in keys TimeKey, LevelKey, MessageKey
+// and SourceKey, or the group-qualified key of an attr, e.g. `http.method`.
+// The cells of the keys missing from a record are left blank,
+// and the attrs whose key isn't one of the columns are dropped.
+// If a key occurs more than once, the last value is written.
+func NewCSVHandler(w io.Writer, columns []string, opts *HandlerOptions) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+	return &csvHandler{
+		out:     &csvOutput{w: w},
+		opts:    *opts,
+		columns: slices.Clone(columns),
+		index:   index,
+		cells:   make([]string, len(columns)),
+	}
+}
+
+// csvOutput is the writer shared among all clones of a csv handler,
+// which writes the header row before the first record.
+type csvOutput struct {
+	mu     sync.Mutex
+	w      io.Writer
+	header bool
+}
+
+type csvHandler struct {
+	out     *csvOutput
+	opts    HandlerOptions
+	columns []string
+	index   map[string]int
+
+	groups []string
+	cells  []string // cells of WithAttrs
+}
+
+func (h *csvHandler) clone() *csvHandler {
+	return &csvHandler{
+		out:     h.out,
+		opts:    h.opts,
+		columns: h.columns,
+		index:   h.index,
+		groups:  slices.Clip(h.groups),
+		cells:   slices.Clone(h.cells),
+	}
+}
+
+func (h *csvHandler) NeedsSource() bool {
+	_, ok := h.index[SourceKey]
+	return h.opts.AddSource && ok
+}
+
+func (h *csvHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *csvHandler) Handle(_ context.Context, record Record) error {
+	cells := slices.Clone(h.cells)
+	if !record.Time.IsZero() {
+		h.setCell(cells, nil, slog.Time(TimeKey, record.Time.Round(0)))
+	}
+	h.setCell(cells, nil, slog.Any(LevelKey, record.Level))
+	h.setCell(cells, nil, slog.String(MessageKey, record.Message))
+	// source, records constructed without a caller have no PC
+	if h.NeedsSource() && record.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{record.PC})
+		f, _ := fs.Next()
+		h.setCell(cells, nil, slog.Any(SourceKey, &slog.Source{
+			Function: f.Function,
+			File:     f.File,
+			Line:     f.Line,
+		}))
+	}
+	record.Attrs(func(attr Attr) bool {
+		h.setCell(cells, h.groups, attr)
+		return true
+	})
+
+	h.out.mu.Lock()
+	defer h.out.mu.Unlock()
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.UseCRLF = true
+	if !h.out.header {
+		_ = cw.Write(h.columns)
+	}
+	_ = cw.Write(cells)
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if _, err := h.out.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	h.out.header = true
+	return nil
+}
+
+func (h *csvHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	for _, attr := range attrs {
+		cp.setCell(cp.cells, cp.groups, attr)
+	}
+	return cp
+}
+
+func (h *csvHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	return cp
+}
+
+// setCell sets the cell of the column of a, if there is one.
+func (h *csvHandler) setCell(cells []string, groups []string, a Attr) {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return
+	}
+
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.setCell(cells, g2, ga)
+		}
+		return
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	i, ok := h.index[key]
+	if !ok {
+		return
+	}
+	switch v := a.Value; v.Kind() {
+	case KindTime:
+		cells[i] = v.Time().Format(time.RFC3339Nano)
+	case KindAny:
+		if src, ok := v.Any().(*slog.Source); ok {
+			cells[i] = SourceFull.Format(src)
+			break
+		}
+		cells[i] = v.String()
+	default:
+		cells[i] = v.String()
+	}
+}
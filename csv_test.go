@@ -0,0 +1,65 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCSVHandler(t *testing.T) {
+	var buf bytes.Buffer
+	columns := []string{LevelKey, MessageKey, "user", "http.status"}
+	l := NewLogger(NewCSVHandler(&buf, columns, nil))
+	l.Info("hello, \"world\"", "user", "a\nb", "dropped", 1)
+	l.With("user", "c").WithGroup("http").Warn("grouped", "status", 200)
+
+	want := "level,msg,user,http.status\r\n" +
+		"INFO,\"hello, \"\"world\"\"\",\"a\r\nb\",\r\n" +
+		"WARN,grouped,c,200\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+}
+
+func TestCSVHandler_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewCSVHandler(&buf, []string{MessageKey, "i"}, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l.With("i", i).Info(strings.Repeat("x", 100))
+		}(i)
+	}
+	wg.Wait()
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 11 {
+		t.Fatalf("records = %d, want 11", len(records))
+	}
+	if want := []string{MessageKey, "i"}; !reflect.DeepEqual(records[0], want) {
+		t.Errorf("header = %v, want %v", records[0], want)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// JSONLineError is returned by JSONDecoder for a malformed line,
+// the following lines can still be decoded.
+type JSONLineError struct {
+	Line int // the line number, starting at 1
+	Err  error
+}
+
+func (e *JSONLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *JSONLineError) Unwrap() error {
+	return e.Err
+}
+
+// JSONDecoder reads back the records written as JSON lines by the JSON handler.
+type JSONDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+// NewJSONDecoder creates a JSONDecoder reading the records from r.
+func NewJSONDecoder(r io.Reader) *JSONDecoder {
+	return &JSONDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next record, it returns io.EOF at the end of the stream.
+// The top-level TimeKey, LevelKey and MessageKey are the time, level and
+// message of the record, and the other keys are its attrs, in their order.
+// Objects are decoded as groups, integers as int64, the other numbers as
+// float64, and arrays as []any. The source is kept as a group attr.
+// A malformed line is skipped and returned as a *JSONLineError,
+// and empty lines are skipped.
+func (d *JSONDecoder) Decode() (Record, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return Record{}, err
+		}
+		d.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		record, lerr := decodeJSONRecord(line)
+		if lerr != nil {
+			return Record{}, &JSONLineError{Line: d.line, Err: lerr}
+		}
+		return record, nil
+	}
+}
+
+func decodeJSONRecord(line []byte) (Record, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return Record{}, err
+	}
+	if dec.More() {
+		return Record{}, errors.New("invalid character after the record")
+	}
+	if v.Kind() != KindGroup {
+		return Record{}, errors.New("record is not an object")
+	}
+
+	var (
+		record Record
+		attrs  []Attr
+	)
+	for _, a := range v.Group() {
+		switch a.Key {
+		case TimeKey:
+			if a.Value.Kind() == KindString {
+				t, err := time.Parse(time.RFC3339Nano, a.Value.String())
+				if err != nil {
+					return Record{}, fmt.Errorf("invalid time: %w", err)
+				}
+				record.Time = t
+				continue
+			}
+		case LevelKey:
+			if a.Value.Kind() == KindString {
+				if err := record.Level.UnmarshalText([]byte(a.Value.String())); err != nil {
+					return Record{}, fmt.Errorf("invalid level: %w", err)
+				}
+				continue
+			}
+		case MessageKey:
+			if a.Value.Kind() == KindString {
+				record.Message = a.Value.String()
+				continue
+			}
+		}
+		attrs = append(attrs, a)
+	}
+	r := slog.NewRecord(record.Time, record.Level, record.Message, 0)
+	r.AddAttrs(attrs...)
+	return r, nil
+}
+
+// decodeJSONValue decodes the next value of dec, keeping the order of the object keys.
+func decodeJSONValue(dec *json.Decoder) (Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return Value{}, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			var attrs []Attr
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return Value{}, err
+				}
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return Value{}, err
+				}
+				attrs = append(attrs, slog.Attr{Key: key.(string), Value: v})
+			}
+			if _, err := dec.Token(); err != nil {
+				return Value{}, err
+			}
+			return slog.GroupValue(attrs...), nil
+		case '[':
+			values := []any{}
+			for dec.More() {
+				v, err := decodeJSONValue(dec)
+				if err != nil {
+					return Value{}, err
+				}
+				values = append(values, jsonAny(v))
+			}
+			if _, err := dec.Token(); err != nil {
+				return Value{}, err
+			}
+			return slog.AnyValue(values), nil
+		}
+		return Value{}, fmt.Errorf("unexpected %v", tok)
+	case string:
+		return slog.StringValue(tok), nil
+	case json.Number:
+		if !strings.ContainsAny(tok.String(), ".eE") {
+			if i, err := tok.Int64(); err == nil {
+				return slog.Int64Value(i), nil
+			}
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		return slog.Float64Value(f), nil
+	case bool:
+		return slog.BoolValue(tok), nil
+	default: // null
+		return slog.AnyValue(nil), nil
+	}
+}
+
+// jsonAny returns v as an element of a []any, with the groups as map[string]any.
+func jsonAny(v Value) any {
+	if v.Kind() != KindGroup {
+		return v.Any()
+	}
+	m := make(map[string]any, len(v.Group()))
+	for _, a := range v.Group() {
+		m[a.Key] = jsonAny(a.Value)
+	}
+	return m
+}
@@ -0,0 +1,84 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestJSONDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewJSONHandler(&buf, nil, ""))
+	l.With("app", "test").WithGroup("req").Warn("hello",
+		"id", 42,
+		"ratio", 0.5,
+		"ok", true,
+		"tags", []string{"a", "b"},
+		"nil", nil,
+		slog.Group("db", "table", "users"),
+	)
+	buf.WriteString("\nnot json\n")
+	l.Log(LevelError+2, "second")
+
+	dec := NewJSONDecoder(&buf)
+	record, err := dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Message != "hello" || record.Level != LevelWarn || time.Since(record.Time) > time.Minute {
+		t.Errorf("Decode() = %v %v %v", record.Time, record.Level, record.Message)
+	}
+	var attrs []Attr
+	record.Attrs(func(a Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	want := []Attr{
+		slog.String("app", "test"),
+		slog.Group("req",
+			slog.Int64("id", 42),
+			slog.Float64("ratio", 0.5),
+			slog.Bool("ok", true),
+			slog.Any("tags", []any{"a", "b"}),
+			slog.Any("nil", nil),
+			slog.Group("db", "table", "users"),
+		),
+	}
+	if got := slog.GroupValue(attrs...).String(); got != slog.GroupValue(want...).String() {
+		t.Errorf("Decode() attrs = %s, want %s", got, slog.GroupValue(want...))
+	}
+
+	_, err = dec.Decode()
+	var lineErr *JSONLineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 3 {
+		t.Errorf("Decode() error = %v, want a line error at line 3", err)
+	}
+
+	record, err = dec.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Message != "second" || record.Level != LevelError+2 || record.NumAttrs() != 0 {
+		t.Errorf("Decode() = %v %v %d attrs", record.Level, record.Message, record.NumAttrs())
+	}
+	if _, err := dec.Decode(); !errors.Is(err, io.EOF) {
+		t.Errorf("Decode() error = %v, want io.EOF", err)
+	}
+}
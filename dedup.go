@@ -0,0 +1,207 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// DuplicateKeyPolicy decides what happens to attributes
+// that share a key within the same group of a record.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeepAll emits every attribute, which is the default.
+	DuplicateKeepAll DuplicateKeyPolicy = iota
+	// DuplicateKeepLast emits only the last attribute of each key.
+	DuplicateKeepLast
+	// DuplicateKeepFirst emits only the first attribute of each key.
+	DuplicateKeepFirst
+	// DuplicateError emits every attribute and
+	// reports the duplicate keys as an error from Handle.
+	DuplicateError
+)
+
+// ParseDuplicateKeyPolicy parses keep-all, keep-last, keep-first or error.
+// Unknown values fall back to DuplicateKeepAll.
+func ParseDuplicateKeyPolicy(s string) DuplicateKeyPolicy {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "keep-last":
+		return DuplicateKeepLast
+	case "keep-first":
+		return DuplicateKeepFirst
+	case "error":
+		return DuplicateError
+	default:
+		return DuplicateKeepAll
+	}
+}
+
+// NewDedupHandler returns a Handler that resolves duplicate keys of
+// the records passed to h according to policy, including the attrs
+// added by WithAttrs. To see all attrs at once, it keeps WithAttrs and
+// WithGroup to itself and passes them to h as part of each record.
+// For DuplicateKeepAll, h is returned as is.
+func NewDedupHandler(h Handler, policy DuplicateKeyPolicy) Handler {
+	if policy == DuplicateKeepAll {
+		return h
+	}
+	return &dedupHandler{handler: h, policy: policy, attrs: make([][]Attr, 1)}
+}
+
+type dedupHandler struct {
+	handler Handler
+	policy  DuplicateKeyPolicy
+
+	// attrs[i] are the attrs added after groups[:i] were opened,
+	// so len(attrs) is always len(groups)+1.
+	groups []string
+	attrs  [][]Attr
+}
+
+func (h *dedupHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	handler, ok := handlerWithOptions(h.handler, mutate)
+	if !ok {
+		return nil, false
+	}
+	cp := *h
+	cp.handler = handler
+	return &cp, true
+}
+
+func (h *dedupHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *dedupHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *dedupHandler) Handle(ctx context.Context, record Record) error {
+	last := len(h.attrs) - 1
+	attrs := make([]Attr, 0, len(h.attrs[last])+record.NumAttrs())
+	attrs = append(attrs, h.attrs[last]...)
+	record.Attrs(func(attr Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+
+	var duplicates []string
+	attrs = h.dedup(h.groups, attrs, &duplicates, 0)
+	for i := last - 1; i >= 0; i-- {
+		// the attrs of the group are already resolved
+		group := Attr{Key: h.groups[i], Value: slog.GroupValue(attrs...)}
+		attrs = h.resolve(h.groups[:i], append(h.flatten(h.groups[:i], h.attrs[i], &duplicates, 0), group), &duplicates)
+	}
+
+	r := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	r.AddAttrs(attrs...)
+	if err := h.handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate keys: %s", strings.Join(duplicates, ", "))
+	}
+	return nil
+}
+
+func (h *dedupHandler) WithAttrs(attrs []Attr) Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	cp := h.clone()
+	last := len(cp.attrs) - 1
+	cp.attrs[last] = append(slices.Clip(cp.attrs[last]), attrs...)
+	return cp
+}
+
+func (h *dedupHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(slices.Clip(cp.groups), name)
+	cp.attrs = append(cp.attrs, nil)
+	return cp
+}
+
+func (h *dedupHandler) clone() *dedupHandler {
+	return &dedupHandler{
+		handler: h.handler,
+		policy:  h.policy,
+		groups:  h.groups,
+		attrs:   slices.Clone(h.attrs),
+	}
+}
+
+// dedup resolves the duplicate keys of attrs within the given groups,
+// descending into nested groups and inlining groups with an empty key.
+func (h *dedupHandler) dedup(groups []string, attrs []Attr, duplicates *[]string, depth int) []Attr {
+	return h.resolve(groups, h.flatten(groups, attrs, duplicates, depth), duplicates)
+}
+
+// flatten inlines the groups of attrs with an empty key, drops the attrs
+// with an empty key, and dedups the nested groups.
+func (h *dedupHandler) flatten(groups []string, attrs []Attr, duplicates *[]string, depth int) []Attr {
+	flat := make([]Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = resolveValue(a.Value)
+		if a.Value.Kind() == KindGroup && depth >= maxAttrDepth {
+			a.Value = slog.StringValue(BadValue)
+		}
+		if a.Value.Kind() != KindGroup {
+			if a.Key != "" {
+				flat = append(flat, a)
+			}
+			continue
+		}
+		if a.Key == "" {
+			flat = append(flat, h.dedup(groups, a.Value.Group(), duplicates, depth+1)...)
+			continue
+		}
+		g2 := append(slices.Clip(groups), a.Key)
+		a.Value = slog.GroupValue(h.dedup(g2, a.Value.Group(), duplicates, depth+1)...)
+		flat = append(flat, a)
+	}
+	return flat
+}
+
+// resolve applies the policy to the attrs of flat sharing a key.
+func (h *dedupHandler) resolve(groups []string, flat []Attr, duplicates *[]string) []Attr {
+	index := make(map[string]int, len(flat))
+	result := make([]Attr, 0, len(flat))
+	for _, a := range flat {
+		i, ok := index[a.Key]
+		if !ok {
+			index[a.Key] = len(result)
+			result = append(result, a)
+			continue
+		}
+
+		switch h.policy {
+		case DuplicateKeepLast:
+			result[i] = a
+		case DuplicateKeepFirst:
+		default:
+			*duplicates = append(*duplicates, strings.Join(append(slices.Clip(groups), a.Key), "."))
+			result = append(result, a)
+		}
+	}
+	return result
+}
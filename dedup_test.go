@@ -0,0 +1,110 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupHandler(t *testing.T) {
+	record := func(args ...any) Record {
+		r := slog.NewRecord(time.Time{}, LevelInfo, "msg", 0)
+		r.Add(args...)
+		return r
+	}
+	tests := []struct {
+		policy  DuplicateKeyPolicy
+		flat    string
+		grouped string
+		err     string
+	}{
+		{DuplicateKeepAll, "a=1 b=1 a=2 c=1 c=2", "a=1 g.x=1 g.x=2 g.a=3", ""},
+		{DuplicateKeepLast, "a=2 b=1 c=2", "a=1 g.x=2 g.a=3", ""},
+		{DuplicateKeepFirst, "a=1 b=1 c=1", "a=1 g.x=1 g.a=3", ""},
+		{DuplicateError, "a=1 b=1 a=2 c=1 c=2", "a=1 g.x=1 g.x=2 g.a=3", "duplicate keys: "},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		h := NewDedupHandler(NewLogfmtHandler(&buf, nil, WithDisableTime(true)), tt.policy)
+
+		// the duplicates of the attrs of WithAttrs and of the record
+		err := h.WithAttrs([]Attr{slog.Int("a", 1), slog.Int("b", 1)}).
+			Handle(context.Background(), record("a", 2, "c", 1, "c", 2))
+		if want := "level=INFO msg=msg " + tt.flat + "\n"; buf.String() != want {
+			t.Errorf("%v: Handle() = %q, want %q", tt.policy, buf.String(), want)
+		}
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err+"a, c") {
+			t.Errorf("%v: Handle() error = %v, want %q", tt.policy, err, tt.err+"a, c")
+		}
+
+		// the duplicates are resolved within each group
+		buf.Reset()
+		err = h.WithAttrs([]Attr{slog.Int("a", 1)}).WithGroup("g").WithAttrs([]Attr{slog.Int("x", 1)}).
+			Handle(context.Background(), record("x", 2, "a", 3))
+		if want := "level=INFO msg=msg " + tt.grouped + "\n"; buf.String() != want {
+			t.Errorf("%v: Handle() = %q, want %q", tt.policy, buf.String(), want)
+		}
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err+"g.x") {
+			t.Errorf("%v: Handle() error = %v, want %q", tt.policy, err, tt.err+"g.x")
+		}
+	}
+}
+
+func TestDedupHandler_LogValuerCycle(t *testing.T) {
+	for _, v := range []any{selfValuer{}, groupValuer{"g"}, groupValuer{""}} {
+		var buf bytes.Buffer
+		h := NewDedupHandler(NewLogfmtHandler(&buf, nil, WithDisableTime(true)), DuplicateKeepLast)
+		record := slog.NewRecord(time.Time{}, LevelInfo, "msg", 0)
+		record.AddAttrs(slog.Any("v", v), slog.Int("a", 1), slog.Int("a", 2))
+		if err := h.Handle(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(buf.String(), " a=2\n") {
+			t.Errorf("%T: Handle() = %q, want the duplicates resolved", v, buf.String())
+		}
+		if v != (groupValuer{""}) && !strings.Contains(buf.String(), BadValue) {
+			t.Errorf("%T: Handle() = %q, want %s", v, buf.String(), BadValue)
+		}
+	}
+}
+
+func TestParseDuplicateKeyPolicy(t *testing.T) {
+	tests := map[string]DuplicateKeyPolicy{
+		"":            DuplicateKeepAll,
+		"keep-all":    DuplicateKeepAll,
+		"keep-last":   DuplicateKeepLast,
+		" Keep-First": DuplicateKeepFirst,
+		"ERROR":       DuplicateError,
+		"merge":       DuplicateKeepAll,
+	}
+	for s, want := range tests {
+		if got := ParseDuplicateKeyPolicy(s); got != want {
+			t.Errorf("ParseDuplicateKeyPolicy(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestConfig_DuplicateKeys(t *testing.T) {
+	var buf bytes.Buffer
+	New(Config{Format: "logfmt", DuplicateKeys: "keep-last"}, &buf).With("a", 1).Info("msg", "a", 2)
+	if want := " a=2\n"; !bytes.HasSuffix(buf.Bytes(), []byte(want)) {
+		t.Errorf("output = %q, want suffix %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ElasticBulkPath is the path of the Elasticsearch bulk API.
+const ElasticBulkPath = "/_bulk"
+
+// ElasticOption configures the handler created by NewElasticHandler.
+type ElasticOption func(c *elasticClient)
+
+// WithElasticBasicAuth sets the basic auth of the requests.
+func WithElasticBasicAuth(username, password string) ElasticOption {
+	return func(c *elasticClient) {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(username, password)
+		c.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithElasticAPIKey sets the base64-encoded API key of the requests.
+func WithElasticAPIKey(key string) ElasticOption {
+	return func(c *elasticClient) {
+		c.header.Set("Authorization", "ApiKey "+key)
+	}
+}
+
+// WithElasticGzip compresses the request bodies with gzip.
+func WithElasticGzip() ElasticOption {
+	return func(c *elasticClient) {
+		c.gzip = true
+	}
+}
+
+// WithElasticBatch flushes the batched records once they reach size bytes
+// or wait has elapsed since the last flush, 5MiB and 5s by default.
+// A non-positive wait keeps the default one.
+func WithElasticBatch(size int, wait time.Duration) ElasticOption {
+	return func(c *elasticClient) {
+		c.batchSize = size
+		if wait > 0 {
+			c.batchWait = wait
+		}
+	}
+}
+
+// WithElasticRetries sets how many times a bulk request failing with a network
+// error, 429 or 5xx is retried with an exponential backoff, 5 by default.
+// The batching is blocked while a request is retried, which slows down
+// the logging once the next batch is full.
+func WithElasticRetries(retries int) ElasticOption {
+	return func(c *elasticClient) {
+		c.retries = retries
+	}
+}
+
+// WithElasticHTTPClient sets the client used to index, http.DefaultClient by default.
+func WithElasticHTTPClient(client *http.Client) ElasticOption {
+	return func(c *elasticClient) {
+		c.client = client
+	}
+}
+
+// NewElasticHandler creates a Handler that indexes the records in batches
+// with the bulk API of the Elasticsearch server at url, e.g. `http://es:9200`.
+// The index is the template with each `%{layout}` replaced by the UTC time
+// of the record formatted with the Go time layout, e.g. `logs-myapp-%{2006.01.02}`.
+// The records are rendered as JSON documents with the keys of PresetECS.
+// Failed requests and the failed documents of a bulk request are reported
+// to the func set by SetErrorHandler.
+// Close the returned handler to flush the pending records.
+func NewElasticHandler(url, index string, opts *HandlerOptions, options ...ElasticOption) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	c := &elasticClient{
+		url:       strings.TrimSuffix(url, "/") + ElasticBulkPath,
+		index:     index,
+		client:    http.DefaultClient,
+		header:    make(http.Header),
+		batchSize: 5 << 20,
+		batchWait: 5 * time.Second,
+		retries:   5,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.header.Set("Content-Type", "application/x-ndjson")
+	if c.gzip {
+		c.header.Set("Content-Encoding", "gzip")
+	}
+	c.batcher = newBatcher("elastic", 0, c.batchSize, 0, c.batchWait, c.bulk)
+
+	ecs := &Config{Preset: PresetECS}
+	cp := *opts
+	cp.ReplaceAttr = ecs.fieldsReplaceAttr(levelLabelReplaceAttr(opts.ReplaceAttr))
+	return &elasticHandler{client: c, doc: slog.NewJSONHandler(c, &cp), addSource: opts.AddSource}
+}
+
+type elasticHandler struct {
+	client    *elasticClient // shared among all clones of this handler
+	doc       Handler        // renders the documents to the client
+	addSource bool
+}
+
+// Close implements io.Closer, and flushes the pending records.
+func (h *elasticHandler) Close() error {
+	return h.client.batcher.close()
+}
+
+// Flush implements Flusher, and indexes the pending records.
+func (h *elasticHandler) Flush() error {
+	return h.client.batcher.flushPending()
+}
+
+func (h *elasticHandler) NeedsSource() bool {
+	return h.addSource
+}
+
+func (h *elasticHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.doc.Enabled(ctx, level)
+}
+
+func (h *elasticHandler) Handle(ctx context.Context, record Record) error {
+	ts := record.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	doc, err := h.client.render(ctx, h.doc, record)
+	if err != nil {
+		return err
+	}
+	return h.client.batcher.add(elasticDoc{index: expandIndex(h.client.index, ts), doc: doc}, len(doc))
+}
+
+func (h *elasticHandler) WithAttrs(attrs []Attr) Handler {
+	return &elasticHandler{client: h.client, doc: h.doc.WithAttrs(attrs), addSource: h.addSource}
+}
+
+func (h *elasticHandler) WithGroup(name string) Handler {
+	return &elasticHandler{client: h.client, doc: h.doc.WithGroup(name), addSource: h.addSource}
+}
+
+// ElasticDocumentError is reported for each failed document of a bulk request.
+type ElasticDocumentError struct {
+	Index    string
+	Status   int
+	Reason   json.RawMessage // the error object of the response
+	Document []byte
+}
+
+func (e *ElasticDocumentError) Error() string {
+	return fmt.Sprintf("elastic document failed: index %s: %d: %s", e.Index, e.Status, e.Reason)
+}
+
+// elasticDoc is a document of an index.
+type elasticDoc struct {
+	index string
+	doc   []byte
+}
+
+// elasticClient batches the documents and indexes them with the bulk API.
+type elasticClient struct {
+	url       string
+	index     string
+	client    *http.Client
+	header    http.Header
+	gzip      bool
+	batchSize int
+	batchWait time.Duration
+	retries   int
+	batcher   *batcher[elasticDoc]
+
+	// the JSON handlers write to the client, one document at a time
+	mu  sync.Mutex
+	doc []byte
+}
+
+// Write implements io.Writer for the JSON handlers.
+func (c *elasticClient) Write(p []byte) (int, error) {
+	c.doc = append(c.doc[:0], bytes.TrimSpace(p)...)
+	return len(p), nil
+}
+
+// render renders record with h, which writes to c.
+func (c *elasticClient) render(ctx context.Context, h Handler, record Record) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.doc = c.doc[:0]
+	if err := h.Handle(ctx, record); err != nil {
+		return nil, err
+	}
+	return slices.Clone(c.doc), nil
+}
+
+// elasticBulkResponse is the part of the bulk response reporting the failures.
+type elasticBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Index  string          `json:"_index"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// bulk indexes docs, and reports the failed ones.
+func (c *elasticClient) bulk(docs []elasticDoc) error {
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action, err := json.Marshal(map[string]any{"index": map[string]string{"_index": doc.index}})
+		if err != nil {
+			return err
+		}
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc.doc)
+		body.WriteByte('\n')
+	}
+	if c.gzip {
+		var zbody bytes.Buffer
+		zw := gzip.NewWriter(&zbody)
+		_, _ = zw.Write(body.Bytes())
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		body = zbody
+	}
+
+	resp, err := postWithRetry(c.client, c.url, c.header, body.Bytes(), c.retries)
+	if err != nil {
+		return fmt.Errorf("elastic bulk failed: %w", err)
+	}
+	var result elasticBulkResponse
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("elastic bulk failed: invalid response: %w", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for i, item := range result.Items {
+		for _, status := range item {
+			if status.Status/100 == 2 {
+				continue
+			}
+			err := &ElasticDocumentError{Index: status.Index, Status: status.Status, Reason: status.Error}
+			if i < len(docs) {
+				err.Document = docs[i].doc
+			}
+			reportError(err)
+		}
+	}
+	return nil
+}
+
+// expandIndex replaces each `%{layout}` of the index template with t formatted in UTC.
+func expandIndex(template string, t time.Time) string {
+	if !strings.Contains(template, "%{") {
+		return template
+	}
+	t = t.UTC()
+	var b strings.Builder
+	for {
+		start := strings.Index(template, "%{")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end == -1 {
+			break
+		}
+		b.WriteString(template[:start])
+		b.WriteString(t.Format(template[start+2 : start+end]))
+		template = template[start+end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
@@ -0,0 +1,147 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestElasticHandler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		lines    []map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.URL.Path != ElasticBulkPath || r.Header.Get("Authorization") != "ApiKey key" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(zr)
+		for scanner.Scan() {
+			var line map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			lines = append(lines, line)
+		}
+		_, _ = io.WriteString(w, `{"errors":true,"items":[`+
+			`{"index":{"_index":"logs-2023.08.18","status":201}},`+
+			`{"index":{"_index":"logs-2023.08.18","status":400,"error":{"type":"mapper_parsing_exception"}}}]}`)
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, 2)
+	SetErrorHandler(func(err error) { errs <- err })
+	defer SetErrorHandler(nil)
+
+	h := NewElasticHandler(srv.URL, "logs-%{2006.01.02}", nil,
+		WithElasticAPIKey("key"),
+		WithElasticGzip(),
+		WithElasticBatch(1<<20, time.Hour),
+	)
+	ts := time.Date(2023, 8, 18, 1, 2, 3, 0, time.UTC)
+	for _, msg := range []string{"ok", "bad"} {
+		r := slog.NewRecord(ts, LevelInfo, msg, 0)
+		r.AddAttrs(slog.Int("a", 1))
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2 with a retry", requests)
+	}
+	if len(lines) != 4 {
+		t.Fatalf("lines = %v, want 4", lines)
+	}
+	action, _ := lines[0]["index"].(map[string]any)
+	if action["_index"] != "logs-2023.08.18" {
+		t.Errorf("action = %v", lines[0])
+	}
+	doc := lines[1]
+	if doc["@timestamp"] != "2023-08-18T01:02:03Z" || doc["log.level"] != "info" || doc["message"] != "ok" || doc["a"] != float64(1) {
+		t.Errorf("document = %v", doc)
+	}
+
+	select {
+	case err := <-errs:
+		var docErr *ElasticDocumentError
+		if !errors.As(err, &docErr) || docErr.Status != 400 || string(docErr.Document) == "" {
+			t.Errorf("error = %v, want the failed document", err)
+		}
+	default:
+		t.Error("no error reported")
+	}
+}
+
+func TestElasticHandler_ZeroWait(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		lines int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines++
+		}
+		_, _ = io.WriteString(w, `{"errors":false}`)
+	}))
+	defer srv.Close()
+
+	h := NewElasticHandler(srv.URL, "logs", nil, WithElasticBatch(1<<20, 0))
+	NewLogger(h).Info("hello")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if lines != 2 {
+		t.Errorf("lines = %d, want the action and the record flushed on Close", lines)
+	}
+}
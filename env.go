@@ -0,0 +1,133 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NewFromEnv creates a Logger from the Config of the environment
+// variables with prefix, see Config.ApplyEnv, which is validated
+// like NewE. opts are passed to New.
+func NewFromEnv(prefix string, opts ...any) (*Logger, error) {
+	var cfg Config
+	if err := cfg.ApplyEnv(prefix); err != nil {
+		return nil, err
+	}
+	return NewE(cfg, opts...)
+}
+
+// ApplyEnv overrides the fields of the Config with the environment
+// variables with prefix, e.g. a Config loaded from a file. The variable of
+// a field is its JSON name in upper snake case, e.g. APP_LEVEL, APP_FORMAT
+// or APP_MAX_SIZE for the prefix APP. Lists are comma-separated, e.g.
+// APP_REDACT_KEYS=password,token, and maps are comma-separated key=value
+// pairs, e.g. APP_LEVEL_FILES=error=app.err.log. APP_COLORFUL is the
+// opposite of APP_DISABLE_COLOR. The empty variables are ignored, and the invalid
+// ones are returned with their names, leaving their fields unchanged.
+func (c *Config) ApplyEnv(prefix string) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	var errs []error
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		env := prefix + envName(name)
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		if err := setEnvField(v.Field(i), value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", env, err))
+		}
+	}
+
+	env := prefix + "COLORFUL"
+	if value := os.Getenv(env); value != "" {
+		colorful, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", env, err))
+		} else {
+			c.DisableColor = !colorful
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envName converts a camel case name to upper snake case, e.g. timeUTC to TIME_UTC.
+func envName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func setEnvField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Slice:
+		var values []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+		field.Set(reflect.ValueOf(values))
+	case reflect.Map:
+		m := reflect.MakeMap(field.Type())
+		for _, pair := range strings.Split(value, ",") {
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%q isn't a key=value pair", pair)
+			}
+			key := reflect.ValueOf(strings.TrimSpace(k)).Convert(field.Type().Key())
+			m.SetMapIndex(key, reflect.ValueOf(strings.TrimSpace(v)).Convert(field.Type().Elem()))
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := map[string]string{
+		"level":           "LEVEL",
+		"maxSize":         "MAX_SIZE",
+		"timeUTC":         "TIME_UTC",
+		"goroutineID":     "GOROUTINE_ID",
+		"splitStdStreams": "SPLIT_STD_STREAMS",
+	}
+	for name, want := range tests {
+		if got := envName(name); got != want {
+			t.Errorf("envName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	t.Setenv("APP_LEVEL", "debug")
+	t.Setenv("APP_SOURCE", "true")
+	t.Setenv("APP_MAX_SIZE", "10")
+	t.Setenv("APP_TIME_UTC", "1")
+	t.Setenv("APP_COLORFUL", "false")
+	t.Setenv("APP_REDACT_KEYS", "password, token")
+	t.Setenv("APP_LEVEL_FILES", "error=app.err.log,warn=app.warn.log")
+	t.Setenv("APP_FORMAT", "")
+
+	cfg := Config{Format: "json", Level: "warn", Filename: "app.log"}
+	if err := cfg.ApplyEnv("APP"); err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Level:        "debug",
+		Format:       "json",
+		Source:       true,
+		Filename:     "app.log",
+		MaxSize:      10,
+		TimeUTC:      true,
+		DisableColor: true,
+		RedactKeys:   []string{"password", "token"},
+		LevelFiles:   map[SLevel]string{"error": "app.err.log", "warn": "app.warn.log"},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ApplyEnv() = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("LOG_FORMAT", "logfmt")
+	if l, err := NewFromEnv("LOG_"); err != nil || l == nil {
+		t.Fatalf("NewFromEnv() = %v, %v, want a Logger", l, err)
+	}
+
+	t.Setenv("LOG_SOURCE", "yes")
+	t.Setenv("LOG_MAX_AGE", "week")
+	t.Setenv("LOG_LEVEL", "debag")
+	_, err := NewFromEnv("LOG")
+	if err == nil {
+		t.Fatal("NewFromEnv() = nil error, want an error")
+	}
+	for _, want := range []string{"invalid LOG_SOURCE", "invalid LOG_MAX_AGE"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("NewFromEnv() = %v, want %q", err, want)
+		}
+	}
+
+	t.Setenv("LOG_SOURCE", "")
+	t.Setenv("LOG_MAX_AGE", "")
+	if _, err := NewFromEnv("LOG"); err == nil || !strings.Contains(err.Error(), `unknown level "debag"`) {
+		t.Errorf("NewFromEnv() = %v, want the unknown level", err)
+	}
+}
+
+func TestConfig_ApplyEnv_Fields(t *testing.T) {
+	t.Setenv("APP_FIELDS", "service=api, env=prod")
+	var cfg Config
+	if err := cfg.ApplyEnv("APP"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"service": "api", "env": "prod"}; !reflect.DeepEqual(cfg.Fields, want) {
+		t.Errorf("Fields = %v, want %v", cfg.Fields, want)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errorHandler atomic.Value
+
+func init() {
+	SetErrorHandler(nil)
+}
+
+// SetErrorHandler sets the func called with the errors returned by the
+// handlers from Handle, e.g. a full disk, and the ones that handlers can't
+// return from Handle, e.g. the failed deliveries of batched records.
+// By default, or if fn is nil, the errors are written to os.Stderr,
+// at most once per second, with the number of the ones suppressed since.
+func SetErrorHandler(fn func(err error)) {
+	if fn == nil {
+		fn = newStderrErrorHandler(time.Second)
+	}
+	errorHandler.Store(fn)
+}
+
+// newStderrErrorHandler returns the default error handler,
+// writing at most one error to os.Stderr per interval.
+func newStderrErrorHandler(interval time.Duration) func(err error) {
+	var (
+		mu         sync.Mutex
+		last       time.Time
+		suppressed int
+	)
+	return func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		now := time.Now()
+		if !last.IsZero() && now.Sub(last) < interval {
+			suppressed++
+			return
+		}
+		last = now
+		if suppressed > 0 {
+			fmt.Fprintf(os.Stderr, "wslog: %v (%d errors suppressed)\n", err, suppressed)
+			suppressed = 0
+			return
+		}
+		fmt.Fprintf(os.Stderr, "wslog: %v\n", err)
+	}
+}
+
+// ReportError passes err to the func set by SetErrorHandler,
+// for the handlers implemented outside of this package.
+func ReportError(err error) {
+	reportError(err)
+}
+
+// reportError passes err to the func set by SetErrorHandler.
+func reportError(err error) {
+	errorHandler.Load().(func(err error))(err)
+}
@@ -0,0 +1,108 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventID is the event identifier of all events written by the handler.
+const eventID = 1
+
+// NewEventLogHandler creates a Handler that writes to the Windows Event Log
+// of the given event source, which must already be installed, e.g. by
+// eventlog.InstallAsEventCreate. The records are rendered like the default
+// log handler without the time and level, which are reported by the
+// Event Log itself. Close the returned handler to release the event source.
+func NewEventLogHandler(source string, opts *HandlerOptions) (Handler, error) {
+	elog, err := eventlog.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	return newEventLogHandler(elog, opts), nil
+}
+
+// eventWriter writes the events of an event source, see eventlog.Log.
+type eventWriter interface {
+	Info(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Error(eid uint32, msg string) error
+	Close() error
+}
+
+func newEventLogHandler(elog eventWriter, opts *HandlerOptions) *eventLogHandler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	cp := *opts
+	cp.ReplaceAttr = func(groups []string, a Attr) Attr {
+		if opts.ReplaceAttr != nil {
+			a = opts.ReplaceAttr(groups, a)
+		}
+		if len(groups) == 0 && (a.Key == TimeKey || a.Key == LevelKey) {
+			return Attr{}
+		}
+		return a
+	}
+	text := NewLogHandler(io.Discard, &cp, true).(*logHandler)
+	return &eventLogHandler{elog: elog, text: text}
+}
+
+type eventLogHandler struct {
+	elog eventWriter
+	text *logHandler
+}
+
+// Close implements io.Closer, and closes the event source.
+func (h *eventLogHandler) Close() error {
+	return h.elog.Close()
+}
+
+func (h *eventLogHandler) NeedsSource() bool {
+	return h.text.NeedsSource()
+}
+
+func (h *eventLogHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.text.Enabled(ctx, level)
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, record Record) error {
+	var buf bytes.Buffer
+	if err := h.text.withWriter(&buf).Handle(ctx, record); err != nil {
+		return err
+	}
+	msg := string(bytes.TrimSpace(buf.Bytes()))
+
+	switch {
+	case record.Level >= LevelError:
+		return h.elog.Error(eventID, msg)
+	case record.Level >= LevelWarn:
+		return h.elog.Warning(eventID, msg)
+	default:
+		return h.elog.Info(eventID, msg)
+	}
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []Attr) Handler {
+	return &eventLogHandler{elog: h.elog, text: h.text.WithAttrs(attrs).(*logHandler)}
+}
+
+func (h *eventLogHandler) WithGroup(name string) Handler {
+	return &eventLogHandler{elog: h.elog, text: h.text.WithGroup(name).(*logHandler)}
+}
@@ -0,0 +1,76 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package wslog
+
+import (
+	"slices"
+	"testing"
+)
+
+// fakeEventLog records the events written to it as `type: msg`.
+type fakeEventLog struct {
+	events []string
+	closed bool
+}
+
+func (l *fakeEventLog) Info(_ uint32, msg string) error {
+	l.events = append(l.events, "info: "+msg)
+	return nil
+}
+
+func (l *fakeEventLog) Warning(_ uint32, msg string) error {
+	l.events = append(l.events, "warning: "+msg)
+	return nil
+}
+
+func (l *fakeEventLog) Error(_ uint32, msg string) error {
+	l.events = append(l.events, "error: "+msg)
+	return nil
+}
+
+func (l *fakeEventLog) Close() error {
+	l.closed = true
+	return nil
+}
+
+func TestEventLogHandler(t *testing.T) {
+	elog := &fakeEventLog{}
+	h := newEventLogHandler(elog, &HandlerOptions{Level: LevelDebug})
+	l := NewLogger(h).With("a", 1)
+	l.Debug("debug")
+	l.Info("info")
+	l.Warn("warn")
+	l.Log(LevelWarn+2, "warn+2")
+	l.WithGroup("g").Error("error", "b", 2)
+	l.Log(LevelError+4, "fatal")
+
+	want := []string{
+		"info: debug  a=1",
+		"info: info  a=1",
+		"warning: warn  a=1",
+		"warning: warn+2  a=1",
+		"error: error  a=1 g.b=2",
+		"error: fatal  a=1",
+	}
+	if !slices.Equal(elog.events, want) {
+		t.Errorf("events = %q, want %q", elog.events, want)
+	}
+
+	if err := h.Close(); err != nil || !elog.closed {
+		t.Errorf("Close() = %v, closed = %v, want the event source closed", err, elog.closed)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// OccurrenceKey is the key of the number of calls from the call site
+// of the records logged by Logger.InfoEvery.
+const OccurrenceKey = "occurrence"
+
+// maxEveryCallSites bounds the call sites counted by Logger.InfoEvery,
+// all counters start over when a new call site exceeds it.
+const maxEveryCallSites = 4096
+
+var everyCounters struct {
+	mu sync.Mutex
+	m  map[uintptr]uint64
+}
+
+// everyCount counts a call from the call site pc, returning the number of calls.
+func everyCount(pc uintptr) uint64 {
+	everyCounters.mu.Lock()
+	defer everyCounters.mu.Unlock()
+	if everyCounters.m == nil {
+		everyCounters.m = make(map[uintptr]uint64)
+	}
+	count, ok := everyCounters.m[pc]
+	if !ok && len(everyCounters.m) >= maxEveryCallSites {
+		clear(everyCounters.m)
+	}
+	count++
+	everyCounters.m[pc] = count
+	return count
+}
+
+// InfoEvery logs at LevelInfo only every n-th call from the same call
+// site, starting with the first one, e.g. the progress of a loop.
+// The call sites are told apart by their PC, so calls with the same
+// message from different lines are counted separately. The records
+// have the number of calls from the call site as OccurrenceKey.
+// Calls while LevelInfo is disabled are not counted.
+func (l *Logger) InfoEvery(n int, msg string, args ...any) {
+	l.logEvery(emptyCtx, LevelInfo, n, msg, args...)
+}
+
+// logEvery is like [Logger.log], but only for every n-th call from the
+// call site. It must always be called directly by an exported logging
+// method or function, because it uses a fixed call depth to obtain the pc.
+func (l *Logger) logEvery(ctx context.Context, level Level, n int, msg string, args ...any) {
+	if !l.EnabledCtx(ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	// skip [runtime.Callers, this function, this function's caller]
+	runtime.Callers(l.skip, pcs[:])
+	count := everyCount(pcs[0])
+	if n > 1 && (count-1)%uint64(n) != 0 {
+		return
+	}
+
+	checkBadKeys(args, l.skip)
+	attrs := append(argsToAttrSlice(args), slog.Uint64(OccurrenceKey, count))
+	l.logAttrsPC(ctx, level, pcs[0], msg, attrs...)
+}
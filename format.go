@@ -0,0 +1,149 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatValueFunc renders the value of the attribute with the given key.
+// It reports false if it does not handle the value,
+// in which case the next hook or the default rendering is used.
+type FormatValueFunc func(key string, v Value) (string, bool)
+
+// DurationFormat renders a duration value.
+type DurationFormat func(d time.Duration) string
+
+var (
+	// DurationString renders durations like 1.5s, which is the default.
+	DurationString DurationFormat = time.Duration.String
+	// DurationMillis renders durations as integer milliseconds.
+	DurationMillis DurationFormat = func(d time.Duration) string {
+		return strconv.FormatInt(d.Milliseconds(), 10)
+	}
+	// DurationSeconds renders durations as float seconds.
+	DurationSeconds DurationFormat = func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	}
+)
+
+// BoolFormat renders a bool value.
+type BoolFormat func(b bool) string
+
+var (
+	// BoolTrueFalse renders bools as true/false, which is the default.
+	BoolTrueFalse BoolFormat = strconv.FormatBool
+	// BoolOneZero renders bools as 1/0.
+	BoolOneZero BoolFormat = func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	// BoolYesNo renders bools as yes/no.
+	BoolYesNo BoolFormat = func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+)
+
+// timeReplaceAttr returns a ReplaceAttr func which converts
+// the record time to loc and then calls next.
+func timeReplaceAttr(loc *time.Location, next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		if len(groups) == 0 && a.Key == TimeKey && a.Value.Kind() == KindTime {
+			a.Value = slog.TimeValue(a.Value.Time().In(loc))
+		}
+		if next != nil {
+			a = next(groups, a)
+		}
+		return a
+	}
+}
+
+// omitBuiltinsReplaceAttr drops the time and the level of the records,
+// before the other replacements.
+func omitBuiltinsReplaceAttr(disableTime, disableLevel bool, next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		if len(groups) == 0 && (disableTime && a.Key == TimeKey || disableLevel && a.Key == LevelKey) {
+			return Attr{}
+		}
+		if next != nil {
+			a = next(groups, a)
+		}
+		return a
+	}
+}
+
+// FormatDuration rounds duration values to the given precision,
+// e.g. a precision of 10ms renders 1.234567891s as 1.23s.
+func FormatDuration(precision time.Duration) FormatValueFunc {
+	return func(_ string, v Value) (string, bool) {
+		if v.Kind() != KindDuration {
+			return "", false
+		}
+		return v.Duration().Round(precision).String(), true
+	}
+}
+
+var byteSizeUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+// FormatByteSize renders integer values of keys ending with suffix
+// as human-readable byte sizes, e.g. 4404019 with the key `body_bytes` as 4.2MB.
+func FormatByteSize(suffix string) FormatValueFunc {
+	return func(key string, v Value) (string, bool) {
+		if !strings.HasSuffix(key, suffix) {
+			return "", false
+		}
+
+		var size float64
+		switch v.Kind() {
+		case KindInt64:
+			if v.Int64() < 0 {
+				return "", false
+			}
+			size = float64(v.Int64())
+		case KindUint64:
+			size = float64(v.Uint64())
+		default:
+			return "", false
+		}
+
+		unit := 0
+		for size >= 1024 && unit < len(byteSizeUnits)-1 {
+			size /= 1024
+			unit++
+		}
+		if unit == 0 {
+			return strconv.FormatFloat(size, 'f', -1, 64) + byteSizeUnits[unit], true
+		}
+		return strconv.FormatFloat(size, 'f', 1, 64) + byteSizeUnits[unit], true
+	}
+}
+
+// FormatFloat limits float values to the given number of significant digits.
+func FormatFloat(digits int) FormatValueFunc {
+	return func(_ string, v Value) (string, bool) {
+		if v.Kind() != KindFloat64 {
+			return "", false
+		}
+		return strconv.FormatFloat(v.Float64(), 'g', digits, 64), true
+	}
+}
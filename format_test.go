@@ -0,0 +1,96 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatValueFuncs(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   FormatValueFunc
+		key  string
+		v    Value
+		want string
+		ok   bool
+	}{
+		{"duration", FormatDuration(10 * time.Millisecond), "d", slog.DurationValue(1234567891), "1.23s", true},
+		{"duration zero", FormatDuration(10 * time.Millisecond), "d", slog.DurationValue(0), "0s", true},
+		{"duration negative", FormatDuration(time.Second), "d", slog.DurationValue(-1500 * time.Millisecond), "-2s", true},
+		{"duration half", FormatDuration(10 * time.Millisecond), "d", slog.DurationValue(5 * time.Millisecond), "10ms", true},
+		{"duration zero precision", FormatDuration(0), "d", slog.DurationValue(1234567891), "1.234567891s", true},
+		{"duration not a duration", FormatDuration(time.Second), "d", slog.Int64Value(1), "", false},
+
+		{"bytes zero", FormatByteSize("_bytes"), "body_bytes", slog.Int64Value(0), "0B", true},
+		{"bytes below a KB", FormatByteSize("_bytes"), "body_bytes", slog.Int64Value(1023), "1023B", true},
+		{"bytes a KB", FormatByteSize("_bytes"), "body_bytes", slog.Int64Value(1024), "1.0KB", true},
+		{"bytes MB", FormatByteSize("_bytes"), "body_bytes", slog.Int64Value(4404019), "4.2MB", true},
+		{"bytes max uint", FormatByteSize("_bytes"), "body_bytes", slog.Uint64Value(math.MaxUint64), "16.0EB", true},
+		{"bytes negative", FormatByteSize("_bytes"), "body_bytes", slog.Int64Value(-1), "", false},
+		{"bytes other key", FormatByteSize("_bytes"), "count", slog.Int64Value(1024), "", false},
+		{"bytes not an int", FormatByteSize("_bytes"), "body_bytes", slog.StringValue("1024"), "", false},
+
+		{"float", FormatFloat(3), "f", slog.Float64Value(3.14159), "3.14", true},
+		{"float zero", FormatFloat(3), "f", slog.Float64Value(0), "0", true},
+		{"float negative", FormatFloat(3), "f", slog.Float64Value(-1234.5), "-1.23e+03", true},
+		{"float shortest", FormatFloat(-1), "f", slog.Float64Value(0.1), "0.1", true},
+		{"float not a float", FormatFloat(3), "f", slog.Int64Value(3), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.fn(tt.key, tt.v)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: got (%q, %t), want (%q, %t)", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestOmitBuiltinsReplaceAttr(t *testing.T) {
+	mark := func(_ []string, a Attr) Attr {
+		a.Key += "!"
+		return a
+	}
+	tests := []struct {
+		disableTime, disableLevel bool
+		groups                    []string
+		key                       string
+		want                      string
+	}{
+		{true, false, nil, TimeKey, ""},
+		{true, false, nil, LevelKey, LevelKey + "!"},
+		{false, true, nil, TimeKey, TimeKey + "!"},
+		{false, true, nil, LevelKey, ""},
+		{true, true, nil, MessageKey, MessageKey + "!"},
+		{true, true, []string{"g"}, TimeKey, TimeKey + "!"},
+		{true, true, []string{"g"}, LevelKey, LevelKey + "!"},
+		{false, false, nil, TimeKey, TimeKey + "!"},
+	}
+	for _, tt := range tests {
+		fn := omitBuiltinsReplaceAttr(tt.disableTime, tt.disableLevel, mark)
+		if got := fn(tt.groups, slog.String(tt.key, "v")).Key; got != tt.want {
+			t.Errorf("omitBuiltinsReplaceAttr(%t, %t)(%v, %s) = %q, want %q",
+				tt.disableTime, tt.disableLevel, tt.groups, tt.key, got, tt.want)
+		}
+	}
+
+	// a nil next keeps the other attrs
+	fn := omitBuiltinsReplaceAttr(true, true, nil)
+	if got := fn(nil, slog.String("a", "v")); !got.Equal(slog.String("a", "v")) {
+		t.Errorf("omitBuiltinsReplaceAttr(nil) = %v, want a=v", got)
+	}
+}
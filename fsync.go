@@ -0,0 +1,82 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"errors"
+)
+
+// Syncer is implemented by the writers that can commit their writes
+// to stable storage, e.g. *Writer and *os.File.
+type Syncer interface {
+	Sync() error
+}
+
+// newSyncHandler returns a Handler that syncs syncers after each
+// record of h at or above level, see Config.SyncLevel.
+func newSyncHandler(h Handler, level Level, syncers []Syncer) Handler {
+	return &syncHandler{handler: h, level: level, syncers: syncers}
+}
+
+type syncHandler struct {
+	handler Handler
+	level   Level
+	syncers []Syncer // shared among all clones of this handler
+}
+
+func (h *syncHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	handler, ok := handlerWithOptions(h.handler, mutate)
+	if !ok {
+		return nil, false
+	}
+	return &syncHandler{handler: handler, level: h.level, syncers: h.syncers}, true
+}
+
+func (h *syncHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *syncHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *syncHandler) Handle(ctx context.Context, record Record) error {
+	if err := h.handler.Handle(ctx, record); err != nil {
+		return err
+	}
+	if record.Level < h.level {
+		return nil
+	}
+	return syncAll(h.syncers)
+}
+
+func (h *syncHandler) WithAttrs(attrs []Attr) Handler {
+	return &syncHandler{handler: h.handler.WithAttrs(attrs), level: h.level, syncers: h.syncers}
+}
+
+func (h *syncHandler) WithGroup(name string) Handler {
+	return &syncHandler{handler: h.handler.WithGroup(name), level: h.level, syncers: h.syncers}
+}
+
+func syncAll(syncers []Syncer) error {
+	var errs []error
+	for _, s := range syncers {
+		if err := s.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,332 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultGELFChunkSize is the default size of the UDP datagrams,
+	// which fits the MTU of most networks.
+	DefaultGELFChunkSize = 1420
+
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// gelfChunkMagic are the magic bytes starting each chunk of a message.
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFOption configures the handler created by NewGELFHandler.
+type GELFOption func(h *gelfHandler)
+
+// WithGELFCompression gzips the messages sent over UDP.
+func WithGELFCompression(compress bool) GELFOption {
+	return func(h *gelfHandler) {
+		h.compress = compress
+	}
+}
+
+// WithGELFChunkSize sets the maximum size of the UDP datagrams,
+// DefaultGELFChunkSize by default. Larger messages are chunked.
+func WithGELFChunkSize(size int) GELFOption {
+	return func(h *gelfHandler) {
+		h.chunkSize = size
+	}
+}
+
+// WithGELFHost sets the host of the messages, the hostname by default.
+func WithGELFHost(host string) GELFOption {
+	return func(h *gelfHandler) {
+		h.host = host
+	}
+}
+
+// NewGELFHandler creates a Handler that sends GELF 1.1 messages to the
+// Graylog input at addr, e.g. `udp://graylog:12201` or `tcp://graylog:12201`,
+// the network is udp if addr has no scheme.
+// The level is sent as the syslog severity, see syslogSeverity,
+// and each attribute as an additional field whose group-qualified key is
+// joined by underscores, e.g. `_http_method`.
+// UDP messages larger than the chunk size are chunked, and can be gzipped.
+// TCP messages are terminated by a null byte and never compressed.
+// The connection is dialed on the first record and redialed with an
+// exponential backoff after a failed write. Close the returned handler
+// to close the connection.
+func NewGELFHandler(addr string, opts *HandlerOptions, options ...GELFOption) Handler {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok {
+		network, address = "udp", addr
+	}
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	hostname, _ := os.Hostname()
+
+	h := &gelfHandler{
+		conn: &netConn{name: "gelf", dial: func() (net.Conn, error) {
+			return net.Dial(network, address)
+		}},
+		opts:      *opts,
+		stream:    !strings.HasPrefix(network, "udp"),
+		host:      hostname,
+		chunkSize: DefaultGELFChunkSize,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+type gelfHandler struct {
+	conn      *netConn // shared among all clones of this handler
+	opts      HandlerOptions
+	stream    bool
+	host      string
+	compress  bool
+	chunkSize int
+
+	groups []string
+	fields []byte // preformatted fields of WithAttrs
+}
+
+// Close implements io.Closer, and closes the connection.
+func (h *gelfHandler) Close() error {
+	return h.conn.close()
+}
+
+func (h *gelfHandler) clone() *gelfHandler {
+	return &gelfHandler{
+		conn:      h.conn,
+		opts:      h.opts,
+		stream:    h.stream,
+		host:      h.host,
+		compress:  h.compress,
+		chunkSize: h.chunkSize,
+		groups:    slices.Clip(h.groups),
+		fields:    slices.Clip(h.fields),
+	}
+}
+
+func (h *gelfHandler) NeedsSource() bool {
+	return h.opts.AddSource
+}
+
+func (h *gelfHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *gelfHandler) Handle(_ context.Context, record Record) error {
+	msg := record.Message
+	if msg == "" {
+		// Graylog rejects messages without a short_message
+		msg = "-"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"version":"1.1","host":`)
+	appendJSONString(&buf, h.host)
+	buf.WriteString(`,"short_message":`)
+	appendJSONString(&buf, msg)
+	if !record.Time.IsZero() {
+		buf.WriteString(`,"timestamp":`)
+		buf.WriteString(strconv.FormatFloat(float64(record.Time.UnixMicro())/1e6, 'f', -1, 64))
+	}
+	buf.WriteString(`,"level":`)
+	buf.WriteString(strconv.Itoa(syslogSeverity(record.Level)))
+	// source, records constructed without a caller have no PC
+	if h.opts.AddSource && record.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{record.PC})
+		f, _ := fs.Next()
+		h.appendField(&buf, nil, slog.String("file", f.File))
+		h.appendField(&buf, nil, slog.Int("line", f.Line))
+		h.appendField(&buf, nil, slog.String("function", f.Function))
+	}
+	buf.Write(h.fields)
+	record.Attrs(func(attr Attr) bool {
+		h.appendField(&buf, h.groups, attr)
+		return true
+	})
+	buf.WriteByte('}')
+
+	if h.stream {
+		buf.WriteByte(0)
+		return h.conn.write(func(conn net.Conn) error {
+			_, err := conn.Write(buf.Bytes())
+			return err
+		})
+	}
+
+	payload := buf.Bytes()
+	if h.compress {
+		var zbuf bytes.Buffer
+		zw := gzip.NewWriter(&zbuf)
+		if _, err := zw.Write(payload); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		payload = zbuf.Bytes()
+	}
+	chunks, err := gelfChunks(payload, h.chunkSize)
+	if err != nil {
+		return err
+	}
+	return h.conn.write(func(conn net.Conn) error {
+		for _, chunk := range chunks {
+			if _, err := conn.Write(chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (h *gelfHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		cp.appendField(&buf, cp.groups, attr)
+	}
+	cp.fields = append(cp.fields, buf.Bytes()...)
+	return cp
+}
+
+func (h *gelfHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	return cp
+}
+
+// appendField appends a as the `,"_name":value` additional fields.
+func (h *gelfHandler) appendField(buf *bytes.Buffer, groups []string, a Attr) {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return
+	}
+
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendField(buf, g2, ga)
+		}
+		return
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, "_") + "_" + key
+	}
+	buf.WriteByte(',')
+	appendJSONString(buf, gelfFieldName(key))
+	buf.WriteByte(':')
+
+	// GELF values are either numbers or strings
+	v := a.Value
+	switch v.Kind() {
+	case KindInt64:
+		buf.WriteString(strconv.FormatInt(v.Int64(), 10))
+		return
+	case KindUint64:
+		buf.WriteString(strconv.FormatUint(v.Uint64(), 10))
+		return
+	case KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			return
+		}
+	}
+	appendJSONString(buf, v.String())
+}
+
+// gelfFieldName converts key to a valid additional field name,
+// which is prefixed with an underscore and consists of word characters,
+// dots and dashes. The reserved `_id` is renamed to `__id`.
+func gelfFieldName(key string) string {
+	name := []byte("_" + key)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '_' || c == '.' || c == '-') {
+			name[i] = '_'
+		}
+	}
+	if string(name) == "_id" {
+		return "__id"
+	}
+	return string(name)
+}
+
+// gelfChunks splits payload into the GELF chunks of at most size bytes,
+// a payload that fits is returned as is.
+func gelfChunks(payload []byte, size int) ([][]byte, error) {
+	if size <= gelfChunkHeaderSize {
+		size = DefaultGELFChunkSize
+	}
+	if len(payload) <= size {
+		return [][]byte{payload}, nil
+	}
+
+	dataSize := size - gelfChunkHeaderSize
+	count := (len(payload) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf message of %d bytes exceeds %d chunks", len(payload), gelfMaxChunks)
+	}
+
+	var id [8]byte
+	binary.BigEndian.PutUint64(id[:], rand.Uint64())
+	chunks := make([][]byte, 0, count)
+	for seq := 0; seq < count; seq++ {
+		data := payload[seq*dataSize : min((seq+1)*dataSize, len(payload))]
+		chunk := make([]byte, 0, gelfChunkHeaderSize+len(data))
+		chunk = append(chunk, gelfChunkMagic...)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, data...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// appendJSONString appends s to buf as a JSON string.
+func appendJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
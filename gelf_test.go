@@ -0,0 +1,177 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readGELF reads a GELF message from pc, reassembling its chunks.
+func readGELF(t *testing.T, pc net.PacketConn) []byte {
+	t.Helper()
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var (
+		chunks [][]byte
+		count  int
+	)
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datagram := bytes.Clone(buf[:n])
+		if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+			return datagram
+		}
+		if chunks == nil {
+			count = int(datagram[11])
+			chunks = make([][]byte, count)
+		}
+		chunks[datagram[10]] = datagram[gelfChunkHeaderSize:]
+
+		received := 0
+		for _, chunk := range chunks {
+			if chunk != nil {
+				received++
+			}
+		}
+		if received == count {
+			return bytes.Join(chunks, nil)
+		}
+	}
+}
+
+func TestGELFHandler(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Skip(err)
+		}
+
+		h := NewGELFHandler(pc.LocalAddr().String(), nil,
+			WithGELFHost("host"),
+			WithGELFChunkSize(64),
+			WithGELFCompression(compress),
+		)
+		long := strings.Repeat("x", 1000)
+		NewLogger(h).WithGroup("http").Warn("hello", "method", "GET", "status", 200, "id", "1", "body", long)
+
+		payload := readGELF(t, pc)
+		if compress {
+			zr, err := gzip.NewReader(bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload, err = io.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var got map[string]any
+		if err := json.Unmarshal(payload, &got); err != nil {
+			t.Fatalf("unmarshal %q: %v", payload, err)
+		}
+		want := map[string]any{
+			"version":       "1.1",
+			"host":          "host",
+			"short_message": "hello",
+			"level":         float64(4),
+			"_http_method":  "GET",
+			"_http_status":  float64(200),
+			"_http_id":      "1",
+			"_http_body":    long,
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("compress=%v: %s = %v, want %v", compress, key, got[key], value)
+			}
+		}
+		if _, ok := got["timestamp"].(float64); !ok {
+			t.Errorf("compress=%v: timestamp = %v, want float", compress, got["timestamp"])
+		}
+
+		_ = h.(io.Closer).Close()
+		_ = pc.Close()
+	}
+}
+
+func TestGELFHandler_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+
+	h := NewGELFHandler("tcp://"+ln.Addr().String(), nil, WithGELFCompression(true))
+	defer h.(io.Closer).Close()
+	l := NewLogger(h)
+	l.Info("first")
+	l.Info("second")
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var data []byte
+	buf := make([]byte, 4096)
+	for bytes.Count(data, []byte{0}) < 2 {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, buf[:n]...)
+	}
+	for i, msg := range bytes.Split(bytes.TrimSuffix(data, []byte{0}), []byte{0}) {
+		var got map[string]any
+		if err := json.Unmarshal(msg, &got); err != nil {
+			t.Fatalf("unmarshal %q: %v", msg, err)
+		}
+		if want := []string{"first", "second"}[i]; got["short_message"] != want {
+			t.Errorf("short_message = %v, want %v", got["short_message"], want)
+		}
+	}
+}
+
+func TestGELFChunks_TooLarge(t *testing.T) {
+	payload := make([]byte, (100-gelfChunkHeaderSize)*gelfMaxChunks+1)
+	if _, err := gelfChunks(payload, 100); err == nil {
+		t.Error("gelfChunks() error = nil, want too many chunks")
+	}
+}
+
+func TestGELFFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user":     "_user",
+		"id":       "__id",
+		"a b":      "_a_b",
+		"http.url": "_http.url",
+	}
+	for key, want := range tests {
+		if got := gelfFieldName(key); got != want {
+			t.Errorf("gelfFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/zc2638/wslog
 
 go 1.21.0
+
+require (
+	golang.org/x/sys v0.15.0
+	golang.org/x/time v0.5.0
+)
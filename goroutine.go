@@ -0,0 +1,50 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// GoroutineIDKey is the key used for the goroutine ID of Logger.WithGoroutineID.
+const GoroutineIDKey = "goid"
+
+// CurrentGoroutineID returns the ID of the calling goroutine for
+// Logger.WithGoroutineID. Go doesn't expose it, so by default it is
+// parsed from the header of runtime.Stack, which is slow.
+// It can be replaced, e.g. by a faster implementation.
+var CurrentGoroutineID = func() uint64 {
+	var buf [64]byte
+	// the header is like "goroutine 18 [running]:"
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// WithGoroutineID returns a Logger that adds an attribute with the key
+// GoroutineIDKey and the ID of the logging goroutine to each record,
+// e.g. to debug concurrency issues. It calls CurrentGoroutineID for
+// each record, which is too slow for production by default.
+func (l *Logger) WithGoroutineID(enabled bool) *Logger {
+	c := l.clone()
+	c.goid = enabled
+	return c
+}
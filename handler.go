@@ -21,25 +21,262 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"reflect"
 	"runtime"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
-func NewLogHandler(w io.Writer, opts *HandlerOptions, disableColor bool) Handler {
+// LogHandlerOption configures the default log handler created by NewLogHandler.
+type LogHandlerOption func(h *logHandler)
+
+// WithFormatValue sets the hooks used to render attribute values.
+// The hooks are consulted in order, and the first one that reports true
+// provides the rendered value. If none match, Value.String is used.
+func WithFormatValue(fns ...FormatValueFunc) LogHandlerOption {
+	return func(h *logHandler) {
+		h.formatValues = append(h.formatValues, fns...)
+	}
+}
+
+// WithSeparator sets the separator used to join group names into
+// qualified attribute keys, "." by default.
+func WithSeparator(sep string) LogHandlerOption {
+	return func(h *logHandler) {
+		h.sep = sep
+	}
+}
+
+// WithDurationFormat sets how duration values are rendered,
+// DurationString by default.
+func WithDurationFormat(format DurationFormat) LogHandlerOption {
+	return func(h *logHandler) {
+		h.durationFormat = format
+	}
+}
+
+// WithBoolFormat sets how bool values are rendered,
+// BoolTrueFalse by default.
+func WithBoolFormat(format BoolFormat) LogHandlerOption {
+	return func(h *logHandler) {
+		h.boolFormat = format
+	}
+}
+
+// WithHexKeys renders the integer values of the given keys
+// in hexadecimal with a 0x prefix.
+func WithHexKeys(keys ...string) LogHandlerOption {
+	return func(h *logHandler) {
+		if h.hexKeys == nil {
+			h.hexKeys = make(map[string]struct{}, len(keys))
+		}
+		for _, key := range keys {
+			h.hexKeys[key] = struct{}{}
+		}
+	}
+}
+
+// WithSortAttrs sorts the attrs of each record by their group-qualified key.
+// The level, time and message keep their fixed positions,
+// and attrs added by WithAttrs keep their insertion order.
+func WithSortAttrs(sortAttrs bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.sortAttrs = sortAttrs
+	}
+}
+
+// WithSourceFormat sets how the source of a log call is rendered,
+// SourceFull by default.
+func WithSourceFormat(format SourceFormat) LogHandlerOption {
+	return func(h *logHandler) {
+		h.sourceFormat = format
+	}
+}
+
+// GroupStyle decides how the default log handler renders group attrs.
+type GroupStyle int
+
+const (
+	// GroupFlatten renders each attr of a group with a qualified key,
+	// e.g. `http.method=GET http.status=200`, which is the default.
+	GroupFlatten GroupStyle = iota
+	// GroupInline renders a group as a single attr,
+	// e.g. `http={method=GET status=200}`.
+	GroupInline
+)
+
+// WithGroupStyle sets how group attrs are rendered, GroupFlatten by default.
+// Groups opened by WithGroup are always flattened.
+func WithGroupStyle(style GroupStyle) LogHandlerOption {
+	return func(h *logHandler) {
+		h.groupStyle = style
+	}
+}
+
+// WithMaxValueLength truncates rendered attribute values longer than n runes.
+// The level, time and message are never truncated.
+func WithMaxValueLength(n int) LogHandlerOption {
+	return func(h *logHandler) {
+		h.maxValueLength = n
+	}
+}
+
+// WithMaxAttrs caps the number of attrs of each record to n,
+// replacing the dropped ones with a TruncatedKey group holding
+// the number of dropped attrs, e.g. `!TRUNCATED.dropped=3`.
+// Attrs added by WithAttrs are preformatted once, so they are
+// exempt from the limit and always written.
+func WithMaxAttrs(n int) LogHandlerOption {
+	return func(h *logHandler) {
+		h.maxAttrs = n
+	}
+}
+
+// MultilineStyle decides how the default log handler writes
+// messages containing newlines, e.g. stack traces and panics.
+type MultilineStyle string
+
+const (
+	// MultilineEscape writes the newlines and carriage returns of
+	// the message as `\n` and `\r`, keeping each record on a single line,
+	// which is the default.
+	MultilineEscape MultilineStyle = "escape"
+	// MultilineBlock writes the first line of the message in place,
+	// and the following lines after the attrs, each prefixed with a tab.
+	MultilineBlock MultilineStyle = "block"
+)
+
+// WithMultilineStyle sets how messages containing newlines are written,
+// MultilineEscape by default. The logfmt format always quotes them.
+func WithMultilineStyle(style MultilineStyle) LogHandlerOption {
+	return func(h *logHandler) {
+		h.multiline = MultilineStyle(strings.ToLower(string(style)))
+	}
+}
+
+// WithPadLevel pads the levels with spaces to the width of the longest
+// registered level, aligning the records in columns. The padding is written
+// after the color escape codes. The logfmt format never pads the levels.
+func WithPadLevel(padLevel bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.padLevel = padLevel
+	}
+}
+
+// ShortLevelLabels are the three-letter labels of the builtin levels,
+// for dense output, see WithLevelLabels.
+var ShortLevelLabels = map[Level]string{
+	LevelTrace: "TRC",
+	LevelDebug: "DBG",
+	LevelInfo:  "INF",
+	LevelWarn:  "WRN",
+	LevelError: "ERR",
+}
+
+// WithLevelLabels writes the labels of the levels instead of their names,
+// e.g. ShortLevelLabels or emojis, wrapped in the level colors. The levels
+// without a label keep their names. With WithPadLevel, the levels are
+// padded to the width of the longest label or unlabeled level name. The logfmt format ignores them.
+func WithLevelLabels(labels map[Level]string) LogHandlerOption {
+	return func(h *logHandler) {
+		h.levelLabels = labels
+		h.labelPad = &labelPad{}
+		for _, label := range labels {
+			h.labelPad.labelWidth = max(h.labelPad.labelWidth, utf8.RuneCountInString(label))
+		}
+	}
+}
+
+// WithTimeLocation converts the record times to loc before they are
+// formatted, e.g. time.UTC, instead of keeping the location of time.Now.
+func WithTimeLocation(loc *time.Location) LogHandlerOption {
+	return func(h *logHandler) {
+		h.timeLocation = loc
+	}
+}
+
+// WithDisableTime omits the time of the records, e.g. when the platform,
+// like journald or Docker, already timestamps the lines.
+func WithDisableTime(disableTime bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.disableTime = disableTime
+	}
+}
+
+// WithDisableLevel omits the level of the records,
+// e.g. when the platform already colors or prefixes the lines.
+func WithDisableLevel(disableLevel bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.disableLevel = disableLevel
+	}
+}
+
+// WithDedupKeys keeps only the last attr of each key within a record,
+// including the attrs added by WithAttrs. The same key in different
+// groups is distinct. It's a NewDedupHandler with DuplicateKeepLast,
+// which resolves all the attrs when the record is handled.
+func WithDedupKeys(dedupKeys bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.dedupKeys = dedupKeys
+	}
+}
+
+// WithExpandCollections renders the slices and arrays as groups of
+// indexed attrs, e.g. `tags.0=a tags.1=b`, and the maps as groups of
+// their members sorted by key, instead of their Go formatting.
+// Empty collections and byte slices are rendered as is.
+func WithExpandCollections(expand bool) LogHandlerOption {
+	return func(h *logHandler) {
+		h.expandCollections = expand
+	}
+}
+
+// NewLogfmtHandler creates a Handler that writes spec-compliant logfmt to w,
+// e.g. `time=2023-08-18T01:02:03.000Z level=INFO msg=hello key=value`.
+// It shares the options of NewLogHandler, but never writes colors.
+func NewLogfmtHandler(w io.Writer, opts *HandlerOptions, options ...LogHandlerOption) Handler {
+	h := newLogHandler(w, opts, true, options...)
+	h.logfmt = true
+	return h.dedup()
+}
+
+// logfmtTimeLayout is the time layout of the logfmt handler,
+// the same as the one of the slog text handler.
+const logfmtTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func NewLogHandler(w io.Writer, opts *HandlerOptions, disableColor bool, options ...LogHandlerOption) Handler {
+	return newLogHandler(w, opts, disableColor, options...).dedup()
+}
+
+func newLogHandler(w io.Writer, opts *HandlerOptions, disableColor bool, options ...LogHandlerOption) *logHandler {
 	if opts == nil {
 		opts = new(HandlerOptions)
 	}
-	return &logHandler{
+	h := &logHandler{
 		w:            w,
 		opts:         *opts,
 		mu:           new(sync.Mutex),
 		sep:          ".",
 		disableColor: disableColor,
 	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// dedup returns h, wrapped by a dedup handler keeping the last attr
+// of each key if WithDedupKeys is set.
+func (h *logHandler) dedup() Handler {
+	if h.dedupKeys {
+		return NewDedupHandler(h, DuplicateKeepLast)
+	}
+	return h
 }
 
 type logHandler struct {
@@ -47,49 +284,221 @@ type logHandler struct {
 	opts HandlerOptions
 	mu   *sync.Mutex
 
-	sep          string
-	groups       []string
-	attrBuffer   bytes.Buffer
-	disableColor bool
+	sep               string
+	groups            []string
+	attrBuffer        bytes.Buffer
+	colorAttrs        [len(levelColors)][]byte // attrBuffer with the keys colored per level color
+	disableColor      bool
+	logfmt            bool
+	formatValues      []FormatValueFunc
+	durationFormat    DurationFormat
+	boolFormat        BoolFormat
+	hexKeys           map[string]struct{}
+	sortAttrs         bool
+	sourceFormat      SourceFormat
+	groupStyle        GroupStyle
+	maxValueLength    int
+	maxAttrs          int
+	multiline         MultilineStyle
+	padLevel          bool
+	timeLocation      *time.Location
+	disableTime       bool
+	disableLevel      bool
+	dedupKeys         bool
+	expandCollections bool
+	levelLabels       map[Level]string
+	labelPad          *labelPad // shared among all clones of this handler
+}
+
+// labelPad is the width the levels are padded to with level labels.
+type labelPad struct {
+	labelWidth int // the width of the longest level label
+	// the width of the longest label or unlabeled name of the registered
+	// levels, computed again when the level registry is replaced
+	width atomic.Pointer[registryWidth]
+}
+
+type registryWidth struct {
+	registry *levelRegistry
+	width    int
+}
+
+// padWidth returns the width the levels are padded to with level labels.
+func (h *logHandler) padWidth() int {
+	r := levels.Load()
+	if w := h.labelPad.width.Load(); w != nil && w.registry == r {
+		return w.width
+	}
+	width := h.labelPad.labelWidth
+	for _, level := range r.sorted {
+		if _, ok := h.levelLabels[level]; !ok {
+			width = max(width, utf8.RuneCountInString(levelName(level)))
+		}
+	}
+	h.labelPad.width.Store(&registryWidth{registry: r, width: width})
+	return width
 }
 
 func (h *logHandler) clone() *logHandler {
 	return &logHandler{
-		mu:           h.mu, // mutex shared among all clones of this handler
-		w:            h.w,
-		opts:         h.opts,
-		sep:          h.sep,
-		groups:       slices.Clip(h.groups),
-		attrBuffer:   h.attrBuffer,
-		disableColor: h.disableColor,
+		mu:                h.mu, // mutex shared among all clones of this handler
+		w:                 h.w,
+		opts:              h.opts,
+		sep:               h.sep,
+		groups:            slices.Clip(h.groups),
+		attrBuffer:        *bytes.NewBuffer(slices.Clone(h.attrBuffer.Bytes())),
+		colorAttrs:        h.colorAttrs,
+		logfmt:            h.logfmt,
+		disableColor:      h.disableColor,
+		formatValues:      h.formatValues,
+		durationFormat:    h.durationFormat,
+		boolFormat:        h.boolFormat,
+		hexKeys:           h.hexKeys,
+		sortAttrs:         h.sortAttrs,
+		sourceFormat:      h.sourceFormat,
+		groupStyle:        h.groupStyle,
+		maxValueLength:    h.maxValueLength,
+		maxAttrs:          h.maxAttrs,
+		multiline:         h.multiline,
+		padLevel:          h.padLevel,
+		timeLocation:      h.timeLocation,
+		disableTime:       h.disableTime,
+		disableLevel:      h.disableLevel,
+		expandCollections: h.expandCollections,
+		levelLabels:       h.levelLabels,
+		labelPad:          h.labelPad,
 	}
 }
 
-func (h *logHandler) Enabled(_ context.Context, level Level) bool {
-	minLevel := LevelInfo
-	if h.opts.Level != nil {
-		minLevel = h.opts.Level.Level()
+// SourceAware is an optional interface for handlers to report
+// whether they read the PC of records. Logger skips looking up
+// the caller for handlers that report false, handlers that don't
+// implement it are assumed to need the PC.
+type SourceAware interface {
+	NeedsSource() bool
+}
+
+// needsSource reports whether h needs the PC of records.
+func needsSource(h Handler) bool {
+	if sa, ok := h.(SourceAware); ok {
+		return sa.NeedsSource()
 	}
-	return level >= minLevel
+	return true
+}
+
+// Flusher is an optional interface for handlers that buffer records,
+// Flush writes the buffered records out.
+type Flusher interface {
+	Flush() error
+}
+
+// optionsHandler is implemented by the handlers of this package
+// that can be rebuilt with modified HandlerOptions, see Logger.WithOptions.
+type optionsHandler interface {
+	withOptions(mutate func(opts *HandlerOptions)) (Handler, bool)
+}
+
+// handlerWithOptions rebuilds h with the HandlerOptions modified by mutate,
+// it reports false if h doesn't support it.
+func handlerWithOptions(h Handler, mutate func(opts *HandlerOptions)) (Handler, bool) {
+	oh, ok := h.(optionsHandler)
+	if !ok {
+		return nil, false
+	}
+	return oh.withOptions(mutate)
+}
+
+// withWriter returns a clone of h that writes to w.
+func (h *logHandler) withWriter(w io.Writer) *logHandler {
+	cp := h.clone()
+	cp.w = w
+	return cp
+}
+
+func (h *logHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	cp := h.clone()
+	mutate(&cp.opts)
+	return cp, true
+}
+
+func (h *logHandler) NeedsSource() bool {
+	return h.opts.AddSource
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+// bufferPool holds the buffers used to render records,
+// which are reused across records to avoid allocating them per call.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBufferSize is the capacity above which buffers are
+// not returned to the pool, so that one large record doesn't
+// keep its memory alive.
+const maxPooledBufferSize = 16 << 10
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
 }
 
 func (h *logHandler) Handle(_ context.Context, record Record) error {
-	var (
-		defBuf  bytes.Buffer
-		attrBuf bytes.Buffer
-	)
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	// the keys of the attrs are colored inline with the level color
+	var keyColor string
+	if !h.disableColor {
+		keyColor = levelColors[levelColorIndex(record.Level)]
+	}
 
 	logTime := record.Time.Round(0)
-	defAttrs := []Attr{
-		slog.Any(LevelKey, record.Level),        // level
-		slog.Time(TimeKey, logTime),             // time: strip monotonic to match Attr behavior
-		slog.String(MessageKey, record.Message), // message
+	if h.timeLocation != nil && !logTime.IsZero() {
+		logTime = logTime.In(h.timeLocation)
+	}
+	var defArray [3]Attr
+	defAttrs := defArray[:0]
+	if h.logfmt {
+		// all built-ins are ordinary key=value pairs, a zero time is omitted
+		if !logTime.IsZero() && !h.disableTime {
+			defAttrs = append(defAttrs, slog.Time(TimeKey, logTime))
+		}
+		if !h.disableLevel {
+			defAttrs = append(defAttrs, slog.Any(LevelKey, record.Level))
+		}
+		defAttrs = append(defAttrs, slog.String(MessageKey, record.Message))
+	} else {
+		if !h.disableLevel {
+			defAttrs = append(defAttrs, slog.Any(LevelKey, record.Level))
+		}
+		if !h.disableTime {
+			// time: strip monotonic to match Attr behavior
+			defAttrs = append(defAttrs, slog.Time(TimeKey, logTime))
+		}
+		defAttrs = append(defAttrs, slog.String(MessageKey, record.Message))
+	}
+	var block string
+	for _, a := range defAttrs {
+		if lines := h.addBuiltin(buf, record.Level, a); lines != "" {
+			block = lines
+		}
+	}
+	if !h.logfmt {
+		buf.WriteString(" ")
 	}
-	h.addAttrs(&defBuf, nil, defAttrs)
-	defBuf.WriteString(" ")
 
-	// source
-	if h.opts.AddSource {
+	// source, records constructed without a caller have no PC
+	if h.opts.AddSource && record.PC != 0 {
 		fs := runtime.CallersFrames([]uintptr{record.PC})
 		f, _ := fs.Next()
 		source := &slog.Source{
@@ -98,37 +507,58 @@ func (h *logHandler) Handle(_ context.Context, record Record) error {
 			Line:     f.Line,
 		}
 		sourceAttr := slog.Any(SourceKey, source)
-		h.addAttrs(&attrBuf, nil, []Attr{sourceAttr})
+		h.addAttrs(buf, nil, []Attr{sourceAttr}, keyColor, 0)
 	}
 
-	attrBuf.Write(h.attrBuffer.Bytes())
-	extraAttrs := make([]Attr, 0, record.NumAttrs())
+	if keyColor == "" {
+		buf.Write(h.attrBuffer.Bytes())
+	} else {
+		buf.Write(h.colorAttrs[levelColorIndex(record.Level)])
+	}
+	// most records have few attrs, which fit in a stack array
+	var attrArray [8]Attr
+	extraAttrs := attrArray[:0]
 	record.Attrs(func(attr slog.Attr) bool {
 		extraAttrs = append(extraAttrs, attr)
 		return true
 	})
-	h.addAttrs(&attrBuf, nil, extraAttrs)
-
-	attrBytes := attrBuf.Bytes()
-	if !h.disableColor {
-		slevel := SLevel(record.Level.String())
-		colorPrefix, colorSuffix := slevel.getColorPrefix(), slevel.getColorSuffix()
-		attrBytes = convertToColorKey(attrBytes, []byte(colorPrefix), []byte(colorSuffix))
+	if h.sortAttrs {
+		extraAttrs = sortAttrs(extraAttrs)
+	}
+	extraAttrs = truncateAttrs(extraAttrs, h.maxAttrs)
+	h.addAttrs(buf, h.groups, extraAttrs, keyColor, 0)
+	buf.WriteByte('\n')
+	if block != "" {
+		for _, line := range strings.Split(block, "\n") {
+			buf.WriteByte('\t')
+			buf.WriteString(strings.TrimSuffix(line, "\r"))
+			buf.WriteByte('\n')
+		}
 	}
 
-	defBuf.Write(attrBytes)
-	// TODO write record attr
-	defBuf.WriteByte('\n')
+	out := buf.Bytes()
+	if h.logfmt {
+		out = bytes.TrimPrefix(out, []byte{sepChar})
+	}
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	_, err := h.w.Write(defBuf.Bytes())
+	_, err := h.w.Write(out)
 	return err
 }
 
+// WithGroup opens the group name, or the nested groups of a name
+// joined by the separator, e.g. `a.b.c`, skipping the empty ones.
 func (h *logHandler) WithGroup(name string) Handler {
+	names := []string{name}
+	if h.sep != "" && strings.Contains(name, h.sep) {
+		names = slices.DeleteFunc(strings.Split(name, h.sep), func(name string) bool { return name == "" })
+	}
+	if len(names) == 0 || names[0] == "" {
+		return h
+	}
 	cp := h.clone()
-	cp.groups = append(cp.groups, name)
+	cp.groups = append(cp.groups, names...)
 	return cp
 }
 
@@ -136,33 +566,52 @@ func (h *logHandler) WithAttrs(attrs []Attr) Handler {
 	cp := h.clone()
 	groups := make([]string, len(cp.groups))
 	copy(groups[:], cp.groups[:])
-	cp.addAttrs(&cp.attrBuffer, groups, attrs)
+	cp.addAttrs(&cp.attrBuffer, groups, attrs, "", 0)
+	if !cp.disableColor {
+		// preformatted once per color, instead of coloring them per record
+		for index, color := range levelColors {
+			cp.colorAttrs[index] = convertToColorKey(cp.attrBuffer.Bytes(), []byte(color), []byte(colorReset))
+		}
+	}
 	return cp
 }
 
-func (h *logHandler) addAttrs(buf *bytes.Buffer, groups []string, attrs []Attr) {
-	groupPrefix := strings.Join(groups, ".")
+// addAttrs writes attrs to buf, wrapping their keys in keyColor if it isn't empty.
+func (h *logHandler) addAttrs(buf *bytes.Buffer, groups []string, attrs []Attr, keyColor string, depth int) {
+	groupPrefix := strings.Join(groups, h.sep)
 	for _, a := range attrs {
 		if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
-			a.Value = a.Value.Resolve()
+			a.Value = resolveValue(a.Value)
 			a = raFn(groups, a)
 		}
-		a.Value = a.Value.Resolve()
+		a.Value = resolveValue(a.Value)
+		if h.expandCollections {
+			a.Value = expandCollection(a.Value)
+		}
+		if a.Value.Kind() == KindGroup && depth >= maxAttrDepth {
+			a.Value = slog.StringValue(BadValue)
+		}
+		kind := a.Value.Kind()
 
 		// Elide empty Attrs.
-		if a.Key == "" {
+		if a.Key == "" && kind != KindGroup {
 			continue
 		}
 
-		kind := a.Value.Kind()
 		switch kind {
 		case KindAny:
 			// Special case: Source.
 			if src, ok := a.Value.Any().(*slog.Source); ok {
-				a.Value = slog.StringValue(fmt.Sprintf("%s:%d", src.File, src.Line))
+				a.Value = slog.StringValue(h.sourceFormat.Format(src))
 			}
 		case KindGroup:
 			as := a.Value.Group()
+			if len(as) > 0 && a.Key != "" && h.groupStyle == GroupInline {
+				buf.WriteString(" ")
+				writeKey(buf, groupPrefix, h.sep, a.Key, keyColor)
+				h.writeInlineGroup(buf, append(slices.Clip(groups), a.Key), as, keyColor, depth+1)
+				continue
+			}
 			// Output only non-empty groups.
 			if len(as) > 0 {
 				// Inline a group with an empty key.
@@ -171,54 +620,400 @@ func (h *logHandler) addAttrs(buf *bytes.Buffer, groups []string, attrs []Attr)
 				if a.Key != "" {
 					g2 = append(g2, a.Key)
 				}
-				h.addAttrs(buf, g2, as)
+				h.addAttrs(buf, g2, as, keyColor, depth+1)
 			}
 			continue
 		}
 
-		switch a.Key {
-		case LevelKey:
-			levelStr := a.Value.String()
-			if !h.disableColor {
-				slevel := SLevel(levelStr)
-				format := slevel.buildColorFormat("%s")
-				levelStr = fmt.Sprintf(format, levelStr)
-			}
-			buf.WriteString(levelStr)
-		case TimeKey:
-			buf.WriteString("[")
-			if kind == KindTime {
-				buf.WriteString(a.Value.Time().Format(time.RFC3339))
+		buf.WriteString(" ")
+		writeKey(buf, groupPrefix, h.sep, a.Key, keyColor)
+		h.writeValue(buf, a.Key, a.Value)
+	}
+}
+
+// maxAttrDepth bounds the LogValue calls resolving a value, and the
+// nesting of the groups, like slog does, so that a LogValuer resolving
+// to itself can't loop forever. BadValue is written instead.
+const maxAttrDepth = 100
+
+// resolveValue is like Value.Resolve, but returns BadValue if v doesn't
+// resolve within maxAttrDepth LogValue calls.
+func resolveValue(v Value) (rv Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			rv = slog.AnyValue(fmt.Errorf("LogValue panicked: %v", r))
+		}
+	}()
+	for i := 0; i < maxAttrDepth; i++ {
+		if v.Kind() != KindLogValuer {
+			return v
+		}
+		v = v.LogValuer().LogValue()
+	}
+	if v.Kind() == KindLogValuer {
+		return slog.StringValue(BadValue)
+	}
+	return v
+}
+
+// expandCollection returns the non-empty slices, arrays and maps of v
+// as groups, see WithExpandCollections, and the other values as is.
+func expandCollection(v Value) Value {
+	if v.Kind() != KindAny {
+		return v
+	}
+	rv := reflect.ValueOf(v.Any())
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v
+		}
+		attrs := make([]Attr, rv.Len())
+		for i := range attrs {
+			attrs[i] = slog.Any(strconv.Itoa(i), rv.Index(i).Interface())
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Map:
+		if rv.Len() == 0 {
+			return v
+		}
+		attrs := make([]Attr, 0, rv.Len())
+		for iter := rv.MapRange(); iter.Next(); {
+			attrs = append(attrs, slog.Any(fmt.Sprint(iter.Key().Interface()), iter.Value().Interface()))
+		}
+		slices.SortFunc(attrs, func(a, b Attr) int { return strings.Compare(a.Key, b.Key) })
+		return slog.GroupValue(attrs...)
+	}
+	return v
+}
+
+// writeKey writes the key qualified by groupPrefix followed by `=`,
+// wrapped in keyColor if it isn't empty.
+func writeKey(buf *bytes.Buffer, groupPrefix, sep, key, keyColor string) {
+	if keyColor != "" {
+		buf.WriteString(keyColor)
+	}
+	if groupPrefix != "" {
+		buf.WriteString(groupPrefix)
+		buf.WriteString(sep)
+	}
+	buf.WriteString(key)
+	if keyColor != "" {
+		buf.WriteString(colorReset)
+	}
+	buf.WriteByte(splitChar)
+}
+
+// addBuiltin writes the built-in attr a of the level, time or message.
+// ReplaceAttr is applied first, and its result is rendered in the slot
+// of the original key, so the replaced value keeps the position and the
+// level color of the record while an empty key elides the attr.
+// The default format doesn't write the keys of the built-ins,
+// the logfmt format writes the replaced key.
+// It returns the following lines of a multi-line message
+// to write after the attrs in the MultilineBlock style.
+func (h *logHandler) addBuiltin(buf *bytes.Buffer, level Level, a Attr) string {
+	builtin := a.Key
+	if raFn := h.opts.ReplaceAttr; raFn != nil {
+		a = raFn(nil, a)
+	}
+	a.Value = resolveValue(a.Value)
+	if a.Key == "" {
+		return ""
+	}
+
+	if h.logfmt {
+		if builtin == TimeKey && a.Value.Kind() == KindTime {
+			a.Value = slog.StringValue(a.Value.Time().Format(logfmtTimeLayout))
+		}
+		if lvl, ok := a.Value.Any().(Level); ok && builtin == LevelKey {
+			a.Value = slog.StringValue(levelName(lvl))
+		}
+		if a.Value.Kind() == KindGroup {
+			// ReplaceAttr is never applied to groups
+			h.addAttrs(buf, nil, []Attr{a}, "", 0)
+			return ""
+		}
+		buf.WriteString(" ")
+		buf.WriteString(a.Key)
+		buf.WriteString("=")
+		h.writeValue(buf, a.Key, a.Value)
+		return ""
+	}
+
+	switch builtin {
+	case LevelKey:
+		if !h.disableColor {
+			buf.WriteString(levelColor(level))
+		}
+		var s string
+		if lvl, ok := a.Value.Any().(Level); ok {
+			if label, ok := h.levelLabels[lvl]; ok {
+				s = label
 			} else {
-				buf.WriteString(a.Value.String())
+				s = levelName(lvl)
 			}
-			buf.WriteString("]")
-		case MessageKey:
-			buf.WriteString(" ")
+		} else {
+			s = a.Value.String()
+		}
+		buf.WriteString(s)
+		if !h.disableColor {
+			buf.WriteString(colorReset)
+		}
+		if h.padLevel {
+			width := int(levelWidth.Load())
+			if h.levelLabels != nil {
+				width = h.padWidth()
+			}
+			for n := width - utf8.RuneCountInString(s); n > 0; n-- {
+				buf.WriteByte(' ')
+			}
+		}
+	case TimeKey:
+		buf.WriteString("[")
+		if a.Value.Kind() == KindTime {
+			buf.Write(a.Value.Time().AppendFormat(buf.AvailableBuffer(), time.RFC3339))
+		} else {
 			buf.WriteString(a.Value.String())
-		default:
+		}
+		buf.WriteString("]")
+	case MessageKey:
+		// the level and time may be omitted
+		if buf.Len() > 0 {
 			buf.WriteString(" ")
-			if groupPrefix != "" {
-				buf.WriteString(groupPrefix)
-				buf.WriteString(h.sep)
+		}
+		msg := a.Value.String()
+		if !strings.ContainsAny(msg, "\r\n") {
+			buf.WriteString(msg)
+			break
+		}
+		if h.multiline == MultilineBlock {
+			first, rest, _ := strings.Cut(strings.TrimRight(msg, "\r\n"), "\n")
+			buf.WriteString(strings.TrimSuffix(first, "\r"))
+			return rest
+		}
+		messageEscaper.WriteString(buf, msg)
+	}
+	return ""
+}
+
+// messageEscaper escapes the newlines of messages in the MultilineEscape style.
+var messageEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`)
+
+// writeInlineGroup writes the attrs of a group as `{k1=v1 k2={k3=v3}}`.
+func (h *logHandler) writeInlineGroup(buf *bytes.Buffer, groups []string, attrs []Attr, keyColor string, depth int) {
+	buf.WriteByte('{')
+	first := true
+	for _, a := range attrs {
+		if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+			a.Value = resolveValue(a.Value)
+			a = raFn(groups, a)
+		}
+		a.Value = resolveValue(a.Value)
+		if h.expandCollections {
+			a.Value = expandCollection(a.Value)
+		}
+		if a.Value.Kind() == KindGroup && depth >= maxAttrDepth {
+			a.Value = slog.StringValue(BadValue)
+		}
+		if a.Key == "" {
+			continue
+		}
+
+		if !first {
+			buf.WriteByte(sepChar)
+		}
+		first = false
+		writeKey(buf, "", "", a.Key, keyColor)
+		if a.Value.Kind() == KindGroup {
+			h.writeInlineGroup(buf, append(slices.Clip(groups), a.Key), a.Value.Group(), keyColor, depth+1)
+			continue
+		}
+		h.writeValue(buf, a.Key, a.Value)
+	}
+	buf.WriteByte('}')
+}
+
+// timeStringLayout is the layout of time.Time.String without the monotonic clock.
+const timeStringLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// writeValue writes the rendered value to buf, quoted if needed.
+// Values without formatting options are appended directly,
+// which avoids the allocations of Value.String.
+func (h *logHandler) writeValue(buf *bytes.Buffer, key string, v Value) {
+	if len(h.formatValues) == 0 && h.maxValueLength <= 0 {
+		switch v.Kind() {
+		case KindInt64:
+			if _, ok := h.hexKeys[key]; !ok {
+				buf.Write(strconv.AppendInt(buf.AvailableBuffer(), v.Int64(), 10))
+				return
+			}
+		case KindUint64:
+			if _, ok := h.hexKeys[key]; !ok {
+				buf.Write(strconv.AppendUint(buf.AvailableBuffer(), v.Uint64(), 10))
+				return
+			}
+		case KindFloat64:
+			buf.Write(strconv.AppendFloat(buf.AvailableBuffer(), v.Float64(), 'g', -1, 64))
+			return
+		case KindBool:
+			if h.boolFormat == nil {
+				buf.Write(strconv.AppendBool(buf.AvailableBuffer(), v.Bool()))
+				return
+			}
+		case KindTime:
+			// the time layout only renders printable ASCII without quotes or
+			// backslashes, which always needs quoting because of the spaces
+			buf.WriteByte(quoteChar)
+			buf.Write(v.Time().AppendFormat(buf.AvailableBuffer(), timeStringLayout))
+			buf.WriteByte(quoteChar)
+			return
+		case KindDuration:
+			writeString(buf, h.formatDuration(v.Duration()))
+			return
+		}
+	}
+	writeString(buf, truncateString(h.formatValue(key, v), h.maxValueLength))
+}
+
+// writeString writes str to buf, quoted if needed.
+func writeString(buf *bytes.Buffer, str string) {
+	if needsQuoting(str) {
+		buf.Write(strconv.AppendQuote(buf.AvailableBuffer(), str))
+		return
+	}
+	buf.WriteString(str)
+}
+
+// formatDuration renders d with the DurationFormat of h.
+func (h *logHandler) formatDuration(d time.Duration) string {
+	if h.durationFormat != nil {
+		return h.durationFormat(d)
+	}
+	return d.String()
+}
+
+func (h *logHandler) formatValue(key string, v Value) string {
+	for _, fn := range h.formatValues {
+		if str, ok := fn(key, v); ok {
+			return str
+		}
+	}
+	switch v.Kind() {
+	case KindDuration:
+		return h.formatDuration(v.Duration())
+	case KindBool:
+		if h.boolFormat != nil {
+			return h.boolFormat(v.Bool())
+		}
+	case KindInt64:
+		if _, ok := h.hexKeys[key]; ok {
+			if i := v.Int64(); i < 0 {
+				return "-0x" + strconv.FormatUint(uint64(-i), 16)
 			}
-			str := a.Value.String()
-			if needsQuoting(str) {
-				str = strconv.Quote(str)
+			return "0x" + strconv.FormatInt(v.Int64(), 16)
+		}
+	case KindUint64:
+		if _, ok := h.hexKeys[key]; ok {
+			return "0x" + strconv.FormatUint(v.Uint64(), 16)
+		}
+	}
+	return v.String()
+}
+
+// sortAttrs returns attrs stably sorted by key, descending into groups
+// and inlining groups with an empty key.
+func sortAttrs(attrs []Attr) []Attr {
+	return sortAttrsDepth(attrs, 0)
+}
+
+func sortAttrsDepth(attrs []Attr, depth int) []Attr {
+	sorted := make([]Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = resolveValue(a.Value)
+		if a.Value.Kind() == KindGroup && depth >= maxAttrDepth {
+			a.Value = slog.StringValue(BadValue)
+		}
+		if a.Value.Kind() == KindGroup {
+			if a.Key == "" {
+				sorted = append(sorted, sortAttrsDepth(a.Value.Group(), depth+1)...)
+				continue
 			}
-			buf.WriteString(a.Key)
-			buf.WriteString("=")
-			buf.WriteString(str)
+			a.Value = slog.GroupValue(sortAttrsDepth(a.Value.Group(), depth+1)...)
 		}
+		sorted = append(sorted, a)
 	}
+	slices.SortStableFunc(sorted, func(a, b Attr) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return sorted
+}
+
+// NewSortHandler returns a Handler that passes the attrs of each record
+// to h sorted by key, see WithSortAttrs for the default log handler.
+// Attrs added by WithAttrs keep their insertion order.
+func NewSortHandler(h Handler) Handler {
+	return &sortHandler{handler: h}
+}
+
+type sortHandler struct {
+	handler Handler
+}
+
+func (h *sortHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *sortHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *sortHandler) Handle(ctx context.Context, record Record) error {
+	if record.NumAttrs() == 0 {
+		return h.handler.Handle(ctx, record)
+	}
+	attrs := make([]Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	r := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	r.AddAttrs(sortAttrs(attrs)...)
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *sortHandler) WithAttrs(attrs []Attr) Handler {
+	return &sortHandler{handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *sortHandler) WithGroup(name string) Handler {
+	return &sortHandler{handler: h.handler.WithGroup(name)}
 }
 
 func NewMultiHandler(handlers ...Handler) Handler {
 	return &multiHandler{handlers: handlers}
 }
 
+// NewConcurrentMultiHandler is like NewMultiHandler, but dispatches each record
+// to the handlers in parallel using at most concurrency goroutines,
+// so a slow handler does not delay the others.
+// Each handler receives its own clone of the record.
+func NewConcurrentMultiHandler(concurrency int, handlers ...Handler) Handler {
+	return &multiHandler{handlers: handlers, concurrency: concurrency}
+}
+
 type multiHandler struct {
-	handlers []Handler
+	handlers    []Handler
+	concurrency int
+}
+
+func (h *multiHandler) NeedsSource() bool {
+	for _, handler := range h.handlers {
+		if needsSource(handler) {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *multiHandler) Enabled(ctx context.Context, level Level) bool {
@@ -230,28 +1025,111 @@ func (h *multiHandler) Enabled(ctx context.Context, level Level) bool {
 	return false
 }
 
+// Handle passes record to the handlers enabled for its level,
+// so the disabled ones don't format it.
 func (h *multiHandler) Handle(ctx context.Context, record Record) error {
-	var errs []error
+	var enabledArray [4]Handler
+	enabled := enabledArray[:0]
 	for _, handler := range h.handlers {
-		if err := handler.Handle(ctx, record); err != nil {
+		if handler.Enabled(ctx, record.Level) {
+			enabled = append(enabled, handler)
+		}
+	}
+	if h.concurrency > 1 && len(enabled) > 1 {
+		return h.handleConcurrent(ctx, enabled, record)
+	}
+
+	var errs []error
+	for _, handler := range enabled {
+		if err := safeHandle(ctx, handler, record); err != nil {
 			errs = append(errs, err)
 		}
 	}
 	return errors.Join(errs...)
 }
 
+func (h *multiHandler) handleConcurrent(ctx context.Context, handlers []Handler, record Record) error {
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, h.concurrency)
+		errs = make([]error, len(handlers))
+	)
+	for index, handler := range handlers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(index int, handler Handler, record Record) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[index] = safeHandle(ctx, handler, record)
+		}(index, handler, record.Clone())
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Flush implements Flusher, and flushes the handlers implementing it.
+func (h *multiHandler) Flush() error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if f, ok := handler.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements io.Closer, and closes the handlers implementing it.
+func (h *multiHandler) Close() error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if c, ok := handler.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (h *multiHandler) WithAttrs(attrs []Attr) Handler {
-	cp := &multiHandler{handlers: make([]Handler, len(h.handlers))}
+	cp := &multiHandler{handlers: make([]Handler, len(h.handlers)), concurrency: h.concurrency}
 	for index, handler := range h.handlers {
-		cp.handlers[index] = handler.WithAttrs(attrs)
+		cp.handlers[index] = safeDerive(handler, func() Handler { return handler.WithAttrs(attrs) })
 	}
 	return cp
 }
 
 func (h *multiHandler) WithGroup(name string) Handler {
-	cp := &multiHandler{handlers: make([]Handler, len(h.handlers))}
+	cp := &multiHandler{handlers: make([]Handler, len(h.handlers)), concurrency: h.concurrency}
 	for index, handler := range h.handlers {
-		cp.handlers[index] = handler.WithGroup(name)
+		cp.handlers[index] = safeDerive(handler, func() Handler { return handler.WithGroup(name) })
 	}
 	return cp
 }
+
+// safeHandle calls handler.Handle, converting a panic into an error
+// so that one misbehaving handler doesn't affect the others.
+func safeHandle(ctx context.Context, handler Handler, record Record) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return handler.Handle(ctx, record)
+}
+
+// safeDerive returns the handler derived by fn, or the original handler
+// if fn panics, reporting the panic to the func set by SetErrorHandler.
+func safeDerive(handler Handler, fn func() Handler) (derived Handler) {
+	defer func() {
+		if r := recover(); r != nil {
+			reportError(fmt.Errorf("handler panic: %v", r))
+			derived = handler
+		}
+	}()
+	return fn()
+}
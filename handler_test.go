@@ -0,0 +1,732 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func TestLogfmtHandler_slogtest(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogfmtHandler(&buf, nil)
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte{'\n'}) {
+			m, err := parseLogfmt(string(line))
+			if err != nil {
+				t.Fatalf("parse %q: %v", line, err)
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+// parseLogfmt parses a logfmt line into a map,
+// nesting the dot-separated keys of groups.
+func parseLogfmt(line string) (map[string]any, error) {
+	m := make(map[string]any)
+	for len(line) > 0 {
+		index := strings.IndexByte(line, '=')
+		if index == -1 {
+			return nil, fmt.Errorf("missing = in %q", line)
+		}
+		key := line[:index]
+		line = line[index+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, err
+			}
+			line = line[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value, line, _ = strings.Cut(line, " ")
+		}
+		line = strings.TrimPrefix(line, " ")
+
+		keys := strings.Split(key, ".")
+		cur := m
+		for _, k := range keys[:len(keys)-1] {
+			sub, ok := cur[k].(map[string]any)
+			if !ok {
+				sub = make(map[string]any)
+				cur[k] = sub
+			}
+			cur = sub
+		}
+		cur[keys[len(keys)-1]] = value
+	}
+	return m, nil
+}
+
+func TestLogHandler_ZeroPC(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, &HandlerOptions{AddSource: true}, true)
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), LevelInfo, "msg", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), SourceKey+"=") {
+		t.Errorf("Handle() = %q, want no source", buf.String())
+	}
+}
+
+// pcHandler records the PC of the last record.
+type pcHandler struct {
+	Handler
+	needsSource bool
+	pc          *uintptr
+}
+
+func (h pcHandler) NeedsSource() bool { return h.needsSource }
+
+func (h pcHandler) Handle(_ context.Context, r Record) error {
+	*h.pc = r.PC
+	return nil
+}
+
+func TestLogger_NeedsSource(t *testing.T) {
+	var pc uintptr
+	with := pcHandler{Handler: slog.NewTextHandler(io.Discard, nil), needsSource: true, pc: &pc}
+	without := pcHandler{Handler: slog.NewTextHandler(io.Discard, nil), pc: &pc}
+	tests := []struct {
+		name    string
+		handler Handler
+		want    bool
+	}{
+		{"source", with, true},
+		{"no source", without, false},
+		{"log handler", NewLogHandler(io.Discard, nil, true), false},
+		{"log handler with AddSource", NewLogHandler(io.Discard, &HandlerOptions{AddSource: true}, true), true},
+		{"slog handler", slog.NewTextHandler(io.Discard, nil), true},
+		{"multi", NewMultiHandler(without, without), false},
+		{"multi with source", NewMultiHandler(without, with), true},
+		{"dedup", NewDedupHandler(without, DuplicateKeepLast), false},
+		{"dedup with source", NewDedupHandler(with, DuplicateKeepLast), true},
+		{"context level", NewContextLevelHandler(without), false},
+		{"context level with source", NewContextLevelHandler(with), true},
+	}
+	for _, tt := range tests {
+		if got := needsSource(tt.handler); got != tt.want {
+			t.Errorf("%s: needsSource() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	// the Logger only looks up the caller for the handlers needing it
+	for _, h := range []Handler{without, NewMultiHandler(without), NewDedupHandler(without, DuplicateKeepLast)} {
+		pc = 1
+		NewLogger(h).Info("msg")
+		if pc != 0 {
+			t.Errorf("%T: PC = %d, want 0", h, pc)
+		}
+	}
+	NewLogger(NewContextLevelHandler(with)).LogAttrs(LevelInfo, "msg")
+	if pc == 0 {
+		t.Error("PC = 0, want the caller")
+	}
+}
+
+func TestLogHandler_ReplaceAttrBuiltins(t *testing.T) {
+	replaceAttr := func(groups []string, a Attr) Attr {
+		switch a.Key {
+		case LevelKey:
+			return slog.String("severity", "WARNING")
+		case MessageKey:
+			a.Value = slog.StringValue(strings.ToUpper(a.Value.String()))
+		case TimeKey:
+			return Attr{}
+		}
+		return a
+	}
+	record := slog.NewRecord(time.Now(), LevelWarn, "hello", 0)
+	record.AddAttrs(slog.Int("a", 1))
+
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, &HandlerOptions{ReplaceAttr: replaceAttr}, true)
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "WARNING HELLO  a=1\n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	h = NewLogfmtHandler(&buf, &HandlerOptions{ReplaceAttr: replaceAttr})
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "severity=WARNING msg=HELLO a=1\n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+}
+
+func TestLogHandler_ColorKeys(t *testing.T) {
+	for _, level := range []Level{LevelDebug, LevelInfo, LevelInfo + 2, LevelWarn, LevelError + 4} {
+		for _, style := range []GroupStyle{GroupFlatten, GroupInline} {
+			var plain, colored bytes.Buffer
+			for _, l := range []*Logger{
+				NewLogger(NewLogHandler(&plain, &HandlerOptions{Level: LevelDebug}, true, WithGroupStyle(style))),
+				NewLogger(NewLogHandler(&colored, &HandlerOptions{Level: LevelDebug}, false, WithGroupStyle(style))),
+			} {
+				l.With("w", 1, slog.Group("wg", "a", "x y")).WithGroup("g").
+					Log(level, "msg", "b", true, slog.Group("h", "c", 1, slog.Group("i", "d", 2)))
+			}
+
+			_, plainAttrs, _ := strings.Cut(plain.String(), " msg")
+			_, coloredAttrs, _ := strings.Cut(colored.String(), " msg")
+			color := SLevel(level.String()).getColorPrefix()
+			want := convertToColorKey([]byte(plainAttrs), []byte(color), []byte(colorReset))
+			if coloredAttrs != string(want) {
+				t.Errorf("Handle(%v, %v) = %q, want %q", level, style, coloredAttrs, want)
+			}
+		}
+	}
+}
+
+func TestLogHandler_writeValue(t *testing.T) {
+	values := []Value{
+		slog.IntValue(0),
+		slog.Int64Value(math.MinInt64),
+		slog.Uint64Value(math.MaxUint64),
+		slog.Float64Value(0.1),
+		slog.Float64Value(1e21),
+		slog.Float64Value(-1.5),
+		slog.Float64Value(math.NaN()),
+		slog.Float64Value(math.Inf(-1)),
+		slog.BoolValue(true),
+		slog.BoolValue(false),
+		slog.DurationValue(1500 * time.Millisecond),
+		slog.DurationValue(1500 * time.Nanosecond),
+		slog.TimeValue(time.Date(2023, 8, 18, 1, 2, 3, 4, time.UTC)),
+		slog.TimeValue(time.Date(2023, 8, 18, 1, 2, 3, 0, time.FixedZone("CST", 8*3600))),
+		slog.TimeValue(time.Now()),
+		slog.StringValue("a b"),
+	}
+	h := NewLogHandler(io.Discard, nil, true).(*logHandler)
+	for _, v := range values {
+		want := v.String()
+		if needsQuoting(want) {
+			want = strconv.Quote(want)
+		}
+		var buf bytes.Buffer
+		h.writeValue(&buf, "key", v)
+		if got := buf.String(); got != want {
+			t.Errorf("writeValue(%v) = %s, want %s", v.Kind(), got, want)
+		}
+	}
+}
+
+func TestLogHandler_writeValueDuration(t *testing.T) {
+	durations := []time.Duration{0, 1500 * time.Millisecond, -1500 * time.Millisecond, 1500 * time.Nanosecond, math.MinInt64}
+	for _, format := range []DurationFormat{nil, DurationString, DurationMillis, DurationSeconds} {
+		fast := NewLogHandler(io.Discard, nil, true, WithDurationFormat(format)).(*logHandler)
+		// a value length limit disables the fast path
+		slow := NewLogHandler(io.Discard, nil, true, WithDurationFormat(format), WithMaxValueLength(math.MaxInt)).(*logHandler)
+		for _, d := range durations {
+			var got, want bytes.Buffer
+			fast.writeValue(&got, "key", slog.DurationValue(d))
+			slow.writeValue(&want, "key", slog.DurationValue(d))
+			if got.String() != want.String() {
+				t.Errorf("writeValue(%v) = %s, want %s", d, got.String(), want.String())
+			}
+		}
+	}
+}
+
+func TestLogHandler_DurationFormat(t *testing.T) {
+	tests := []struct {
+		format DurationFormat
+		d      time.Duration
+		want   string
+	}{
+		{nil, 1500 * time.Millisecond, "1.5s"},
+		{DurationString, 1500 * time.Millisecond, "1.5s"},
+		{DurationString, 0, "0s"},
+		{DurationMillis, 1500 * time.Millisecond, "1500"},
+		{DurationMillis, 1500 * time.Microsecond, "1"},
+		{DurationMillis, -1500 * time.Millisecond, "-1500"},
+		{DurationMillis, 0, "0"},
+		{DurationSeconds, 1500 * time.Millisecond, "1.5"},
+		{DurationSeconds, 1500 * time.Nanosecond, "0.0000015"},
+		{DurationSeconds, -2 * time.Second, "-2"},
+		{func(d time.Duration) string { return d.Truncate(time.Second).String() }, 1500 * time.Millisecond, "1s"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		NewLogger(NewLogfmtHandler(&buf, nil, WithDisableTime(true), WithDurationFormat(tt.format))).
+			Info("msg", "d", tt.d, slog.Group("g", "d", tt.d))
+		if want := fmt.Sprintf(" d=%s g.d=%s\n", tt.want, tt.want); !strings.HasSuffix(buf.String(), want) {
+			t.Errorf("Handle(%v) = %q, want suffix %q", tt.d, buf.String(), want)
+		}
+	}
+}
+
+func TestLogHandler_BoolFormat(t *testing.T) {
+	tests := []struct {
+		format              BoolFormat
+		wantTrue, wantFalse string
+	}{
+		{nil, "true", "false"},
+		{BoolTrueFalse, "true", "false"},
+		{BoolOneZero, "1", "0"},
+		{BoolYesNo, "yes", "no"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		NewLogger(NewLogfmtHandler(&buf, nil, WithDisableTime(true), WithBoolFormat(tt.format))).
+			Info("msg", "t", true, "f", false, "s", "true")
+		if want := fmt.Sprintf(" t=%s f=%s s=true\n", tt.wantTrue, tt.wantFalse); !strings.HasSuffix(buf.String(), want) {
+			t.Errorf("Handle() = %q, want suffix %q", buf.String(), want)
+		}
+	}
+}
+
+func TestLogHandler_HexKeys(t *testing.T) {
+	var buf bytes.Buffer
+	NewLogger(NewLogfmtHandler(&buf, nil, WithDisableTime(true), WithHexKeys("id", "flags", "min"))).Info("msg",
+		"id", 255,
+		"flags", uint64(math.MaxUint64),
+		"min", math.MinInt64,
+		"neg", -255,
+		"other", 255,
+		slog.Group("g", "id", -16),
+		"id", "not an int",
+	)
+	want := " id=0xff flags=0xffffffffffffffff min=-0x8000000000000000 neg=-255 other=255 g.id=-0x10 id=\"not an int\"\n"
+	if got := buf.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("Handle() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestLogHandler_MaxAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewLogHandler(&buf, nil, true, WithMaxAttrs(2)))
+	// attrs added by With are exempt from the limit
+	l.With("w1", 1, "w2", 2, "w3", 3).Info("msg", "a", 1, "b", 2, "c", 3, "d", 4)
+
+	want := " w1=1 w2=2 w3=3 a=1 b=2 " + TruncatedKey + ".dropped=2\n"
+	if got := buf.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("Handle() = %q, want suffix %q", got, want)
+	}
+}
+
+func TestLogHandler_WithGroupPath(t *testing.T) {
+	tests := []struct {
+		sep    string
+		group  string
+		want   string
+		groups []string
+	}{
+		{".", "a.b.c", " a.b.c.k=1\n", []string{"a", "b", "c"}},
+		{".", "a..b.", " a.b.k=1\n", []string{"a", "b"}},
+		{".", ".", " k=1\n", nil},
+		{"/", "a/b", " a/b/k=1\n", []string{"a", "b"}},
+		{"/", "a.b", " a.b/k=1\n", []string{"a.b"}},
+	}
+	for _, tt := range tests {
+		var (
+			buf    bytes.Buffer
+			groups []string
+		)
+		opts := &HandlerOptions{ReplaceAttr: func(g []string, a Attr) Attr {
+			if a.Key == "k" {
+				groups = g
+			}
+			return a
+		}}
+		NewLogger(NewLogHandler(&buf, opts, true, WithSeparator(tt.sep))).WithGroup(tt.group).Info("msg", "k", 1)
+		if got := buf.String(); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("WithGroup(%q) = %q, want suffix %q", tt.group, got, tt.want)
+		}
+		if !slices.Equal(groups, tt.groups) {
+			t.Errorf("WithGroup(%q): ReplaceAttr groups = %q, want %q", tt.group, groups, tt.groups)
+		}
+	}
+}
+
+func TestLogHandler_Multiline(t *testing.T) {
+	msg := "panic: boom\r\n\ngoroutine 1 [running]:\nmain.main()\n"
+	tests := []struct {
+		style MultilineStyle
+		want  string
+	}{
+		{"", ` panic: boom\r\n\ngoroutine 1 [running]:\nmain.main()\n  a=1` + "\n"},
+		{MultilineBlock, " panic: boom  a=1\n\t\n\tgoroutine 1 [running]:\n\tmain.main()\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		NewLogger(NewLogHandler(&buf, nil, true, WithMultilineStyle(tt.style))).Info(msg, "a", 1)
+		if got := buf.String(); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("%q: Handle() = %q, want suffix %q", tt.style, got, tt.want)
+		}
+	}
+}
+
+func TestLogHandler_PadLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(NewLogHandler(&buf, nil, false, WithPadLevel(true)))
+	logger.Info("a")
+	logger.Error("b")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want := "\x1b[36mINFO\x1b[0m ["; !strings.HasPrefix(lines[0], want) {
+		t.Errorf("Handle() = %q, want prefix %q", lines[0], want)
+	}
+	if want := "\x1b[31mERROR\x1b[0m["; !strings.HasPrefix(lines[1], want) {
+		t.Errorf("Handle() = %q, want prefix %q", lines[1], want)
+	}
+
+	width, registry := levelWidth.Load(), levels.Load()
+	t.Cleanup(func() {
+		levels.Store(registry)
+		levelWidth.Store(width)
+	})
+	RegisterLevel("fatal", LevelError+4)
+	buf.Reset()
+	NewLogger(NewLogHandler(&buf, nil, true, WithPadLevel(true))).Warn("c")
+	if want := "WARN   ["; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("Handle() = %q, want prefix %q", buf.String(), want)
+	}
+}
+
+func TestLogHandler_TimeLocation(t *testing.T) {
+	ts := time.Date(2023, 8, 18, 9, 2, 3, 0, time.FixedZone("CST", 8*3600))
+	record := slog.NewRecord(ts, LevelInfo, "msg", 0)
+
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, nil, true, WithTimeLocation(time.UTC))
+	if err := h.Handle(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	if want := "INFO[2023-08-18T01:02:03Z] msg"; !strings.HasPrefix(buf.String(), want) {
+		t.Errorf("Handle() = %q, want prefix %q", buf.String(), want)
+	}
+}
+
+func TestLogHandler_DisableTime(t *testing.T) {
+	ts := time.Date(2023, 8, 18, 9, 2, 3, 0, time.UTC)
+	tests := []struct {
+		options []LogHandlerOption
+		logfmt  bool
+		want    string
+	}{
+		{options: []LogHandlerOption{WithDisableTime(true)}, want: "INFO msg  k=v\n"},
+		{options: []LogHandlerOption{WithDisableLevel(true)}, want: "[2023-08-18T09:02:03Z] msg  k=v\n"},
+		{options: []LogHandlerOption{WithDisableTime(true), WithDisableLevel(true)}, want: "msg  k=v\n"},
+		{options: []LogHandlerOption{WithDisableTime(true)}, logfmt: true, want: "level=INFO msg=msg k=v\n"},
+		{options: []LogHandlerOption{WithDisableTime(true), WithDisableLevel(true)}, logfmt: true, want: "msg=msg k=v\n"},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		h := NewLogHandler(&buf, nil, true, tt.options...)
+		if tt.logfmt {
+			h = NewLogfmtHandler(&buf, nil, tt.options...)
+		}
+		record := slog.NewRecord(ts, LevelInfo, "msg", 0)
+		record.AddAttrs(slog.String("k", "v"))
+		if err := h.Handle(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("#%d: Handle() = %q, want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestLogHandler_DedupKeys(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogfmtHandler(&buf, nil, WithDisableTime(true), WithDedupKeys(true))
+	l := NewLogger(h).With("a", 1, "b", 1).WithGroup("g").With("a", 1)
+	l.Info("msg", "a", 2, "c", 3, "a", 4)
+	if got, want := buf.String(), "level=INFO msg=msg a=1 b=1 g.a=4 g.c=3\n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	NewLogger(h).With("a", 1).Info("msg", "a", 2)
+	if got, want := buf.String(), "level=INFO msg=msg a=2\n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+}
+
+func TestLogHandler_ExpandCollections(t *testing.T) {
+	tests := []struct {
+		options []LogHandlerOption
+		want    string
+	}{
+		{want: `tags="[a b]" m="map[x:1 y:[2 3]]" b="[104 105]" e="[]"`},
+		{options: []LogHandlerOption{WithExpandCollections(true)}, want: `tags.0=a tags.1=b m.x=1 m.y.0=2 m.y.1=3 b="[104 105]" e="[]"`},
+		{options: []LogHandlerOption{WithExpandCollections(true), WithGroupStyle(GroupInline)}, want: `tags={0=a 1=b} m={x=1 y={0=2 1=3}} b="[104 105]" e="[]"`},
+	}
+	for i, tt := range tests {
+		var buf bytes.Buffer
+		h := NewLogfmtHandler(&buf, nil, append(tt.options, WithDisableTime(true), WithDisableLevel(true))...)
+		NewLogger(h).Info("m",
+			"tags", []string{"a", "b"},
+			"m", map[string]any{"y": [2]int{2, 3}, "x": 1},
+			"b", []byte("hi"),
+			"e", []int{},
+		)
+		if got, want := buf.String(), "msg=m "+tt.want+"\n"; got != want {
+			t.Errorf("#%d: Handle() = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestLogHandler_LevelLabels(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewLogHandler(&buf, nil, false, WithLevelLabels(ShortLevelLabels), WithPadLevel(true), WithDisableTime(true)))
+	l.Warn("w")
+	l.Log(LevelInfo+1, "i")
+	want := "\x1b[33mWRN\x1b[0m w \n\x1b[36mINFO+1\x1b[0m i \n"
+	if got := buf.String(); got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	labels := map[Level]string{LevelInfo: "ℹ️", LevelError: "❌"}
+	l = NewLogger(NewLogHandler(&buf, nil, true, WithLevelLabels(labels), WithPadLevel(true), WithDisableTime(true)))
+	l.Info("i")
+	l.Warn("w")
+	l.Error("e")
+	// padded to the unlabeled DEBUG and TRACE
+	if got, want := buf.String(), "ℹ️    i \nWARN  w \n❌     e \n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+
+	// a level registered later widens the padding
+	width, registry := levelWidth.Load(), levels.Load()
+	t.Cleanup(func() {
+		levels.Store(registry)
+		levelWidth.Store(width)
+	})
+	RegisterLevel("notice", LevelInfo+2)
+	buf.Reset()
+	l.Info("i")
+	if got, want := buf.String(), "ℹ️     i \n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+}
+
+type selfValuer struct{}
+
+func (v selfValuer) LogValue() Value { return slog.AnyValue(v) }
+
+type groupValuer struct{ key string }
+
+func (v groupValuer) LogValue() Value { return slog.GroupValue(slog.Any(v.key, v)) }
+
+func TestLogHandler_LogValuerCycle(t *testing.T) {
+	for _, sorted := range []bool{false, true} {
+		// An inline group with an empty key terminates too, but its
+		// BadValue is elided like any other attr with an empty key.
+		for _, v := range []any{selfValuer{}, groupValuer{"g"}, groupValuer{""}} {
+			var buf bytes.Buffer
+			h := NewLogHandler(&buf, nil, true, WithSortAttrs(sorted))
+			record := slog.NewRecord(time.Time{}, LevelInfo, "msg", 0)
+			record.AddAttrs(slog.Any("v", v))
+			if err := h.Handle(context.Background(), record); err != nil {
+				t.Fatal(err)
+			}
+			if v == (groupValuer{""}) {
+				continue
+			}
+			if !strings.Contains(buf.String(), BadValue) {
+				t.Errorf("sorted=%v %T: Handle() = %q, want %s", sorted, v, buf.String(), BadValue)
+			}
+		}
+	}
+}
+
+func TestSortHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewSortHandler(NewLogfmtHandler(&buf, nil, WithDisableTime(true))))
+	l.With("z", 0, "y", 0).WithGroup("g").With("x", 0).Info("msg",
+		"c", 1,
+		slog.Group("b", "e", 2, slog.Group("d", "g", 3, "f", 4)),
+		slog.Group("", "a", 5),
+	)
+	// the attrs of WithAttrs keep their order, the nested groups are sorted
+	if got, want := buf.String(), "level=INFO msg=msg z=0 y=0 g.x=0 g.a=5 g.b.d.f=4 g.b.d.g=3 g.b.e=2 g.c=1\n"; got != want {
+		t.Errorf("Handle() = %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	l.Info("msg", slog.Group("g", "b", 1, "a", 2))
+	if got, want := buf.String(), "level=INFO msg=msg g.a=2 g.b=1\n"; got != want {
+		t.Errorf("Handle() of a single group = %q, want %q", got, want)
+	}
+}
+
+type panicHandler struct{}
+
+func (panicHandler) Enabled(context.Context, Level) bool  { return true }
+func (panicHandler) Handle(context.Context, Record) error { panic("handle") }
+func (h panicHandler) WithAttrs(attrs []Attr) Handler     { panic("with attrs") }
+func (h panicHandler) WithGroup(name string) Handler      { panic("with group") }
+
+func TestMultiHandler_Panic(t *testing.T) {
+	var reported []string
+	SetErrorHandler(func(err error) { reported = append(reported, err.Error()) })
+	defer SetErrorHandler(nil)
+
+	for _, concurrency := range []int{0, 2} {
+		var buf bytes.Buffer
+		reported = nil
+		h := NewConcurrentMultiHandler(concurrency, panicHandler{}, NewLogHandler(&buf, nil, true))
+		h = h.WithGroup("g").WithAttrs([]Attr{slog.Int("a", 1)})
+		if want := []string{"handler panic: with group", "handler panic: with attrs"}; !slices.Equal(reported, want) {
+			t.Errorf("reported = %q, want %q", reported, want)
+		}
+
+		err := h.Handle(context.Background(), slog.NewRecord(time.Now(), LevelInfo, "msg", 0))
+		if err == nil || !strings.Contains(err.Error(), "handler panic: handle") {
+			t.Errorf("Handle() error = %v, want handler panic", err)
+		}
+		if got := buf.String(); !strings.Contains(got, "msg") || !strings.Contains(got, "g.a=1") {
+			t.Errorf("Handle() = %q, want the record to be written", got)
+		}
+	}
+}
+
+// flushHandler counts the records it handles, and its Flush and Close calls.
+type flushHandler struct {
+	level   Level
+	handled int
+	flushed int
+	closed  int
+}
+
+func (h *flushHandler) Enabled(_ context.Context, level Level) bool { return level >= h.level }
+func (h *flushHandler) Handle(context.Context, Record) error        { h.handled++; return nil }
+func (h *flushHandler) WithAttrs([]Attr) Handler                    { return h }
+func (h *flushHandler) WithGroup(string) Handler                    { return h }
+func (h *flushHandler) Flush() error                                { h.flushed++; return nil }
+func (h *flushHandler) Close() error                                { h.closed++; return nil }
+
+func TestMultiHandler_Enabled(t *testing.T) {
+	for _, concurrency := range []int{0, 2} {
+		debug := &flushHandler{level: LevelDebug}
+		warn := &flushHandler{level: LevelWarn}
+		var buf bytes.Buffer
+		h := NewConcurrentMultiHandler(concurrency, debug, warn, NewLogHandler(&buf, &HandlerOptions{Level: LevelError}, true))
+
+		l := NewLogger(h)
+		l.Debug("debug")
+		l.Info("info")
+		l.Warn("warn")
+		if debug.handled != 3 || warn.handled != 1 {
+			t.Errorf("handled = %d, %d, want 3, 1", debug.handled, warn.handled)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Handle() = %q, want the disabled handler to be skipped", buf.String())
+		}
+
+		if err := h.(Flusher).Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.(io.Closer).Close(); err != nil {
+			t.Fatal(err)
+		}
+		if debug.flushed != 1 || warn.flushed != 1 || debug.closed != 1 || warn.closed != 1 {
+			t.Errorf("flushed = %d, %d, closed = %d, %d, want 1", debug.flushed, warn.flushed, debug.closed, warn.closed)
+		}
+	}
+}
+
+func benchmarkLogHandler(b *testing.B, options ...LogHandlerOption) {
+	benchmarkLogHandlerOpts(b, nil, options...)
+}
+
+func benchmarkLogHandlerOpts(b *testing.B, opts *HandlerOptions, options ...LogHandlerOption) {
+	l := NewLogger(NewLogHandler(io.Discard, opts, true, options...))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("benchmark", "user", "admin", "id", i, "action", "login", "b", true, "a", 1.5)
+	}
+}
+
+func BenchmarkLogHandler(b *testing.B) {
+	benchmarkLogHandler(b)
+}
+
+func BenchmarkLogHandler_SortAttrs(b *testing.B) {
+	benchmarkLogHandler(b, WithSortAttrs(true))
+}
+
+func BenchmarkLogHandler_AddSource(b *testing.B) {
+	benchmarkLogHandlerOpts(b, &HandlerOptions{AddSource: true})
+}
+
+func BenchmarkLogHandler_Color(b *testing.B) {
+	l := NewLogger(NewLogHandler(io.Discard, nil, false)).With("service", "api")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("benchmark", "user", "admin", "id", i, "action", "login", "b", true, "a", 1.5)
+	}
+}
+
+func BenchmarkLogHandler_LogAttrs(b *testing.B) {
+	l := NewLogger(NewLogHandler(io.Discard, nil, true))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.LogAttrs(LevelInfo, "benchmark",
+			slog.String("user", "admin"),
+			slog.Int("id", i),
+			slog.String("action", "login"),
+			slog.Bool("b", true),
+			slog.Float64("a", 1.5),
+		)
+	}
+}
+
+func BenchmarkLogHandler_Duration(b *testing.B) {
+	l := NewLogger(NewLogHandler(io.Discard, nil, true, WithDurationFormat(DurationMillis)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.LogAttrs(LevelInfo, "benchmark", slog.Duration("elapsed", 1500*time.Millisecond))
+	}
+}
+
+func BenchmarkLogHandler_Parallel(b *testing.B) {
+	l := NewLogger(NewLogHandler(io.Discard, nil, true))
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			l.Info("benchmark", "user", "admin", "id", i, "action", "login", "b", true, "a", 1.5)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// HTTPWriterOptions configures the writer created by NewHTTPWriter.
+type HTTPWriterOptions struct {
+	// Header is added to the requests.
+	Header http.Header
+	// BatchSize is the size in bytes of the batches, 1MiB by default.
+	BatchSize int
+	// BatchWait is the time after which a batch is posted even if it
+	// isn't full, 5s by default.
+	BatchWait time.Duration
+	// MaxQueue is the size in bytes of the writes queued while a batch is
+	// posted, 16MiB by default. The writes exceeding it are dropped
+	// with an error.
+	MaxQueue int
+	// Retries is how many times a post failing with a network error,
+	// 429 or 5xx is retried with an exponential backoff.
+	Retries int
+	// Gzip compresses the request bodies with gzip.
+	Gzip bool
+	// Client posts the batches, http.DefaultClient by default.
+	Client *http.Client
+}
+
+// NewHTTPWriter creates a writer that posts the writes to url in batches
+// as newline-delimited JSON, e.g. the output of NewJSONHandler.
+// Each write is a line of the body, a newline is appended if missing.
+// The errors of the background posts are reported to the func set by
+// SetErrorHandler. Close the writer to post the final batch.
+func NewHTTPWriter(url string, opts HTTPWriterOptions) io.WriteCloser {
+	w := &httpWriter{
+		url:     url,
+		client:  opts.Client,
+		header:  opts.Header.Clone(),
+		gzip:    opts.Gzip,
+		retries: opts.Retries,
+	}
+	if w.client == nil {
+		w.client = http.DefaultClient
+	}
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	w.header.Set("Content-Type", "application/x-ndjson")
+	if w.gzip {
+		w.header.Set("Content-Encoding", "gzip")
+	}
+
+	batchSize, batchWait, maxQueue := opts.BatchSize, opts.BatchWait, opts.MaxQueue
+	if batchSize <= 0 {
+		batchSize = 1 << 20
+	}
+	if batchWait <= 0 {
+		batchWait = 5 * time.Second
+	}
+	if maxQueue <= 0 {
+		maxQueue = 16 << 20
+	}
+	w.batcher = newBatcher("http writer", 0, batchSize, max(maxQueue, batchSize), batchWait, w.post)
+	return w
+}
+
+type httpWriter struct {
+	url     string
+	client  *http.Client
+	header  http.Header
+	gzip    bool
+	retries int
+	batcher *batcher[[]byte]
+}
+
+// Write implements io.Writer, and queues a copy of p.
+func (w *httpWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	line := slices.Clone(p)
+	if line[len(line)-1] != '\n' {
+		line = append(line, '\n')
+	}
+	if err := w.batcher.add(line, len(line)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close implements io.Closer, and posts the pending writes.
+func (w *httpWriter) Close() error {
+	return w.batcher.close()
+}
+
+// Flush implements Flusher, and posts the pending writes.
+func (w *httpWriter) Flush() error {
+	return w.batcher.flushPending()
+}
+
+func (w *httpWriter) post(lines [][]byte) error {
+	var body bytes.Buffer
+	if w.gzip {
+		zw := gzip.NewWriter(&body)
+		for _, line := range lines {
+			_, _ = zw.Write(line)
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	} else {
+		for _, line := range lines {
+			body.Write(line)
+		}
+	}
+	if _, err := postWithRetry(w.client, w.url, w.header, body.Bytes(), w.retries); err != nil {
+		return fmt.Errorf("http writer post failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPWriter(t *testing.T) {
+	s := new(httpServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	w := NewHTTPWriter(srv.URL, HTTPWriterOptions{BatchSize: 100, BatchWait: time.Hour})
+	dropTime := func(groups []string, a Attr) Attr {
+		if len(groups) == 0 && a.Key == TimeKey {
+			return Attr{}
+		}
+		return a
+	}
+	l := NewLogger(NewJSONHandler(w, &HandlerOptions{ReplaceAttr: dropTime}, ""))
+	l.Info("one", "a", strings.Repeat("x", 60))
+	l.Info("two")
+	// the full batch is posted in the background
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		posted := len(s.bodies)
+		s.mu.Unlock()
+		if posted > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := w.Write([]byte(`{"msg":"raw"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("{}")); err == nil {
+		t.Error("Write() after Close() succeeded")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{
+		`{"level":"INFO","msg":"one","a":"` + strings.Repeat("x", 60) + `"}` + "\n" +
+			`{"level":"INFO","msg":"two"}` + "\n",
+		`{"msg":"raw"}` + "\n",
+	}
+	if strings.Join(s.bodies, "|") != strings.Join(want, "|") {
+		t.Errorf("bodies = %q, want %q", s.bodies, want)
+	}
+	if got := s.header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestHTTPWriter_Gzip(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	w := NewHTTPWriter(srv.URL, HTTPWriterOptions{Gzip: true})
+	_, _ = w.Write([]byte(`{"msg":"one"}` + "\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"msg":"one"}` + "\n"; body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestHTTPWriter_MaxQueue(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+
+	w := NewHTTPWriter(srv.URL, HTTPWriterOptions{BatchSize: 10, MaxQueue: 20, BatchWait: time.Hour})
+	line := []byte("0123456789")
+	// the first batch is posting, then the queue fills up
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, err = w.Write(line)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err == nil || !strings.Contains(err.Error(), "queue is full") {
+		t.Errorf("Write() error = %v, want queue is full", err)
+	}
+	close(done)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// JournaldSocket is the socket of the journald native protocol.
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// NewJournaldHandler creates a Handler that sends records to journald
+// using the native protocol. The level is sent as the syslog PRIORITY,
+// the source as CODE_FILE, CODE_LINE and CODE_FUNC if AddSource is set,
+// and each attribute as a field, whose group-qualified key is upper-cased
+// and sanitized to the characters journald accepts.
+// It returns an error if the journald socket isn't present.
+func NewJournaldHandler(opts *HandlerOptions) (Handler, error) {
+	if _, err := os.Stat(JournaldSocket); err != nil {
+		return nil, fmt.Errorf("journald is not available: %w", err)
+	}
+	conn, err := net.Dial("unixgram", JournaldSocket)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to journald: %w", err)
+	}
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	return &journaldHandler{
+		conn:       conn,
+		opts:       *opts,
+		identifier: filepath.Base(os.Args[0]),
+	}, nil
+}
+
+type journaldHandler struct {
+	conn       net.Conn
+	opts       HandlerOptions
+	identifier string
+
+	groups []string
+	fields []byte // preformatted fields of WithAttrs
+}
+
+// Close implements io.Closer, and closes the journald connection.
+func (h *journaldHandler) Close() error {
+	return h.conn.Close()
+}
+
+func (h *journaldHandler) clone() *journaldHandler {
+	return &journaldHandler{
+		conn:       h.conn,
+		opts:       h.opts,
+		identifier: h.identifier,
+		groups:     slices.Clip(h.groups),
+		fields:     slices.Clip(h.fields),
+	}
+}
+
+func (h *journaldHandler) NeedsSource() bool {
+	return h.opts.AddSource
+}
+
+func (h *journaldHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *journaldHandler) Handle(_ context.Context, record Record) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", record.Message)
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(record.Level)))
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", h.identifier)
+	// source, records constructed without a caller have no PC
+	if h.opts.AddSource && record.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{record.PC})
+		f, _ := fs.Next()
+		appendJournalField(&buf, "CODE_FILE", f.File)
+		appendJournalField(&buf, "CODE_LINE", strconv.Itoa(f.Line))
+		appendJournalField(&buf, "CODE_FUNC", f.Function)
+	}
+	buf.Write(h.fields)
+	record.Attrs(func(attr Attr) bool {
+		h.appendAttr(&buf, h.groups, attr)
+		return true
+	})
+
+	_, err := h.conn.Write(buf.Bytes())
+	return err
+}
+
+func (h *journaldHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		cp.appendAttr(&buf, cp.groups, attr)
+	}
+	cp.fields = append(cp.fields, buf.Bytes()...)
+	return cp
+}
+
+func (h *journaldHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	return cp
+}
+
+func (h *journaldHandler) appendAttr(buf *bytes.Buffer, groups []string, a Attr) {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return
+	}
+
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(buf, g2, ga)
+		}
+		return
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, "_") + "_" + key
+	}
+	appendJournalField(buf, journalFieldName(key), a.Value.String())
+}
+
+// appendJournalField appends a field in the journald native protocol format,
+// values containing newlines use the length-prefixed binary-safe format.
+func appendJournalField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.IndexByte(value, '\n') == -1 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts key to a valid journald field name,
+// which consists of upper-case letters, digits and underscores,
+// and must not start with an underscore or a digit.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	name = bytes.TrimLeft(name, "_")
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("F_"), name...)
+	}
+	return string(name)
+}
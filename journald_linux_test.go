@@ -0,0 +1,54 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package wslog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAppendJournalField(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "hello", want: "MESSAGE=hello\n"},
+		{value: "a\nb", want: "MESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		appendJournalField(&buf, "MESSAGE", tt.value)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("appendJournalField(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	tests := map[string]string{
+		"user_id":  "USER_ID",
+		"http.url": "HTTP_URL",
+		"_secret":  "SECRET",
+		"1st":      "F_1ST",
+		"":         "F_",
+	}
+	for key, want := range tests {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
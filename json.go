@@ -0,0 +1,244 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PresetECS is the Config.Preset for the Elastic Common Schema,
+// which renames the built-in keys to `@timestamp`, `log.level` and `message`,
+// renders the time as RFC3339Nano and lower-cases the level.
+const PresetECS = "ecs"
+
+// fieldsReplaceAttr returns a ReplaceAttr func which calls next and then
+// renames the built-in keys according to the Config fields and preset.
+func (c *Config) fieldsReplaceAttr(next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	ecs := strings.EqualFold(c.Preset, PresetECS)
+	timeField, levelField, messageField := c.TimeField, c.LevelField, c.MessageField
+	if ecs {
+		if timeField == "" {
+			timeField = "@timestamp"
+		}
+		if levelField == "" {
+			levelField = "log.level"
+		}
+		if messageField == "" {
+			messageField = "message"
+		}
+	}
+
+	return func(groups []string, a Attr) Attr {
+		if next != nil {
+			a = next(groups, a)
+		}
+		if len(groups) > 0 {
+			return a
+		}
+		switch a.Key {
+		case TimeKey:
+			if ecs && a.Value.Kind() == KindTime {
+				a.Value = slog.StringValue(a.Value.Time().Format(time.RFC3339Nano))
+			}
+			if timeField != "" {
+				a.Key = timeField
+			}
+		case LevelKey:
+			if ecs {
+				a.Value = slog.StringValue(strings.ToLower(a.Value.String()))
+			}
+			if levelField != "" {
+				a.Key = levelField
+			}
+		case MessageKey:
+			if messageField != "" {
+				a.Key = messageField
+			}
+		}
+		return a
+	}
+}
+
+// maxSafeInteger is the largest integer that a float64 represents exactly,
+// e.g. the Number of JavaScript.
+const maxSafeInteger = 1<<53 - 1
+
+// intStringReplaceAttr returns a ReplaceAttr func which calls next and
+// then converts to strings the integers beyond ±maxSafeInteger if safe
+// is true, and the integers of keys, either attr keys or group-qualified
+// keys like `order.id`.
+func intStringReplaceAttr(safe bool, keys []string, next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	keySet := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		keySet[key] = struct{}{}
+	}
+	matches := func(groups []string, key string) bool {
+		if _, ok := keySet[key]; ok {
+			return true
+		}
+		if len(groups) == 0 {
+			return false
+		}
+		_, ok := keySet[strings.Join(groups, ".")+"."+key]
+		return ok
+	}
+
+	return func(groups []string, a Attr) Attr {
+		if next != nil {
+			a = next(groups, a)
+		}
+		switch a.Value.Kind() {
+		case KindInt64:
+			if n := a.Value.Int64(); (safe && (n > maxSafeInteger || n < -maxSafeInteger)) || matches(groups, a.Key) {
+				a.Value = slog.StringValue(strconv.FormatInt(n, 10))
+			}
+		case KindUint64:
+			if n := a.Value.Uint64(); (safe && n > maxSafeInteger) || matches(groups, a.Key) {
+				a.Value = slog.StringValue(strconv.FormatUint(n, 10))
+			}
+		}
+		return a
+	}
+}
+
+// NewJSONHandler creates a slog JSON handler that writes to w.
+// If indent is not empty, each record is pretty-printed over multiple lines
+// with the given indent. The rendering of the values is the same as
+// the compact form, but the output can no longer be parsed line by line,
+// so indent is intended for local development only.
+func NewJSONHandler(w io.Writer, opts *HandlerOptions, indent string) Handler {
+	if indent != "" {
+		w = &indentWriter{w: w, indent: indent}
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// indentWriter indents the JSON record written by each call to Write.
+// The slog JSON handler writes each record with a single call.
+type indentWriter struct {
+	w      io.Writer
+	indent string
+}
+
+func (w *indentWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, p, "", w.indent); err != nil {
+		// not a complete JSON value, write it as is
+		return w.w.Write(p)
+	}
+	if _, err := w.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NewPrettyJSONHandler creates a slog JSON handler for the console that
+// writes each record to w as an indented block with colorized keys and
+// a level-colored level, separated by a blank line.
+// If disableColor is true, it falls back to the compact JSON form.
+func NewPrettyJSONHandler(w io.Writer, opts *HandlerOptions, disableColor bool) Handler {
+	if disableColor {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewJSONHandler(&prettyJSONWriter{w: w}, opts)
+}
+
+const jsonKeyColor = "\x1b[34m" // blue
+
+// prettyJSONWriter indents and colorizes the JSON record written by each call to Write.
+type prettyJSONWriter struct {
+	w io.Writer
+}
+
+func (w *prettyJSONWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, bytes.TrimSpace(p), "", "  "); err != nil {
+		// not a complete JSON value, write it as is
+		return w.w.Write(p)
+	}
+	out := colorizeJSON(buf.Bytes())
+	out = append(out, '\n', '\n')
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// colorizeJSON wraps the keys of the valid JSON b in color sequences,
+// and the value of the top-level level key in the color of the level.
+func colorizeJSON(b []byte) []byte {
+	out := make([]byte, 0, len(b)*2)
+	var (
+		depth     int
+		levelNext bool
+	)
+	for i := 0; i < len(b); {
+		switch c := b[i]; c {
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		case quoteChar:
+			end := i + 1
+			for ; end < len(b) && b[end] != quoteChar; end++ {
+				if b[end] == escapeChar {
+					end++
+				}
+			}
+			end = min(end+1, len(b))
+			str := b[i:end]
+
+			next := end
+			for next < len(b) && (b[next] == sepChar || b[next] == '\n') {
+				next++
+			}
+			switch {
+			case next < len(b) && b[next] == ':':
+				out = append(out, jsonKeyColor...)
+				out = append(out, str...)
+				out = append(out, SLevel("").getColorSuffix()...)
+				levelNext = depth == 1 && string(str) == `"`+LevelKey+`"`
+			case levelNext:
+				level, _ := strconv.Unquote(string(str))
+				out = append(out, SLevel(level).buildColorFormat(string(str))...)
+				levelNext = false
+			default:
+				out = append(out, str...)
+			}
+			i = end
+			continue
+		}
+		out = append(out, b[i])
+		i++
+	}
+	return out
+}
+
+// isTerminal reports whether w is a character device like a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
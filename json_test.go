@@ -0,0 +1,211 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_PresetECS(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{Format: "json", Preset: PresetECS}
+	New(cfg, &buf).Warn("the warn log", "user", "admin")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	ts, ok := got["@timestamp"].(string)
+	if !ok {
+		t.Fatalf("@timestamp = %v, want a string", got["@timestamp"])
+	}
+	if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		t.Errorf("@timestamp = %s, want RFC3339Nano: %v", ts, err)
+	}
+	delete(got, "@timestamp")
+
+	want := map[string]any{
+		"log.level": "warn",
+		"message":   "the warn log",
+		"user":      "admin",
+	}
+	if len(got) != len(want) {
+		t.Errorf("New() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("New() %s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestConfig_TimeZone(t *testing.T) {
+	for _, cfg := range []Config{
+		{Format: "json", TimeUTC: true},
+		{Format: "json", TimeZone: "UTC"},
+	} {
+		var buf bytes.Buffer
+		New(cfg, &buf).Info("msg")
+
+		var got struct {
+			Time string `json:"time"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, got.Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, offset := ts.Zone(); offset != 0 || !strings.HasSuffix(got.Time, "Z") {
+			t.Errorf("%+v: time = %s, want UTC", cfg, got.Time)
+		}
+	}
+}
+
+func TestConfig_SafeIntegers(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{Format: "json", SafeIntegers: true, StringIntKeys: []string{"id", "order.total"}}
+	New(cfg, &buf).Info("msg",
+		"small", 1<<53-1,
+		"large", int64(1<<53),
+		"negative", int64(-1<<53),
+		"unsigned", uint64(1<<63),
+		"id", 1,
+		slog.Group("order", "total", 2, "count", 3),
+	)
+	want := `"small":9007199254740991,"large":"9007199254740992","negative":"-9007199254740992",` +
+		`"unsigned":"9223372036854775808","id":"1","order":{"total":"2","count":3}}`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("output = %s, want %s", got, want)
+	}
+}
+
+func TestConfig_DisableTime(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{Format: "json", DisableTime: true, DisableLevel: true}
+	New(cfg, &buf).Info("msg", "k", "v")
+	if got, want := buf.String(), `{"msg":"msg","k":"v"}`+"\n"; got != want {
+		t.Errorf("output = %s, want %s", got, want)
+	}
+}
+
+func TestConfig_Indent(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "json", Indent: "  ", DisableTime: true}, &buf)
+	l.Info("first", slog.Group("g", "a", 1))
+	l.Warn("second", "s", "a\nb")
+
+	want := `{
+  "level": "INFO",
+  "msg": "first",
+  "g": {
+    "a": 1
+  }
+}
+{
+  "level": "WARN",
+  "msg": "second",
+  "s": "a\nb"
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output = %s, want %s", got, want)
+	}
+
+	// each record is a block of valid JSON
+	dec := json.NewDecoder(&buf)
+	var msgs []string
+	for dec.More() {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m[MessageKey].(string))
+	}
+	if len(msgs) != 2 || msgs[0] != "first" || msgs[1] != "second" {
+		t.Errorf("records = %v, want first and second", msgs)
+	}
+}
+
+func TestColorizeJSON(t *testing.T) {
+	const (
+		key   = jsonKeyColor
+		reset = colorReset
+	)
+	tests := []struct {
+		in, want string
+	}{
+		{
+			`{"level": "ERROR", "msg": "a"}`,
+			`{` + key + `"level"` + reset + `: ` + "\x1b[31m" + `"ERROR"` + reset + `, ` + key + `"msg"` + reset + `: "a"}`,
+		},
+		{
+			// the escaped quotes don't end the strings
+			`{"a\"b": "c\": \"d", "e": "\\"}`,
+			`{` + key + `"a\"b"` + reset + `: "c\": \"d", ` + key + `"e"` + reset + `: "\\"}`,
+		},
+		{
+			// only the top-level level is colored
+			`{"g": {"level": "INFO"}, "msg": "level"}`,
+			`{` + key + `"g"` + reset + `: {` + key + `"level"` + reset + `: "INFO"}, ` + key + `"msg"` + reset + `: "level"}`,
+		},
+		{
+			// a level replaced by a number doesn't color the next string
+			`{"level": 8, "msg": "INFO"}`,
+			`{` + key + `"level"` + reset + `: 8, ` + key + `"msg"` + reset + `: "INFO"}`,
+		},
+	}
+	for _, tt := range tests {
+		if got := string(colorizeJSON([]byte(tt.in))); got != tt.want {
+			t.Errorf("colorizeJSON(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+var colorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func TestPrettyJSONHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewPrettyJSONHandler(&buf, nil, false))
+	l.Info("first", "k", `"quoted"`)
+	l.Warn("second")
+
+	blocks := strings.Split(strings.TrimSuffix(buf.String(), "\n\n"), "\n\n")
+	if len(blocks) != 2 {
+		t.Fatalf("output = %q, want 2 blocks", buf.String())
+	}
+	for _, block := range blocks {
+		if !strings.Contains(block, jsonKeyColor) || strings.Count(block, "\n") < 3 {
+			t.Errorf("block = %q, want indented and colorized", block)
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(colorPattern.ReplaceAllString(block, "")), &m); err != nil {
+			t.Errorf("block %q: %v", block, err)
+		}
+	}
+
+	buf.Reset()
+	NewLogger(NewPrettyJSONHandler(&buf, &HandlerOptions{ReplaceAttr: omitBuiltinsReplaceAttr(true, false, nil)}, true)).Info("msg", "k", 1)
+	if got, want := buf.String(), `{"level":"INFO","msg":"msg","k":1}`+"\n"; got != want {
+		t.Errorf("output = %q, want the compact form %q", got, want)
+	}
+}
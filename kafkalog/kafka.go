@@ -0,0 +1,266 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kafkalog produces the records of wslog to a Kafka topic.
+package kafkalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+// ErrBufferFull is passed to the error handler for each record
+// dropped because the buffer of the handler is full.
+var ErrBufferFull = errors.New("kafka buffer is full, dropped a record")
+
+// Message is a message to produce.
+type Message struct {
+	Topic string
+	Key   []byte // nil if the record has no key attr
+	Value []byte
+	Time  time.Time
+}
+
+// Producer produces a message and waits for its delivery, e.g. with kgo:
+//
+//	func (p kgoProducer) Produce(ctx context.Context, msg kafkalog.Message) error {
+//		record := &kgo.Record{Topic: msg.Topic, Key: msg.Key, Value: msg.Value, Timestamp: msg.Time}
+//		return p.client.ProduceSync(ctx, record).FirstErr()
+//	}
+//
+// or with the SendMessage of a sarama.SyncProducer.
+type Producer interface {
+	Produce(ctx context.Context, msg Message) error
+}
+
+// Encoder creates the Handler rendering each record written to w,
+// which must write each record with a single call to w.Write.
+type Encoder func(w io.Writer, opts *wslog.HandlerOptions) wslog.Handler
+
+// JSONEncoder renders the records with the slog JSON handler, which is the default.
+func JSONEncoder(w io.Writer, opts *wslog.HandlerOptions) wslog.Handler {
+	return slog.NewJSONHandler(w, opts)
+}
+
+// Option configures the handler created by NewHandler.
+type Option func(q *queue)
+
+// WithEncoder sets the encoder of the records, JSONEncoder by default.
+func WithEncoder(encoder Encoder) Option {
+	return func(q *queue) {
+		q.encoder = encoder
+	}
+}
+
+// WithKeyAttr sets the key of the top-level attr whose value is the key of
+// the messages, e.g. `tenant`. The messages have no key by default.
+func WithKeyAttr(key string) Option {
+	return func(q *queue) {
+		q.keyAttr = key
+	}
+}
+
+// WithBufferSize sets how many records are buffered while they are produced,
+// 1024 by default. The records exceeding it are dropped.
+func WithBufferSize(size int) Option {
+	return func(q *queue) {
+		q.bufferSize = size
+	}
+}
+
+// WithErrorHandler sets the func called with the produce errors and
+// ErrBufferFull, wslog.ReportError by default.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(q *queue) {
+		q.onError = fn
+	}
+}
+
+// NewHandler creates a Handler that produces each record to topic with
+// producer in the background, without blocking the logging.
+// Close the returned handler to produce the pending records.
+func NewHandler(producer Producer, topic string, opts *wslog.HandlerOptions, options ...Option) wslog.Handler {
+	q := &queue{
+		client:     producer,
+		topic:      topic,
+		encoder:    JSONEncoder,
+		bufferSize: 1024,
+		onError:    wslog.ReportError,
+	}
+	for _, option := range options {
+		option(q)
+	}
+	q.messages = make(chan Message, max(q.bufferSize, 1))
+	q.wg.Add(1)
+	go q.run()
+	return &handler{queue: q, encoded: q.encoder(q, opts)}
+}
+
+type handler struct {
+	queue   *queue // shared among all clones of this handler
+	encoded wslog.Handler
+
+	grouped bool    // whether WithGroup was called, attrs are no longer top-level
+	key     *string // message key of WithAttrs, nil if none
+}
+
+// Close implements io.Closer, and produces the pending records.
+func (h *handler) Close() error {
+	return h.queue.close()
+}
+
+func (h *handler) NeedsSource() bool {
+	if sa, ok := h.encoded.(wslog.SourceAware); ok {
+		return sa.NeedsSource()
+	}
+	return true
+}
+
+func (h *handler) Enabled(ctx context.Context, level wslog.Level) bool {
+	return h.encoded.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record wslog.Record) error {
+	key := h.key
+	if !h.grouped && h.queue.keyAttr != "" {
+		record.Attrs(func(attr wslog.Attr) bool {
+			if attr.Key == h.queue.keyAttr {
+				value := attr.Value.Resolve().String()
+				key = &value
+			}
+			return true
+		})
+	}
+
+	data, err := h.queue.encode(ctx, h.encoded, record)
+	if err != nil {
+		return err
+	}
+	msg := Message{Topic: h.queue.topic, Value: data, Time: record.Time}
+	if key != nil {
+		msg.Key = []byte(*key)
+	}
+	return h.queue.send(msg)
+}
+
+func (h *handler) WithAttrs(attrs []wslog.Attr) wslog.Handler {
+	cp := &handler{
+		queue:   h.queue,
+		encoded: h.encoded.WithAttrs(attrs),
+		grouped: h.grouped,
+		key:     h.key,
+	}
+	if !h.grouped && h.queue.keyAttr != "" {
+		for _, attr := range attrs {
+			if attr.Key == h.queue.keyAttr {
+				value := attr.Value.Resolve().String()
+				cp.key = &value
+			}
+		}
+	}
+	return cp
+}
+
+func (h *handler) WithGroup(name string) wslog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{
+		queue:   h.queue,
+		encoded: h.encoded.WithGroup(name),
+		grouped: true,
+		key:     h.key,
+	}
+}
+
+type queue struct {
+	client     Producer
+	topic      string
+	encoder    Encoder
+	keyAttr    string
+	bufferSize int
+	onError    func(err error)
+
+	messages chan Message
+	wg       sync.WaitGroup
+	closeMu  sync.RWMutex // guards closed against the sends
+	closed   bool
+
+	// the encoded handlers write to the queue, one record at a time
+	mu   sync.Mutex
+	data []byte
+}
+
+// Write implements io.Writer for the encoded handlers.
+func (q *queue) Write(b []byte) (int, error) {
+	q.data = append(q.data[:0], b...)
+	return len(b), nil
+}
+
+// encode renders record with h, which writes to q.
+func (q *queue) encode(ctx context.Context, h wslog.Handler, record wslog.Record) ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.data = q.data[:0]
+	if err := h.Handle(ctx, record); err != nil {
+		return nil, err
+	}
+	return slices.Clone(q.data), nil
+}
+
+// send queues msg without blocking.
+func (q *queue) send(msg Message) error {
+	q.closeMu.RLock()
+	defer q.closeMu.RUnlock()
+
+	if q.closed {
+		return errors.New("kafka handler is closed")
+	}
+	select {
+	case q.messages <- msg:
+	default:
+		q.onError(ErrBufferFull)
+	}
+	return nil
+}
+
+func (q *queue) run() {
+	defer q.wg.Done()
+	for msg := range q.messages {
+		if err := q.client.Produce(context.Background(), msg); err != nil {
+			q.onError(fmt.Errorf("kafka produce failed: %w", err))
+		}
+	}
+}
+
+// close stops accepting records, and waits for the pending ones to be produced.
+func (q *queue) close() error {
+	q.closeMu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.messages)
+	}
+	q.closeMu.Unlock()
+	q.wg.Wait()
+	return nil
+}
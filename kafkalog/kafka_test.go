@@ -0,0 +1,128 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kafkalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/zc2638/wslog"
+)
+
+type fakeProducer struct {
+	mu       sync.Mutex
+	block    chan struct{} // blocks the produces until closed, if set
+	messages []Message
+}
+
+func (p *fakeProducer) Produce(_ context.Context, msg Message) error {
+	if p.block != nil {
+		<-p.block
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if string(msg.Key) == "fail" {
+		return errors.New("broker down")
+	}
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	producer := new(fakeProducer)
+	var errs []error
+	h := NewHandler(producer, "logs", nil,
+		WithKeyAttr("tenant"),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+	l := wslog.NewLogger(h)
+	l.Info("no key")
+	l.With("tenant", "a").Info("with attrs")
+	l.Info("record", "tenant", "b")
+	l.WithGroup("g").Info("grouped", "tenant", "c")
+	l.Info("failed", "tenant", "fail")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(context.Background(), wslog.Record{}); err == nil {
+		t.Error("Handle() after Close() succeeded")
+	}
+
+	want := []struct{ key, msg string }{
+		{"", "no key"},
+		{"a", "with attrs"},
+		{"b", "record"},
+		{"", "grouped"},
+	}
+	if len(producer.messages) != len(want) {
+		t.Fatalf("produced %d messages, want %d", len(producer.messages), len(want))
+	}
+	for i, w := range want {
+		msg := producer.messages[i]
+		var value map[string]any
+		if err := json.Unmarshal(msg.Value, &value); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Topic != "logs" || string(msg.Key) != w.key || value["msg"] != w.msg {
+			t.Errorf("message %d = %s %q %s, want key %q msg %q", i, msg.Topic, msg.Key, msg.Value, w.key, w.msg)
+		}
+		if w.key == "" && msg.Key != nil {
+			t.Errorf("message %d key = %q, want nil", i, msg.Key)
+		}
+	}
+	if len(errs) != 1 || errs[0].Error() != "kafka produce failed: broker down" {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestHandler_BufferFull(t *testing.T) {
+	producer := &fakeProducer{block: make(chan struct{})}
+	var mu sync.Mutex
+	var errs []error
+	h := NewHandler(producer, "logs", nil,
+		WithBufferSize(1),
+		WithErrorHandler(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	l := wslog.NewLogger(h)
+	for i := 0; i < 5; i++ {
+		l.Info("msg")
+	}
+	close(producer.block)
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// one record is producing, one is buffered
+	if got := len(producer.messages); got < 1 || got > 2 {
+		t.Errorf("produced %d messages, want 1 or 2", got)
+	}
+	if len(errs)+len(producer.messages) != 5 {
+		t.Errorf("errors = %v, want the dropped records", errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrBufferFull) {
+			t.Errorf("error = %v, want ErrBufferFull", err)
+		}
+	}
+}
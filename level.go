@@ -15,39 +15,321 @@
 package wslog
 
 import (
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// levelMux serializes the changes of the level registry,
+// the lookups load it without locking.
 var levelMux sync.Mutex
 
-var levelSet = map[SLevel]Level{
-	SLevelDebug: LevelDebug,
-	SLevelInfo:  LevelInfo,
-	SLevelWarn:  LevelWarn,
-	SLevelError: LevelError,
+// levelWidth is the width of the longest rendered registered level,
+// which the default log handler pads the levels to, see WithPadLevel.
+var levelWidth atomic.Int64
+
+// levels is the level registry, replaced as a whole on every change.
+var levels atomic.Pointer[levelRegistry]
+
+func init() {
+	levelWidth.Store(int64(len(LevelError.String())))
+	levels.Store(newLevelRegistry(map[SLevel]Level{
+		SLevelTrace: LevelTrace,
+		SLevelDebug: LevelDebug,
+		SLevelInfo:  LevelInfo,
+		SLevelWarn:  LevelWarn,
+		SLevelError: LevelError,
+	}, map[SLevel]SLevel{
+		// critical is the alias of a fatal level, if one is registered
+		"warning":  SLevelWarn,
+		"err":      SLevelError,
+		"critical": "fatal",
+	}, nil))
+}
+
+// levelRegistry is an immutable snapshot of the registered levels.
+type levelRegistry struct {
+	set     map[SLevel]Level     // the registered names
+	aliases map[SLevel]SLevel    // the aliases of the registered names
+	styles  map[Level]levelStyle // the styles registered by RegisterLevelStyle
+	names   map[Level]SLevel     // the preferred name of each registered level
+	sorted  []Level              // the registered levels in ascending order
+}
+
+// levelStyle is how the handlers render a registered level.
+type levelStyle struct {
+	label string
+	color string
 }
 
+func newLevelRegistry(set map[SLevel]Level, aliases map[SLevel]SLevel, styles map[Level]levelStyle) *levelRegistry {
+	r := &levelRegistry{
+		set:     set,
+		aliases: aliases,
+		styles:  styles,
+		names:   make(map[Level]SLevel, len(set)),
+	}
+	for ls, ln := range set {
+		if name, ok := r.names[ln]; !ok || preferLevelName(ls, name) {
+			r.names[ln] = ls
+		}
+	}
+	for ln := range r.names {
+		r.sorted = append(r.sorted, ln)
+	}
+	slices.Sort(r.sorted)
+	return r
+}
+
+// lookup returns the level of a registered name or alias.
+func (r *levelRegistry) lookup(name SLevel) (Level, bool) {
+	if level, ok := r.set[name]; ok {
+		return level, true
+	}
+	canonical, ok := r.aliases[name]
+	if !ok {
+		return LevelInfo, false
+	}
+	level, ok := r.set[canonical]
+	return level, ok
+}
+
+// normalizeLevel returns the lower-cased name, the level names are case-insensitive.
+func normalizeLevel(ls SLevel) SLevel {
+	return SLevel(strings.ToLower(strings.TrimSpace(string(ls))))
+}
+
+// RegisterLevel registers the level name ls, case-insensitively.
+// It is safe to call concurrently with logging.
 func RegisterLevel(ls SLevel, ln Level) {
+	ls = normalizeLevel(ls)
 	if ls == "" {
 		return
 	}
 	levelMux.Lock()
-	levelSet[ls] = ln
-	levelMux.Unlock()
+	defer levelMux.Unlock()
+	r := levels.Load()
+	set := maps.Clone(r.set)
+	set[ls] = ln
+	levels.Store(newLevelRegistry(set, r.aliases, r.styles))
+	if width := int64(max(len(ln.String()), len(ls))); width > levelWidth.Load() {
+		levelWidth.Store(width)
+	}
 }
 
-func ParseLevel(ls SLevel) slog.Level {
+// RegisterLevelAlias registers alias as another name of the level
+// registered as canonical, e.g. `warning` for `warn`. The canonical
+// level may be registered later, and a level registered with the
+// name of an alias takes precedence over it.
+func RegisterLevelAlias(alias, canonical SLevel) {
+	alias = normalizeLevel(alias)
+	if alias == "" {
+		return
+	}
 	levelMux.Lock()
 	defer levelMux.Unlock()
-	// If it does not exist, a zero value will be returned,
-	// which is equivalent to returning slog.LevelInfo
-	return levelSet[ls]
+	r := levels.Load()
+	aliases := maps.Clone(r.aliases)
+	aliases[alias] = normalizeLevel(canonical)
+	levels.Store(newLevelRegistry(r.set, aliases, r.styles))
+}
+
+// RegisterLevelStyle sets how the level registered as ls is rendered:
+// the text handlers write label instead of its name, e.g. `AUDIT`, in the
+// ANSI color escape code color, e.g. "\x1b[35m", when colors are enabled,
+// and the other handlers, like the JSON one, write label as the level.
+// An empty label or color keeps the default one. The levels without a style,
+// and the offsets from them, keep their default rendering.
+// An unknown ls is reported to the func set by SetErrorHandler.
+func RegisterLevelStyle(ls SLevel, label string, color string) {
+	levelMux.Lock()
+	defer levelMux.Unlock()
+	r := levels.Load()
+	level, ok := r.lookup(normalizeLevel(ls))
+	if !ok {
+		reportError(fmt.Errorf("unknown level %q", ls))
+		return
+	}
+	styles := maps.Clone(r.styles)
+	if styles == nil {
+		styles = make(map[Level]levelStyle)
+	}
+	styles[level] = levelStyle{label: label, color: color}
+	levels.Store(newLevelRegistry(r.set, r.aliases, styles))
+	if width := int64(len(label)); width > levelWidth.Load() {
+		levelWidth.Store(width)
+	}
+}
+
+func ParseLevel(ls SLevel) slog.Level {
+	// If it does not exist, LevelInfo will be returned,
+	// see ParseLevelErr to tell them apart
+	level, _ := levels.Load().lookup(normalizeLevel(ls))
+	return level
+}
+
+// ParseLevelErr is like ParseLevel, but returns an error
+// instead of LevelInfo if ls isn't registered.
+func ParseLevelErr(ls SLevel) (Level, error) {
+	level, ok := levels.Load().lookup(normalizeLevel(ls))
+	if !ok {
+		return LevelInfo, fmt.Errorf("unknown level %q", ls)
+	}
+	return level, nil
+}
+
+// ParseLevelStrict parses a level name with an optional offset like
+// SLevel.Level, e.g. `warn`, `info+2` or `debug-4`, but returns an
+// error for an unknown name or an invalid offset.
+func ParseLevelStrict(ls SLevel) (Level, error) {
+	return parseLevel(ls.String())
+}
+
+// parseLevel parses s as a registered level name, or a name followed by
+// a + or - offset. On error, the level of the name, or LevelInfo if it
+// is unknown, is returned along with the error.
+func parseLevel(s string) (Level, error) {
+	s = string(normalizeLevel(SLevel(s)))
+	r := levels.Load()
+
+	// a registered name takes precedence, it may contain a -
+	if level, ok := r.lookup(SLevel(s)); ok {
+		return level, nil
+	}
+	index := strings.LastIndexAny(s, "+-")
+	if index <= 0 {
+		return LevelInfo, fmt.Errorf("unknown level %q", s)
+	}
+	name := strings.TrimSpace(s[:index])
+	level, ok := r.lookup(SLevel(name))
+	if !ok {
+		return LevelInfo, fmt.Errorf("unknown level %q", name)
+	}
+	offset, err := strconv.Atoi(strings.TrimSpace(s[index+1:]))
+	if err != nil {
+		return level, fmt.Errorf("invalid offset of level %q", s)
+	}
+	if s[index] == '-' {
+		offset = -offset
+	}
+	return level + Level(offset), nil
+}
+
+// LevelToSLevel returns the registered name of level, or the name of the
+// closest registered level below it followed by an offset, e.g. `info+2`,
+// or above it if there is none, e.g. `trace-2`. The names of the builtin
+// levels are preferred over the ones registered for the same level.
+func LevelToSLevel(level Level) SLevel {
+	r := levels.Load()
+	if name, ok := r.names[level]; ok {
+		return name
+	}
+	if len(r.sorted) == 0 {
+		return SLevel(strings.ToLower(level.String()))
+	}
+	// the index of the lowest registered level above level
+	index, _ := slices.BinarySearch(r.sorted, level)
+	if index == 0 {
+		base := r.sorted[0]
+		return SLevel(fmt.Sprintf("%s-%d", r.names[base], base-level))
+	}
+	base := r.sorted[index-1]
+	return SLevel(fmt.Sprintf("%s+%d", r.names[base], level-base))
+}
+
+// preferLevelName reports whether a is preferred over b as the name
+// of the same level: a builtin name, then a shorter one, then the first
+// in lexical order, so that the choice doesn't depend on the map order.
+func preferLevelName(a, b SLevel) bool {
+	if builtinA, builtinB := isBuiltinLevel(a), isBuiltinLevel(b); builtinA != builtinB {
+		return builtinA
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+func isBuiltinLevel(ls SLevel) bool {
+	switch ls {
+	case SLevelTrace, SLevelDebug, SLevelInfo, SLevelWarn, SLevelError:
+		return true
+	}
+	return false
+}
+
+// levelName renders level for the text handlers, like Level.String for
+// the slog levels, and as the upper-cased LevelToSLevel for the others,
+// so that the registered levels are written with their names.
+func levelName(level Level) string {
+	if label, ok := levelLabel(level); ok {
+		return label
+	}
+	switch level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		// Level.String doesn't allocate for the standard levels
+		return level.String()
+	}
+	return strings.ToUpper(LevelToSLevel(level).String())
+}
+
+// levelLabel returns the label of level registered by RegisterLevelStyle.
+func levelLabel(level Level) (string, bool) {
+	style, ok := levels.Load().styles[level]
+	return style.label, ok && style.label != ""
+}
+
+// levelColor returns the color of level registered by RegisterLevelStyle,
+// or the color of its range.
+func levelColor(level Level) string {
+	if style, ok := levels.Load().styles[level]; ok && style.color != "" {
+		return style.color
+	}
+	return levelColors[levelColorIndex(level)]
+}
+
+// levelLabelReplaceAttr renders the level of the records with its label
+// registered by RegisterLevelStyle, if any.
+func levelLabelReplaceAttr(next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		isLevel := len(groups) == 0 && a.Key == LevelKey
+		if next != nil {
+			a = next(groups, a)
+		}
+		if isLevel && a.Value.Kind() == KindAny {
+			if level, ok := a.Value.Any().(Level); ok {
+				if label, ok := levelLabel(level); ok {
+					a.Value = slog.StringValue(label)
+				}
+			}
+		}
+		return a
+	}
+}
+
+// levelNameReplaceAttr renders the level of the records with levelName.
+func levelNameReplaceAttr(next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		isLevel := len(groups) == 0 && a.Key == LevelKey
+		if next != nil {
+			a = next(groups, a)
+		}
+		if isLevel && a.Value.Kind() == KindAny {
+			if level, ok := a.Value.Any().(Level); ok {
+				a.Value = slog.StringValue(levelName(level))
+			}
+		}
+		return a
+	}
 }
 
 const (
+	SLevelTrace SLevel = "trace"
 	SLevelDebug SLevel = "debug"
 	SLevelInfo  SLevel = "info"
 	SLevelWarn  SLevel = "warn"
@@ -60,30 +342,49 @@ func (l SLevel) String() string {
 	return string(l)
 }
 
-func (l SLevel) Level() Level {
-	parts := strings.SplitN(l.String(), "+", 2)
-	kind := strings.ToLower(strings.TrimSpace(parts[0]))
-	level := ParseLevel(SLevel(kind))
-	if len(parts) != 2 {
-		return level
+// MarshalText implements encoding.TextMarshaler. It returns an error if
+// l isn't a registered level name with an optional offset, see
+// LevelToSLevel to get the name of a Level.
+func (l SLevel) MarshalText() ([]byte, error) {
+	if l == "" {
+		return nil, nil
 	}
-	offset, _ := strconv.Atoi(strings.TrimSpace(parts[1]))
-	return level + Level(offset)
+	if _, err := parseLevel(l.String()); err != nil {
+		return nil, err
+	}
+	return []byte(l), nil
 }
 
-func (l SLevel) getColorPrefix() string {
-	parts := strings.SplitN(string(l), "+", 2)
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the
+// registered level names with an optional offset, e.g. `warn+2`,
+// and returns an error for the unknown ones. Empty text is allowed.
+func (l *SLevel) UnmarshalText(text []byte) error {
+	s := strings.ToLower(strings.TrimSpace(string(text)))
+	if s != "" {
+		if _, err := parseLevel(s); err != nil {
+			return err
+		}
+	}
+	*l = SLevel(s)
+	return nil
+}
 
-	var level string
-	switch len(parts) {
-	case 1, 2:
-		level = parts[0]
-	default:
-		return "\x1b[32m"
+// Level parses the level name with an optional offset, e.g. `warn`,
+// `info+2` or `debug-4`. An unknown name is LevelInfo, and an invalid
+// offset is ignored, see ParseLevelStrict to report them.
+func (l SLevel) Level() Level {
+	level, _ := parseLevel(l.String())
+	return level
+}
+
+func (l SLevel) getColorPrefix() string {
+	level := string(l)
+	if index := strings.LastIndexAny(level, "+-"); index > 0 {
+		level = level[:index]
 	}
 
 	switch SLevel(strings.ToLower(level)) {
-	case SLevelDebug:
+	case SLevelTrace, SLevelDebug:
 		return "\x1b[37m" // gray
 	case SLevelInfo:
 		return "\x1b[36m" // blue
@@ -97,7 +398,33 @@ func (l SLevel) getColorPrefix() string {
 }
 
 func (l SLevel) getColorSuffix() string {
-	return "\x1b[0m"
+	return colorReset
+}
+
+const colorReset = "\x1b[0m"
+
+// levelColors are the color prefixes of the slog levels, which only
+// depend on the level range, see levelColorIndex.
+var levelColors = [...]string{
+	"\x1b[37m", // gray
+	"\x1b[36m", // blue
+	"\x1b[33m", // yellow
+	"\x1b[31m", // red
+}
+
+// levelColorIndex returns the index in levelColors of the color of level,
+// the same as SLevel(level.String()).getColorPrefix() without formatting it.
+func levelColorIndex(level Level) int {
+	switch {
+	case level < LevelInfo:
+		return 0
+	case level < LevelWarn:
+		return 1
+	case level < LevelError:
+		return 2
+	default:
+		return 3
+	}
 }
 
 func (l SLevel) buildColorFormat(format string) string {
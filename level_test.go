@@ -0,0 +1,254 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSLevel_Level(t *testing.T) {
+	RegisterLevel("audit-log", LevelInfo+2)
+
+	tests := []struct {
+		level   SLevel
+		want    Level
+		wantErr bool
+	}{
+		{level: "info", want: LevelInfo},
+		{level: " WARN ", want: LevelWarn},
+		{level: "trace", want: LevelTrace},
+		{level: "info+2", want: LevelInfo + 2},
+		{level: "debug+4", want: LevelInfo},
+		{level: "warn-2", want: LevelWarn - 2},
+		{level: "info - 1", want: LevelInfo - 1},
+		{level: "audit-log", want: LevelInfo + 2},
+		{level: "audit-log+1", want: LevelInfo + 3},
+		{level: "error+x", want: LevelError, wantErr: true},
+		{level: "verbose", want: LevelInfo, wantErr: true},
+		{level: "verbose-1", want: LevelInfo, wantErr: true},
+		{level: "", want: LevelInfo, wantErr: true},
+	}
+	for _, tt := range tests {
+		if got := tt.level.Level(); got != tt.want {
+			t.Errorf("SLevel(%q).Level() = %v, want %v", tt.level, got, tt.want)
+		}
+		got, err := ParseLevelStrict(tt.level)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseLevelStrict(%q) = %v, %v, want %v", tt.level, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseLevelErr(t *testing.T) {
+	if level, err := ParseLevelErr(SLevelWarn); err != nil || level != LevelWarn {
+		t.Errorf("ParseLevelErr(warn) = %v, %v, want WARN", level, err)
+	}
+	if _, err := ParseLevelErr("debag"); err == nil {
+		t.Error("ParseLevelErr(debag) = nil error, want an error")
+	}
+
+	var reported error
+	SetErrorHandler(func(err error) { reported = err })
+	defer SetErrorHandler(nil)
+	cfg := Config{Level: "debag"}
+	if level := cfg.HandlerOptions().Level.Level(); level != LevelInfo || reported == nil {
+		t.Errorf("HandlerOptions() level = %v, reported %v, want INFO and an error", level, reported)
+	}
+	if _, err := NewE(cfg); err == nil {
+		t.Error("NewE() = nil error, want the unknown level")
+	}
+
+	l := New(Config{Level: SLevelWarn})
+	if err := cfg.Apply(l); err == nil || l.Enabled(LevelInfo) {
+		t.Errorf("Apply() = %v, want an error and the level unchanged", err)
+	}
+}
+
+func TestRegisterLevelAlias(t *testing.T) {
+	RegisterLevel(" NOTICE ", LevelInfo+1)
+	RegisterLevel("fatal", LevelError+4)
+	RegisterLevelAlias("Verbose-Debug", SLevelDebug)
+
+	tests := []struct {
+		level SLevel
+		want  Level
+	}{
+		{level: "WARNING", want: LevelWarn},
+		{level: "Err", want: LevelError},
+		{level: "critical", want: LevelError + 4},
+		{level: "Notice", want: LevelInfo + 1},
+		{level: "verbose-debug", want: LevelDebug},
+		{level: "warning+1", want: LevelWarn + 1},
+	}
+	for _, tt := range tests {
+		if got, err := ParseLevelStrict(tt.level); err != nil || got != tt.want {
+			t.Errorf("ParseLevelStrict(%q) = %v, %v, want %v", tt.level, got, err, tt.want)
+		}
+		if got := tt.level.Level(); got != tt.want {
+			t.Errorf("SLevel(%q).Level() = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+	if got := ParseLevel("ERROR"); got != LevelError {
+		t.Errorf("ParseLevel(ERROR) = %v, want ERROR", got)
+	}
+	if got, err := ParseLevelErr("Warning"); err != nil || got != LevelWarn {
+		t.Errorf("ParseLevelErr(Warning) = %v, %v, want WARN", got, err)
+	}
+	if got := LevelToSLevel(LevelWarn); got != SLevelWarn {
+		t.Errorf("LevelToSLevel(WARN) = %q, want the canonical name", got)
+	}
+
+	RegisterLevelAlias("mistake", "missing")
+	if _, err := ParseLevelErr("mistake"); err == nil {
+		t.Error("ParseLevelErr(mistake) = nil error, want the alias of an unregistered level")
+	}
+}
+
+func TestLevelToSLevel(t *testing.T) {
+	RegisterLevel("emerg", LevelError+12)
+
+	tests := []struct {
+		level Level
+		want  SLevel
+	}{
+		{level: LevelWarn, want: SLevelWarn},
+		{level: LevelTrace, want: SLevelTrace},
+		{level: LevelWarn + 1, want: "warn+1"},
+		{level: LevelTrace - 2, want: "trace-2"},
+		{level: LevelError + 12, want: "emerg"},
+		{level: LevelError + 13, want: "emerg+1"},
+	}
+	for _, tt := range tests {
+		if got := LevelToSLevel(tt.level); got != tt.want {
+			t.Errorf("LevelToSLevel(%v) = %q, want %q", tt.level, got, tt.want)
+		}
+		if got := LevelToSLevel(tt.level).Level(); got != tt.level {
+			t.Errorf("LevelToSLevel(%v).Level() = %v", tt.level, got)
+		}
+	}
+
+	for _, format := range []string{"text", "log"} {
+		var buf bytes.Buffer
+		New(Config{Format: format, DisableColor: true}, &buf).Log(LevelError+12, "msg")
+		if got := buf.String(); !strings.Contains(got, "EMERG") {
+			t.Errorf("%s: output = %q, want the EMERG level", format, got)
+		}
+	}
+}
+
+func TestSLevel_UnmarshalText(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"level":"WARN+2"}`), &cfg); err != nil || cfg.Level != "warn+2" {
+		t.Errorf("Unmarshal() level = %q, %v, want warn+2", cfg.Level, err)
+	}
+	if err := json.Unmarshal([]byte(`{"level":"nope"}`), &cfg); err == nil {
+		t.Error("Unmarshal(nope) = nil error, want an error")
+	}
+
+	b, err := json.Marshal(Config{Level: LevelToSLevel(LevelError + 2)})
+	if err != nil || string(b) != `{"level":"error+2"}` {
+		t.Errorf("Marshal() = %s, %v, want the error+2 level", b, err)
+	}
+	if _, err := json.Marshal(Config{Level: "nope"}); err == nil {
+		t.Error("Marshal(nope) = nil error, want an error")
+	}
+}
+
+func TestRegisterLevel_Concurrent(t *testing.T) {
+	width, registry := levelWidth.Load(), levels.Load()
+	defer func() {
+		levels.Store(registry)
+		levelWidth.Store(width)
+	}()
+
+	var buf bytes.Buffer
+	l := New(Config{Format: "log", DisableColor: true}, &buf)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			RegisterLevel(SLevel("custom"+strconv.Itoa(i)), LevelError+Level(100+i))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		l.Log(LevelError+Level(100+i), "msg")
+		_ = ParseLevel("warn")
+	}
+	<-done
+	if got := LevelToSLevel(LevelError + 199); got != "custom99" {
+		t.Errorf("LevelToSLevel() = %q, want custom99", got)
+	}
+}
+
+// BenchmarkLevelToSLevel_Parallel looks up the names of the levels on
+// every record, like the text handlers do, without contending on a lock.
+func BenchmarkLevelToSLevel_Parallel(b *testing.B) {
+	RegisterLevel("emerg", LevelError+12)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = LevelToSLevel(LevelError + 12)
+			_ = ParseLevel(SLevelWarn)
+		}
+	})
+}
+
+func TestRegisterLevelStyle(t *testing.T) {
+	width, registry := levelWidth.Load(), levels.Load()
+	defer func() {
+		levels.Store(registry)
+		levelWidth.Store(width)
+	}()
+	RegisterLevel("security", LevelWarn+2)
+	RegisterLevelStyle("security", "SEC", "\x1b[35m")
+
+	var buf bytes.Buffer
+	NewLogger(NewLogHandler(&buf, nil, false)).Log(LevelWarn+2, "msg")
+	if got, want := buf.String(), "\x1b[35mSEC\x1b[0m["; !strings.HasPrefix(got, want) {
+		t.Errorf("log output = %q, want prefix %q", got, want)
+	}
+
+	buf.Reset()
+	l := New(Config{Format: "json"}, &buf)
+	l.Log(LevelWarn+2, "msg")
+	l.Log(LevelWarn+3, "msg")
+	if got := buf.String(); !strings.Contains(got, `"level":"SEC"`) || !strings.Contains(got, `"level":"WARN+3"`) {
+		t.Errorf("json output = %q, want the SEC label and the offset level unchanged", got)
+	}
+
+	// the label is renamed and lower-cased by the preset
+	buf.Reset()
+	New(Config{Format: "json", Preset: PresetECS}, &buf).Log(LevelWarn+2, "msg")
+	if got := buf.String(); !strings.Contains(got, `"log.level":"sec"`) {
+		t.Errorf("ecs output = %q, want the sec label", got)
+	}
+	buf.Reset()
+	New(Config{Format: "text", LevelField: "severity"}, &buf).Log(LevelWarn+2, "msg")
+	if got := buf.String(); !strings.Contains(got, " severity=SEC ") {
+		t.Errorf("text output = %q, want the SEC label", got)
+	}
+
+	var reported error
+	SetErrorHandler(func(err error) { reported = err })
+	defer SetErrorHandler(nil)
+	RegisterLevelStyle("nope", "NOPE", "")
+	if reported == nil {
+		t.Error("RegisterLevelStyle(nope) reported no error, want the unknown level")
+	}
+}
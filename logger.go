@@ -16,9 +16,12 @@ package wslog
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"runtime"
+	"slices"
 	"time"
 )
 
@@ -40,6 +43,16 @@ func NewLoggerSkip(h Handler, skip int) *Logger {
 type Logger struct {
 	handler Handler
 	skip    int
+	ctxErr  bool
+	goid    bool // whether the records have the goroutine ID, see WithGoroutineID
+	swallow bool // whether Recover swallows the panics
+	name    string
+
+	correlationID string
+	rotators      []Rotator   // the rotators owned by the Logger, see New
+	closers       []io.Closer // the other writers owned by the Logger, see New
+	level         *LevelVar   // the level of the Logger created by New, see Config.Apply
+	timer         *logTimer   // the timer of WithTimer, nil if none
 }
 
 func (l *Logger) clone() *Logger {
@@ -49,15 +62,178 @@ func (l *Logger) clone() *Logger {
 
 func (l *Logger) Handler() Handler { return l.handler }
 
+// Reopener is implemented by the writers whose files can be reopened, e.g. *Writer.
+type Reopener interface {
+	Reopen() error
+}
+
+// Rotators returns the Rotators owned by the Logger, which are the files
+// opened by New for the Config and the Rotator passed to New.
+func (l *Logger) Rotators() []Rotator {
+	return slices.Clone(l.rotators)
+}
+
+// Reopen reopens the Rotators and the other writers of the Logger
+// implementing Reopener, e.g. after logrotate moved the files away.
+// The writes are blocked while a file is reopened. See also EnableReopenOnSignal.
+func (l *Logger) Reopen() error {
+	var errs []error
+	for _, rotator := range l.rotators {
+		if r, ok := rotator.(Reopener); ok {
+			if err := r.Reopen(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, c := range l.closers {
+		if r, ok := c.(Reopener); ok {
+			if err := r.Reopen(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sync flushes the handler of the Logger and the writer of Config.Output
+// if they implement Flusher, and commits the Rotators implementing Syncer
+// to stable storage, e.g. before a graceful shutdown.
+func (l *Logger) Sync() error {
+	var errs []error
+	if f, ok := l.handler.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, c := range l.closers {
+		if f, ok := c.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	for _, rotator := range l.rotators {
+		if s, ok := rotator.(Syncer); ok {
+			if err := s.Sync(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the Rotators of the Logger and the writer of Config.Output,
+// which are shared by the Loggers derived from l. The other writers and
+// handlers passed to New are left open.
+func (l *Logger) Close() error {
+	var errs []error
+	for _, rotator := range l.rotators {
+		if err := rotator.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (l *Logger) With(args ...any) *Logger {
+	return l.with(args)
+}
+
+// with implements With, it must be called directly by an exported
+// function, because it uses a fixed call depth to report misused args.
+func (l *Logger) with(args []any) *Logger {
 	if len(args) == 0 {
 		return l
 	}
+	// skip [runtime.Callers, this function, this function's caller]
+	checkBadKeys(args, 3)
 	c := l.clone()
 	c.handler = l.handler.WithAttrs(argsToAttrSlice(args))
 	return c
 }
 
+// WithError returns a Logger that includes the given error
+// as an attribute with the key ErrorKey in each output.
+// If err is nil, WithError returns the receiver.
+func (l *Logger) WithError(err error) *Logger {
+	if err == nil {
+		return l
+	}
+	return l.With(ErrorKey, err)
+}
+
+// WithCallerSkip returns a Logger that skips delta more stack frames
+// when looking up the source of a log call, so that helpers wrapping
+// the Logger can report the source of their own callers.
+func (l *Logger) WithCallerSkip(delta int) *Logger {
+	if delta == 0 {
+		return l
+	}
+	c := l.clone()
+	c.skip += delta
+	return c
+}
+
+// Named returns a Logger that adds an attribute with the key LoggerKey
+// and the given name to each record. Successive calls are joined with dots,
+// so Named("a").Named("b") yields the name `a.b`.
+// Unlike WithGroup, the name does not qualify other keys.
+// If the name is empty, Named returns the receiver.
+func (l *Logger) Named(name string) *Logger {
+	if name == "" {
+		return l
+	}
+	c := l.clone()
+	if c.name != "" {
+		c.name += "." + name
+	} else {
+		c.name = name
+	}
+	return c
+}
+
+// Name returns the name of the Logger set by Named.
+func (l *Logger) Name() string { return l.name }
+
+// WithContextError returns a Logger that appends an attribute with the key
+// ContextErrorKey to each record logged with a canceled or expired context.
+func (l *Logger) WithContextError(enabled bool) *Logger {
+	c := l.clone()
+	c.ctxErr = enabled
+	return c
+}
+
+// WithOptions returns a Logger whose handler is rebuilt
+// with the HandlerOptions modified by mutate, e.g. to enable AddSource
+// for a single subsystem. Attrs already added by With are kept as is.
+// It only works for the default log handler created by NewLogHandler,
+// optionally wrapped by the handlers that New adds from the Config.
+// For any other Handler, WithOptions returns the receiver.
+func (l *Logger) WithOptions(mutate func(opts *HandlerOptions)) *Logger {
+	handler, ok := handlerWithOptions(l.handler, mutate)
+	if !ok {
+		return l
+	}
+	c := l.clone()
+	c.handler = handler
+	return c
+}
+
+// WithSource returns a Logger whose handler adds the source of the
+// records if enabled, e.g. to turn it off for a noisy subsystem while
+// it is on globally. Like WithOptions, it only works for the default
+// log handler, for any other Handler it returns the receiver.
+func (l *Logger) WithSource(enabled bool) *Logger {
+	return l.WithOptions(func(opts *HandlerOptions) {
+		opts.AddSource = enabled
+	})
+}
+
 // WithGroup returns a Logger that starts a group if the name is non-empty.
 // The keys of all attributes added to the Logger will be qualified by the given
 // name. (How that qualification happens depends on the [Handler.WithGroup]
@@ -114,6 +290,52 @@ func (l *Logger) LogAttrs(level Level, msg string, attrs ...Attr) {
 	l.logAttrs(emptyCtx, level, msg, attrs...)
 }
 
+// LogAttrsPC is like [Logger.LogAttrsCtx], but uses pc as the source of the
+// record instead of looking up the caller, so that wrappers can report the
+// source of their own callers, e.g. a PC of [runtime.Callers].
+// A zero pc records no source, see [NewFrameRecord] for a [runtime.Frame].
+func (l *Logger) LogAttrsPC(ctx context.Context, level Level, pc uintptr, msg string, attrs ...Attr) {
+	if !l.EnabledCtx(ctx, level) {
+		return
+	}
+	l.logAttrsPC(ctx, level, pc, msg, attrs...)
+}
+
+// NewFrameRecord creates a Record whose source is frame.
+// The PC of a Frame is the one of the call instruction, while the PC of
+// a Record is a return address as reported by [runtime.Callers].
+func NewFrameRecord(t time.Time, level Level, msg string, frame runtime.Frame) Record {
+	var pc uintptr
+	if frame.PC != 0 {
+		pc = frame.PC + 1
+	}
+	return slog.NewRecord(t, level, msg, pc)
+}
+
+// LogRecord emits r as is, keeping its time and PC, with the
+// attributes of the Logger added like the other log methods.
+func (l *Logger) LogRecord(ctx context.Context, r Record) {
+	if !l.EnabledCtx(ctx, r.Level) {
+		return
+	}
+	r = r.Clone()
+	if l.name != "" {
+		r.AddAttrs(slog.String(LoggerKey, l.name))
+	}
+	if l.timer != nil {
+		r.AddAttrs(slog.Any(ElapsedKey, l.timer))
+	}
+	if l.goid {
+		r.AddAttrs(slog.Uint64(GoroutineIDKey, CurrentGoroutineID()))
+	}
+	if ctx == nil {
+		ctx = emptyCtx
+	}
+	addContextAttrs(ctx, &r)
+	l.addContextError(ctx, &r)
+	l.handle(ctx, r)
+}
+
 // Debug logs at LevelDebug.
 func (l *Logger) Debug(msg string, args ...any) {
 	l.log(emptyCtx, LevelDebug, msg, args...)
@@ -174,6 +396,25 @@ func (l *Logger) ErrorCtx(ctx context.Context, msg string, args ...any) {
 	l.log(ctx, LevelError, msg, args...)
 }
 
+// ErrorErr logs at LevelError with the given error
+// as an attribute with the key ErrorKey, which is omitted if err is nil.
+func (l *Logger) ErrorErr(err error, msg string, args ...any) {
+	l.log(emptyCtx, LevelError, msg, appendError(args, err)...)
+}
+
+// ErrorErrCtx logs at LevelError with the given context and error.
+func (l *Logger) ErrorErrCtx(ctx context.Context, err error, msg string, args ...any) {
+	l.log(ctx, LevelError, msg, appendError(args, err)...)
+}
+
+func appendError(args []any, err error) []any {
+	if err == nil {
+		return args
+	}
+	// args may be the slice of the caller
+	return append(slices.Clip(args), slog.Any(ErrorKey, err))
+}
+
 // log is the low-level logging method for methods that take ...any.
 // It must always be called directly by an exported logging method
 // or function, because it uses a fixed call depth to obtain the pc.
@@ -182,17 +423,32 @@ func (l *Logger) log(ctx context.Context, level Level, msg string, args ...any)
 		return
 	}
 
-	var pcs [1]uintptr
-	// skip [runtime.Callers, this function, this function's caller]
-	runtime.Callers(l.skip, pcs[:])
-	pc := pcs[0]
+	var pc uintptr
+	if needsSource(l.handler) {
+		var pcs [1]uintptr
+		// skip [runtime.Callers, this function, this function's caller]
+		runtime.Callers(l.skip, pcs[:])
+		pc = pcs[0]
+	}
 
 	r := slog.NewRecord(time.Now(), level, msg, pc)
+	if l.name != "" {
+		r.AddAttrs(slog.String(LoggerKey, l.name))
+	}
+	if l.timer != nil {
+		r.AddAttrs(slog.Any(ElapsedKey, l.timer))
+	}
+	if l.goid {
+		r.AddAttrs(slog.Uint64(GoroutineIDKey, CurrentGoroutineID()))
+	}
+	checkBadKeys(args, l.skip)
 	r.Add(args...)
 	if ctx == nil {
 		ctx = emptyCtx
 	}
-	_ = l.Handler().Handle(ctx, r)
+	addContextAttrs(ctx, &r)
+	l.addContextError(ctx, &r)
+	l.handle(ctx, r)
 }
 
 // logAttrs is like [Logger.log], but for methods that take ...Attr.
@@ -201,15 +457,51 @@ func (l *Logger) logAttrs(ctx context.Context, level Level, msg string, attrs ..
 		return
 	}
 
-	var pcs [1]uintptr
-	// skip [runtime.Callers, this function, this function's caller]
-	runtime.Callers(l.skip, pcs[:])
-	pc := pcs[0]
+	var pc uintptr
+	if needsSource(l.handler) {
+		var pcs [1]uintptr
+		// skip [runtime.Callers, this function, this function's caller]
+		runtime.Callers(l.skip, pcs[:])
+		pc = pcs[0]
+	}
+	l.logAttrsPC(ctx, level, pc, msg, attrs...)
+}
 
+// logAttrsPC emits a record with the source pc, the caller must check EnabledCtx.
+func (l *Logger) logAttrsPC(ctx context.Context, level Level, pc uintptr, msg string, attrs ...Attr) {
 	r := slog.NewRecord(time.Now(), level, msg, pc)
+	if l.name != "" {
+		r.AddAttrs(slog.String(LoggerKey, l.name))
+	}
+	if l.timer != nil {
+		r.AddAttrs(slog.Any(ElapsedKey, l.timer))
+	}
+	if l.goid {
+		r.AddAttrs(slog.Uint64(GoroutineIDKey, CurrentGoroutineID()))
+	}
 	r.AddAttrs(attrs...)
 	if ctx == nil {
 		ctx = emptyCtx
 	}
-	_ = l.Handler().Handle(ctx, r)
+	addContextAttrs(ctx, &r)
+	l.addContextError(ctx, &r)
+	l.handle(ctx, r)
+}
+
+// handle passes r to the handler, reporting its error
+// to the func set by SetErrorHandler.
+func (l *Logger) handle(ctx context.Context, r Record) {
+	if err := l.Handler().Handle(ctx, r); err != nil {
+		reportError(err)
+	}
+}
+
+// addContextError adds the error of ctx to r if enabled by WithContextError.
+func (l *Logger) addContextError(ctx context.Context, r *Record) {
+	if !l.ctxErr || ctx == emptyCtx {
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		r.AddAttrs(slog.Any(ContextErrorKey, err))
+	}
 }
@@ -0,0 +1,590 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func logHelper(l *Logger, msg string) {
+	l.Info(msg)
+}
+
+func TestLogger_WithCallerSkip(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, &HandlerOptions{AddSource: true}, true, WithSourceFormat(SourceShort))
+	l := NewLogger(h).WithCallerSkip(1)
+
+	_, file, line, _ := runtime.Caller(0)
+	logHelper(l, "msg")
+
+	want := `source="` + filepath.Base(file) + ":" + strconv.Itoa(line+1) + `"`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("WithCallerSkip() = %q, want %s", got, want)
+	}
+}
+
+func TestLogger_LogAttrsPC(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, &HandlerOptions{AddSource: true}, true, WithSourceFormat(SourceShort))
+	l := NewLogger(h).Named("app")
+
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_, file, line, _ := runtime.Caller(0)
+	want := `source="` + filepath.Base(file) + ":" + strconv.Itoa(line-1) + `"`
+
+	l.LogAttrsPC(context.Background(), LevelInfo, pcs[0], "pc")
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	l.LogRecord(context.Background(), NewFrameRecord(time.Now(), LevelInfo, "frame", frame))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("output = %q, want 2 lines", buf.String())
+	}
+	for _, got := range lines {
+		if !strings.Contains(got, want) || !strings.Contains(got, LoggerKey+"=app") {
+			t.Errorf("output = %q, want %s", got, want)
+		}
+	}
+}
+
+func TestLogger_ContextLevel(t *testing.T) {
+	for _, format := range []string{"", "json"} {
+		var buf bytes.Buffer
+		l := New(Config{Format: format, Level: SLevelWarn}, &buf)
+		ctx := WithLevel(context.Background(), LevelDebug)
+
+		l.Info("dropped")
+		l.DebugCtx(ctx, "traced")
+		if !l.EnabledCtx(ctx, LevelDebug) || l.EnabledCtx(ctx, LevelDebug-1) {
+			t.Errorf("%q: EnabledCtx() ignores the level override", format)
+		}
+
+		got := buf.String()
+		if strings.Contains(got, "dropped") || !strings.Contains(got, "traced") {
+			t.Errorf("%q: output = %q, want only the traced record", format, got)
+		}
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Level: SLevelWarn}, &buf)
+	l.WarnCtx(WithLevel(context.Background(), LevelError), "kept")
+	if !strings.Contains(buf.String(), "kept") {
+		t.Errorf("output = %q, want a higher override ignored", buf.String())
+	}
+
+	ctx := WithLevel(context.Background(), LevelDebug)
+	if LevelEnabled(nil, LevelDebug, nil) || LevelEnabled(context.Background(), LevelDebug, nil) {
+		t.Error("LevelEnabled() = true without an override, want false")
+	}
+	if !LevelEnabled(ctx, LevelDebug, LevelError) || LevelEnabled(ctx, LevelTrace, nil) {
+		t.Error("LevelEnabled() ignores the override")
+	}
+	if level, ok := LevelFromContext(ContextWithLevel(context.Background(), LevelDebug)); !ok || level != LevelDebug {
+		t.Errorf("LevelFromContext(ContextWithLevel()) = %v, %v, want the override", level, ok)
+	}
+}
+
+func TestLogger_WithCorrelationID(t *testing.T) {
+	defer func(fn func() string) { NewCorrelationID = fn }(NewCorrelationID)
+	NewCorrelationID = func() string { return "id1" }
+
+	var buf bytes.Buffer
+	l := NewLogger(NewLogHandler(&buf, nil, true)).WithCorrelationID()
+	ctx := WithContext(context.Background(), l)
+	if id := CorrelationIDFromContext(ctx); id != "id1" {
+		t.Errorf("CorrelationIDFromContext() = %q, want id1", id)
+	}
+	FromContext(ctx).Info("msg")
+	if got := buf.String(); !strings.Contains(got, CorrelationIDKey+"=id1") {
+		t.Errorf("output = %q, want the correlation ID", got)
+	}
+}
+
+type requestIDKey struct{}
+
+func TestRegisterContextAttr(t *testing.T) {
+	RegisterContextAttr(requestIDKey{}, "req")
+	RegisterContextAttr(requestIDKey{}, "request_id")
+
+	var buf bytes.Buffer
+	l := NewLogger(NewJSONHandler(&buf, nil, ""))
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	l.InfoCtx(ctx, "with")
+	l.LogAttrsCtx(context.Background(), LevelInfo, "without")
+	l.Info("none")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %q, want 3 records", buf.String())
+	}
+	if !strings.Contains(lines[0], `"request_id":"abc"`) || strings.Contains(lines[0], `"req"`) {
+		t.Errorf("got %q, want request_id", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if strings.Contains(line, "request_id") {
+			t.Errorf("got %q, want no request_id", line)
+		}
+	}
+}
+
+func TestLogger_WithTimer(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewJSONHandler(&buf, nil, "")).WithTimer().With("a", 1)
+	time.Sleep(20 * time.Millisecond)
+	l.Info("first")
+	l.Lap("lap")
+	l.Info("second")
+
+	var elapsed []time.Duration
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(line, `"`+ElapsedKey+`"`) != 1 {
+			t.Fatalf("record = %q, want one %s", line, ElapsedKey)
+		}
+		elapsed = append(elapsed, time.Duration(m[ElapsedKey].(float64)))
+	}
+	if len(elapsed) != 3 || elapsed[0] < 20*time.Millisecond || elapsed[1] < elapsed[0] || elapsed[2] >= elapsed[1] {
+		t.Errorf("elapsed = %v, want the lap to reset the timer", elapsed)
+	}
+}
+
+func TestLogger_WithError(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewLogfmtHandler(&buf, nil, WithDisableTime(true)))
+	if l.WithError(nil) != l {
+		t.Error("WithError(nil) != l, want the receiver")
+	}
+
+	wrapped := fmt.Errorf("read config: %w", os.ErrNotExist)
+	l.WithError(wrapped).Info("with")
+	l.ErrorErr(wrapped, "err", "a", 1)
+	l.ErrorErr(nil, "nil", "a", 1)
+
+	// the args of the caller are not overwritten
+	args := make([]any, 2, 4)
+	args[0], args[1] = "a", 1
+	l.ErrorErr(wrapped, "args", args...)
+	if args = args[:4]; args[2] != nil {
+		t.Errorf("args = %v, want the spare capacity untouched", args)
+	}
+
+	defer func(key string) { ErrorKey = key }(ErrorKey)
+	ErrorKey = "err"
+	l.ErrorErr(wrapped, "key")
+
+	want := `level=INFO msg=with error="read config: file does not exist"
+level=ERROR msg=err a=1 error="read config: file does not exist"
+level=ERROR msg=nil a=1
+level=ERROR msg=args a=1 error="read config: file does not exist"
+level=ERROR msg=key err="read config: file does not exist"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestLogger_WithContextError(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewLogfmtHandler(&buf, nil, WithDisableTime(true)))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	l.InfoCtx(canceled, "disabled")
+	c := l.WithContextError(true)
+	c.InfoCtx(canceled, "canceled")
+	c.InfoCtx(expired, "expired")
+	c.LogAttrsCtx(canceled, LevelWarn, "attrs", slog.Int("a", 1))
+	c.InfoCtx(context.Background(), "background")
+	c.Info("no context")
+	c.WithContextError(false).InfoCtx(canceled, "disabled again")
+
+	want := `level=INFO msg=disabled
+level=INFO msg=canceled ctx_err="context canceled"
+level=INFO msg=expired ctx_err="context deadline exceeded"
+level=WARN msg=attrs a=1 ctx_err="context canceled"
+level=INFO msg=background
+level=INFO msg="no context"
+level=INFO msg="disabled again"
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestLogger_WithGoroutineID(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "logfmt", GoroutineID: true}, &buf)
+	done := make(chan uint64)
+	go func() {
+		l.Info("child")
+		done <- CurrentGoroutineID()
+	}()
+	child := <-done
+	l.Info("parent")
+	l.WithGoroutineID(false).Info("disabled")
+
+	parent := CurrentGoroutineID()
+	if parent == 0 || child == 0 || parent == child {
+		t.Fatalf("CurrentGoroutineID() = %d and %d, want distinct IDs", parent, child)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		"msg=child goid=" + strconv.FormatUint(child, 10),
+		"msg=parent goid=" + strconv.FormatUint(parent, 10),
+	}
+	if len(lines) != 3 || !strings.Contains(lines[0], want[0]) || !strings.Contains(lines[1], want[1]) || strings.Contains(lines[2], "goid") {
+		t.Errorf("output = %q, want %q", lines, want)
+	}
+}
+
+func BenchmarkLogger_WithGoroutineID(b *testing.B) {
+	for _, enabled := range []bool{false, true} {
+		b.Run(strconv.FormatBool(enabled), func(b *testing.B) {
+			l := NewLogger(NewLogfmtHandler(io.Discard, nil)).WithGoroutineID(enabled)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				l.Info("benchmark", "id", i)
+			}
+		})
+	}
+}
+
+func TestNew_LevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{
+		Format:        "logfmt",
+		Filename:      filepath.Join(dir, "app.log"),
+		ErrorFilename: filepath.Join(dir, "app.err.log"),
+		LevelFiles:    map[SLevel]string{SLevelWarn: filepath.Join(dir, "app.warn.log")},
+	}
+	l := New(cfg)
+	l.Info("info")
+	// the level override of the context only widens the main file
+	l.DebugCtx(WithLevel(context.Background(), LevelDebug), "traced")
+	l.With("a", 1).Warn("warn")
+	l.Error("error")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"app.log":      {"info", "traced", "warn", "error"},
+		"app.warn.log": {"warn", "error"},
+		"app.err.log":  {"error"},
+	}
+	for name, want := range tests {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("%s = %q, want %d records", name, b, len(want))
+		}
+		for i, msg := range want {
+			if !strings.Contains(lines[i], "msg="+msg) {
+				t.Errorf("%s record %d = %q, want msg=%s", name, i, lines[i], msg)
+			}
+		}
+	}
+}
+
+func TestConfig_Apply(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := Config{Format: "logfmt", Level: SLevelWarn}
+	l := New(cfg, &buf)
+	derived := l.With("a", 1)
+
+	derived.Info("dropped")
+	cfg.Level = SLevelDebug
+	if err := cfg.Apply(l); err != nil {
+		t.Fatal(err)
+	}
+	derived.Debug("kept")
+	if got := buf.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "msg=kept a=1") {
+		t.Errorf("output = %q, want only the record after Apply", got)
+	}
+
+	if err := cfg.Apply(New(cfg, LevelInfo)); err == nil {
+		t.Error("Apply() without a LevelVar = nil, want an error")
+	}
+}
+
+func TestConfig_SortKeys(t *testing.T) {
+	attrs := []any{
+		"c", 1,
+		slog.Group("b", "z", 1, slog.Group("y", "k2", 1, "k1", 2), "x", 3),
+		"a", 1,
+		slog.Group("", "d", 1, "aa", 2),
+		"c", 2,
+	}
+	tests := map[string]string{
+		"logfmt": " a=1 aa=2 b.x=3 b.y.k1=2 b.y.k2=1 b.z=1 c=1 c=2 d=1\n",
+		"json":   `,"a":1,"aa":2,"b":{"x":3,"y":{"k1":2,"k2":1},"z":1},"c":1,"c":2,"d":1}` + "\n",
+	}
+	for format, want := range tests {
+		var buf bytes.Buffer
+		New(Config{Format: format, SortKeys: true}, &buf).Info("msg", attrs...)
+		if got := buf.String(); !strings.HasSuffix(got, want) {
+			t.Errorf("%s: output = %q, want suffix %q", format, got, want)
+		}
+	}
+}
+
+func TestSwapDefault(t *testing.T) {
+	prev := Default()
+	var buf bytes.Buffer
+	restore := SwapDefault(NewLogger(NewLogHandler(&buf, nil, true)))
+	Info("captured")
+	restore()
+	if Default() != prev {
+		t.Error("restore() didn't restore the previous default Logger")
+	}
+	if !strings.Contains(buf.String(), "captured") {
+		t.Errorf("Info() = %q, want the record to be captured", buf.String())
+	}
+}
+
+func TestConfig_Fields(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skip(err)
+	}
+	pid := strconv.Itoa(os.Getpid())
+	tests := map[string]string{
+		"":     "env=prod service=api host=" + hostname + " pid=" + pid + " g.a=1",
+		"text": "env=prod service=api host=" + hostname + " pid=" + pid + " g.a=1",
+		"json": `"env":"prod","service":"api","host":"` + hostname + `","pid":` + pid + `,"g":{"a":1}`,
+	}
+	for format, want := range tests {
+		var buf bytes.Buffer
+		cfg := Config{
+			Format:       format,
+			DisableColor: true,
+			Fields:       map[string]any{"service": "api", "env": "prod"},
+			AddHostname:  true,
+			AddPID:       true,
+		}
+		New(cfg, &buf).WithGroup("g").Info("msg", "a", 1)
+		if got := buf.String(); !strings.Contains(got, want) {
+			t.Errorf("%q: output = %q, want %s", format, got, want)
+		}
+	}
+}
+
+func TestNew_Options(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(LevelVar)
+	level.Set(LevelDebug)
+	l := New(Config{Format: "logfmt"},
+		WithWriter(&buf),
+		WithLeveler(level),
+		WithReplaceAttr(func(groups []string, a Attr) Attr {
+			if a.Key == "secret" {
+				a.Value = slog.StringValue("***")
+			}
+			return a
+		}),
+	)
+	l.Debug("msg", "secret", "password")
+	if got := buf.String(); !strings.Contains(got, `msg=msg secret="***"`) {
+		t.Errorf("output = %q, want the debug record with the replaced attr", got)
+	}
+
+	var reported error
+	SetErrorHandler(func(err error) { reported = err })
+	defer SetErrorHandler(nil)
+	buf.Reset()
+	New(Config{Format: "logfmt"}, &buf, HandlerOptions{}).Info("msg")
+	if reported == nil || !strings.Contains(reported.Error(), "unknown option slog.HandlerOptions") || buf.Len() == 0 {
+		t.Errorf("reported %v, output %q, want the unknown option and the record", reported, buf.String())
+	}
+	if _, err := NewE(Config{}, 42); err == nil {
+		t.Error("NewE() with an unknown option = nil error, want an error")
+	}
+}
+
+func TestSetBadKeyMode(t *testing.T) {
+	defer SetBadKeyMode(BadKeySilent)
+	var buf bytes.Buffer
+	l := New(Config{Format: "logfmt"}, &buf)
+
+	SetBadKeyMode(BadKeyPanic)
+	for _, fn := range []func(){
+		func() { l.Info("msg", "k", "v", 42) },
+		func() { l.With("k") },
+		func() { With(slog.String("k", "v"), 1) },
+	} {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, "logger_test.go") {
+					t.Errorf("panic = %q, want the call site", msg)
+				}
+			}()
+			fn()
+		}()
+	}
+	l.With(slog.Int("a", 1)).Info("msg", "k", "v")
+
+	SetBadKeyMode(BadKeyWarn)
+	name := swapStdFile(t, &os.Stderr, filepath.Join(t.TempDir(), "stderr"))
+	for i := 0; i < 2; i++ {
+		l.Info("msg", 42)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); strings.Count(got, "has no key") != 1 || !strings.Contains(got, "logger_test.go") {
+		t.Errorf("stderr = %q, want one warning", got)
+	}
+	if got := buf.String(); !strings.Contains(got, BadKey+"=42") {
+		t.Errorf("output = %q, want the %s attr", got, BadKey)
+	}
+}
+
+func TestLogger_InfoEvery(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "logfmt", Source: true}, &buf)
+	for i := 0; i < 7; i++ {
+		l.InfoEvery(3, "progress", "i", i)
+		l.InfoEvery(5, "progress", "i", i)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"i=0 occurrence=1", "i=0 occurrence=1", "i=3 occurrence=4", "i=5 occurrence=6", "i=6 occurrence=7"}
+	if len(lines) != len(want) {
+		t.Fatalf("output = %q, want %d records", buf.String(), len(want))
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, want[i]) || !strings.Contains(line, "logger_test.go") {
+			t.Errorf("record %d = %q, want the source and %q", i, line, want[i])
+		}
+	}
+}
+
+func TestLogger_WithOptions(t *testing.T) {
+	var buf bytes.Buffer
+	// wrapped by the redact and the dedup handlers
+	cfg := Config{Format: "logfmt", DisableTime: true, RedactKeys: []string{"password"}, DuplicateKeys: "keep-last"}
+	l := New(cfg, &buf).With("a", 1)
+	verbose := l.WithOptions(func(opts *HandlerOptions) {
+		opts.Level = LevelDebug
+		next := opts.ReplaceAttr
+		opts.ReplaceAttr = func(groups []string, a Attr) Attr {
+			if next != nil {
+				a = next(groups, a)
+			}
+			if a.Key == "user" {
+				a.Value = slog.StringValue(strings.ToUpper(a.Value.String()))
+			}
+			return a
+		}
+	})
+	l.Debug("dropped")
+	verbose.Debug("debug", "user", "admin", "password", "secret", "a", 2)
+	l.Info("info", "user", "admin")
+
+	want := `level=DEBUG msg=debug a=2 user=ADMIN password="***"
+level=INFO msg=info a=1 user=admin
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	other := NewLogger(slog.NewTextHandler(io.Discard, nil))
+	if got := other.WithOptions(func(opts *HandlerOptions) { opts.AddSource = true }); got != other {
+		t.Error("WithOptions() of a slog handler returned a new Logger, want the receiver")
+	}
+	if got := NewLogger(NewRateLimitHandler(NewLogfmtHandler(io.Discard, nil), nil)); got.WithSource(true) != got {
+		t.Error("WithOptions() of an unsupported wrapper returned a new Logger, want the receiver")
+	}
+}
+
+func TestLogger_WithSource(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "logfmt", Source: true}, &buf).With("a", 1)
+	quiet := l.WithSource(false)
+	quiet.Info("quiet")
+	l.Info("loud")
+	quiet.WithSource(true).Info("again")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || strings.Contains(lines[0], SourceKey+"=") ||
+		!strings.Contains(lines[1], SourceKey+"=") || !strings.Contains(lines[2], SourceKey+"=") {
+		t.Errorf("output = %q, want the source only in the loud records", buf.String())
+	}
+	if !strings.Contains(lines[0], "a=1") {
+		t.Errorf("output = %q, want the attrs kept", lines[0])
+	}
+
+	other := NewLogger(slog.NewTextHandler(io.Discard, nil))
+	if got := other.WithSource(true); got != other {
+		t.Error("WithSource() of a slog handler returned a new Logger, want the receiver")
+	}
+}
+
+type errHandler struct{ Handler }
+
+func (errHandler) Handle(context.Context, Record) error { return io.ErrShortWrite }
+
+func TestLogger_HandleError(t *testing.T) {
+	var reported []error
+	SetErrorHandler(func(err error) { reported = append(reported, err) })
+	defer SetErrorHandler(nil)
+
+	l := NewLogger(errHandler{slog.NewTextHandler(io.Discard, nil)})
+	l.Info("msg")
+	l.LogAttrs(LevelWarn, "msg")
+	l.LogRecord(context.Background(), slog.NewRecord(time.Now(), LevelError, "msg", 0))
+	if len(reported) != 3 || reported[0] != io.ErrShortWrite {
+		t.Errorf("reported = %v, want 3 short writes", reported)
+	}
+
+	name := swapStdFile(t, &os.Stderr, filepath.Join(t.TempDir(), "stderr"))
+	fn := newStderrErrorHandler(time.Hour)
+	for i := 0; i < 3; i++ {
+		fn(io.ErrShortWrite)
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), "wslog: short write\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// LokiPushPath is the path of the Loki push API.
+	LokiPushPath = "/loki/api/v1/push"
+
+	// LokiOtherLabelValue replaces the values of a dynamic label
+	// once it reached the maximum number of distinct values.
+	LokiOtherLabelValue = "_other"
+)
+
+// LokiOption configures the handler created by NewLokiHandler.
+type LokiOption func(c *lokiClient)
+
+// WithLokiLabels adds static labels to all the streams.
+func WithLokiLabels(labels map[string]string) LokiOption {
+	return func(c *lokiClient) {
+		for name, value := range labels {
+			c.labels[lokiLabelName(name)] = value
+		}
+	}
+}
+
+// WithLokiLabelKeys turns the top-level attrs of the given keys into labels.
+// Each label has at most maxValues distinct values, further values are
+// replaced by LokiOtherLabelValue to bound the number of streams.
+func WithLokiLabelKeys(maxValues int, keys ...string) LokiOption {
+	return func(c *lokiClient) {
+		c.maxLabelValues = maxValues
+		for _, key := range keys {
+			c.labelKeys[key] = lokiLabelName(key)
+		}
+	}
+}
+
+// WithLokiBatch flushes the batched records once they reach size bytes
+// or wait has elapsed since the last flush, 1MiB and 1s by default.
+// A non-positive wait keeps the default one.
+func WithLokiBatch(size int, wait time.Duration) LokiOption {
+	return func(c *lokiClient) {
+		c.batchSize = size
+		if wait > 0 {
+			c.batchWait = wait
+		}
+	}
+}
+
+// WithLokiRetries sets how many times a push failing with a network error,
+// 429 or 5xx is retried with an exponential backoff, 5 by default.
+func WithLokiRetries(retries int) LokiOption {
+	return func(c *lokiClient) {
+		c.retries = retries
+	}
+}
+
+// WithLokiHTTPClient sets the client used to push, http.DefaultClient by default.
+func WithLokiHTTPClient(client *http.Client) LokiOption {
+	return func(c *lokiClient) {
+		c.client = client
+	}
+}
+
+// NewLokiHandler creates a Handler that pushes the records in batches
+// to the Loki server at url, e.g. `http://loki:3100`, as JSON streams.
+// Each record is written as a logfmt line without the time, which is
+// the time of the entry. The streams are labeled with the lower-cased
+// `level`, the static labels and the labels of WithLokiLabelKeys.
+// Failed pushes are reported to the func set by SetErrorHandler.
+// Close the returned handler to flush the pending records.
+func NewLokiHandler(url string, opts *HandlerOptions, options ...LokiOption) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	c := &lokiClient{
+		url:         strings.TrimSuffix(url, "/") + LokiPushPath,
+		client:      http.DefaultClient,
+		labels:      make(map[string]string),
+		labelKeys:   make(map[string]string),
+		labelValues: make(map[string]map[string]struct{}),
+		batchSize:   1 << 20,
+		batchWait:   time.Second,
+		retries:     5,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.batcher = newBatcher("loki", 0, c.batchSize, 0, c.batchWait, c.push)
+
+	cp := *opts
+	cp.ReplaceAttr = func(groups []string, a Attr) Attr {
+		if opts.ReplaceAttr != nil {
+			a = opts.ReplaceAttr(groups, a)
+		}
+		if len(groups) == 0 && a.Key == TimeKey {
+			return Attr{}
+		}
+		return a
+	}
+	line := NewLogfmtHandler(io.Discard, &cp).(*logHandler)
+	return &lokiHandler{client: c, line: line}
+}
+
+type lokiHandler struct {
+	client *lokiClient // shared among all clones of this handler
+	line   *logHandler
+
+	grouped bool              // whether WithGroup was called, attrs are no longer top-level
+	labels  map[string]string // dynamic labels of WithAttrs
+}
+
+// Close implements io.Closer, and flushes the pending records.
+func (h *lokiHandler) Close() error {
+	return h.client.batcher.close()
+}
+
+// Flush implements Flusher, and pushes the pending records.
+func (h *lokiHandler) Flush() error {
+	return h.client.batcher.flushPending()
+}
+
+func (h *lokiHandler) NeedsSource() bool {
+	return h.line.NeedsSource()
+}
+
+func (h *lokiHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.line.Enabled(ctx, level)
+}
+
+func (h *lokiHandler) Handle(ctx context.Context, record Record) error {
+	var buf bytes.Buffer
+	if err := h.line.withWriter(&buf).Handle(ctx, record); err != nil {
+		return err
+	}
+
+	labels := make(map[string]string, len(h.labels)+1)
+	for name, value := range h.labels {
+		labels[name] = value
+	}
+	labels[LevelKey] = strings.ToLower(record.Level.String())
+	if !h.grouped {
+		record.Attrs(func(attr Attr) bool {
+			h.client.addLabel(labels, attr)
+			return true
+		})
+	}
+
+	ts := record.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	return h.client.add(labels, ts, string(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})))
+}
+
+func (h *lokiHandler) WithAttrs(attrs []Attr) Handler {
+	cp := &lokiHandler{
+		client:  h.client,
+		line:    h.line.WithAttrs(attrs).(*logHandler),
+		grouped: h.grouped,
+		labels:  h.labels,
+	}
+	if !h.grouped {
+		cp.labels = make(map[string]string, len(h.labels))
+		for name, value := range h.labels {
+			cp.labels[name] = value
+		}
+		for _, attr := range attrs {
+			h.client.addLabel(cp.labels, attr)
+		}
+	}
+	return cp
+}
+
+func (h *lokiHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	return &lokiHandler{
+		client:  h.client,
+		line:    h.line.WithGroup(name).(*logHandler),
+		grouped: true,
+		labels:  h.labels,
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiEntry is a line of the stream of labels.
+type lokiEntry struct {
+	labels map[string]string
+	ts     time.Time
+	line   string
+}
+
+// lokiClient batches the entries of the streams and pushes them to Loki.
+type lokiClient struct {
+	url            string
+	client         *http.Client
+	labels         map[string]string
+	labelKeys      map[string]string // attr key to label name
+	maxLabelValues int
+	batchSize      int
+	batchWait      time.Duration
+	retries        int
+	batcher        *batcher[lokiEntry]
+
+	mu          sync.Mutex
+	labelValues map[string]map[string]struct{}
+}
+
+// addLabel adds a to labels if its key is one of the label keys.
+func (c *lokiClient) addLabel(labels map[string]string, a Attr) {
+	name, ok := c.labelKeys[a.Key]
+	if !ok {
+		return
+	}
+	value := a.Value.Resolve().String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	values := c.labelValues[name]
+	if values == nil {
+		values = make(map[string]struct{})
+		c.labelValues[name] = values
+	}
+	if _, ok := values[value]; !ok {
+		if c.maxLabelValues > 0 && len(values) >= c.maxLabelValues {
+			value = LokiOtherLabelValue
+		} else {
+			values[value] = struct{}{}
+		}
+	}
+	labels[name] = value
+}
+
+func (c *lokiClient) add(labels map[string]string, ts time.Time, line string) error {
+	for name, value := range c.labels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = value
+		}
+	}
+	return c.batcher.add(lokiEntry{labels: labels, ts: ts, line: line}, len(line))
+}
+
+// push groups the entries by their labels, and sends them to Loki.
+func (c *lokiClient) push(entries []lokiEntry) error {
+	var streams []*lokiStream
+	index := make(map[string]*lokiStream)
+	for _, entry := range entries {
+		key := lokiStreamKey(entry.labels)
+		stream, ok := index[key]
+		if !ok {
+			stream = &lokiStream{Stream: entry.labels}
+			index[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(entry.ts.UnixNano(), 10), entry.line})
+	}
+
+	body, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/json"}}
+	if _, err := postWithRetry(c.client, c.url, header, body, c.retries); err != nil {
+		return fmt.Errorf("loki push failed: %w", err)
+	}
+	return nil
+}
+
+// lokiStreamKey returns the identity of the stream of labels,
+// in the Prometheus label selector form, e.g. `{a="1",b="2"}`.
+func lokiStreamKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// lokiLabelName converts key to a valid label name, which consists of
+// letters, digits and underscores, and must not start with a digit.
+func lokiLabelName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("_"), name...)
+	}
+	return string(name)
+}
@@ -0,0 +1,150 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lokiServer struct {
+	mu       sync.Mutex
+	failures int // number of requests to fail with 429
+	requests int
+	streams  []lokiStream
+}
+
+func (s *lokiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	if r.URL.Path != LokiPushPath {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	var body struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.streams = append(s.streams, body.Streams...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestLokiHandler(t *testing.T) {
+	s := &lokiServer{failures: 1}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	h := NewLokiHandler(srv.URL, nil,
+		WithLokiLabels(map[string]string{"app": "test"}),
+		WithLokiLabelKeys(2, "user"),
+		WithLokiBatch(1<<20, time.Hour),
+	)
+	l := NewLogger(h)
+	for _, user := range []string{"a", "b", "c", "a"} {
+		l.Info("hello", "user", user)
+	}
+	l.With("user", "b").WithGroup("g").Warn("grouped", "user", "d")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.requests != 2 {
+		t.Errorf("requests = %d, want 2 with a retry", s.requests)
+	}
+	want := map[string][]string{
+		`{app="test",level="info",user="a"}`:      {"level=INFO msg=hello user=a", "level=INFO msg=hello user=a"},
+		`{app="test",level="info",user="b"}`:      {"level=INFO msg=hello user=b"},
+		`{app="test",level="info",user="_other"}`: {"level=INFO msg=hello user=c"},
+		`{app="test",level="warn",user="b"}`:      {"level=WARN msg=grouped user=b g.user=d"},
+	}
+	if len(s.streams) != len(want) {
+		t.Fatalf("streams = %v, want %d", s.streams, len(want))
+	}
+	for _, stream := range s.streams {
+		lines, ok := want[lokiStreamKey(stream.Stream)]
+		if !ok {
+			t.Errorf("unexpected stream %v", stream.Stream)
+			continue
+		}
+		if len(stream.Values) != len(lines) {
+			t.Errorf("stream %v = %v, want %v", stream.Stream, stream.Values, lines)
+			continue
+		}
+		for i, value := range stream.Values {
+			if value[1] != lines[i] {
+				t.Errorf("stream %v line %d = %q, want %q", stream.Stream, i, value[1], lines[i])
+			}
+		}
+	}
+}
+
+func TestLokiHandler_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	errs := make(chan error, 1)
+	SetErrorHandler(func(err error) { errs <- err })
+	defer SetErrorHandler(nil)
+
+	h := NewLokiHandler(srv.URL, nil, WithLokiBatch(1, time.Hour))
+	defer h.(io.Closer).Close()
+	NewLogger(h).Info("hello")
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("error = nil, want push failure")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no error reported")
+	}
+}
+
+func TestLokiHandler_ZeroWait(t *testing.T) {
+	s := new(lokiServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	h := NewLokiHandler(srv.URL, nil, WithLokiBatch(1<<20, 0))
+	NewLogger(h).Info("hello")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.streams) != 1 || len(s.streams[0].Values) != 1 {
+		t.Errorf("streams = %v, want the record pushed on Close", s.streams)
+	}
+}
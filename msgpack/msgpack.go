@@ -0,0 +1,298 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package msgpack implements the MessagePack wslog.Codec of wslog.NewBinaryHandler.
+package msgpack
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+const (
+	// ExtTimestamp is the extension type of the timestamps of the spec.
+	ExtTimestamp = -1
+	// ExtDuration is the extension type of the durations,
+	// whose data is the int64 of the nanoseconds in big-endian.
+	ExtDuration = 1
+)
+
+var errShort = errors.New("msgpack: unexpected end of data")
+
+// Codec encodes the values as MessagePack. The integers of KindInt64
+// and KindUint64 are written with the int and uint formats, so that
+// they are decoded back with their kind. The times are written as
+// timestamp extensions, decoded in UTC.
+type Codec struct{}
+
+var _ wslog.Codec = Codec{}
+
+// Decode reads the next record written by wslog.NewBinaryHandler with Codec from r,
+// it returns io.EOF at the end of the stream. It reads no more than the record.
+func Decode(r io.Reader) (wslog.RecordData, error) {
+	return wslog.NewBinaryDecoder(r, Codec{}).Decode()
+}
+
+func (Codec) AppendValue(b []byte, v wslog.Value) []byte {
+	switch v.Kind() {
+	case wslog.KindString:
+		return appendString(b, v.String())
+	case wslog.KindInt64:
+		return appendInt(b, v.Int64())
+	case wslog.KindUint64:
+		return appendUint(b, v.Uint64())
+	case wslog.KindFloat64:
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v.Float64()))
+	case wslog.KindBool:
+		if v.Bool() {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case wslog.KindDuration:
+		b = append(b, 0xd7, ExtDuration)
+		return binary.BigEndian.AppendUint64(b, uint64(v.Duration()))
+	case wslog.KindTime:
+		t := v.Time()
+		// timestamp 96
+		b = append(b, 0xc7, 12, 0xff)
+		b = binary.BigEndian.AppendUint32(b, uint32(t.Nanosecond()))
+		return binary.BigEndian.AppendUint64(b, uint64(t.Unix()))
+	case wslog.KindGroup:
+		attrs := v.Group()
+		b = appendMapHeader(b, len(attrs))
+		for _, a := range attrs {
+			b = appendString(b, a.Key)
+			b = Codec{}.AppendValue(b, a.Value.Resolve())
+		}
+		return b
+	default:
+		return appendString(b, v.String())
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xde)
+		return binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdf)
+		return binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= -32 && i <= math.MaxInt8:
+		// positive or negative fixint
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		b = append(b, 0xd1)
+		return binary.BigEndian.AppendUint16(b, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		b = append(b, 0xd2)
+		return binary.BigEndian.AppendUint32(b, uint32(i))
+	default:
+		b = append(b, 0xd3)
+		return binary.BigEndian.AppendUint64(b, uint64(i))
+	}
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		b = append(b, 0xcd)
+		return binary.BigEndian.AppendUint16(b, uint16(u))
+	case u <= math.MaxUint32:
+		b = append(b, 0xce)
+		return binary.BigEndian.AppendUint32(b, uint32(u))
+	default:
+		b = append(b, 0xcf)
+		return binary.BigEndian.AppendUint64(b, u)
+	}
+}
+
+// DecodeValue decodes the formats written by AppendValue,
+// and nil, the binary and the other integer and float formats.
+// Arrays and the unknown extensions aren't supported.
+func (Codec) DecodeValue(b []byte) (wslog.Value, int, error) {
+	if len(b) == 0 {
+		return wslog.Value{}, 0, errShort
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return slog.Int64Value(int64(c)), 1, nil
+	case c >= 0xe0:
+		return slog.Int64Value(int64(int8(c))), 1, nil
+	case c&0xe0 == 0xa0:
+		return decodeString(b, 1, int(c&0x1f))
+	case c&0xf0 == 0x80:
+		return decodeMap(b, 1, int(c&0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return slog.AnyValue(nil), 1, nil
+	case 0xc2:
+		return slog.BoolValue(false), 1, nil
+	case 0xc3:
+		return slog.BoolValue(true), 1, nil
+	case 0xca:
+		u, err := uintN(b, 1, 4)
+		return slog.Float64Value(float64(math.Float32frombits(uint32(u)))), 5, err
+	case 0xcb:
+		u, err := uintN(b, 1, 8)
+		return slog.Float64Value(math.Float64frombits(u)), 9, err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		size := 1 << (c - 0xcc)
+		u, err := uintN(b, 1, size)
+		return slog.Uint64Value(u), 1 + size, err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := uintN(b, 1, size)
+		// sign-extend the integer of size bytes
+		shift := 64 - 8*size
+		return slog.Int64Value(int64(u<<shift) >> shift), 1 + size, err
+	case 0xd9, 0xda, 0xdb, 0xc4, 0xc5, 0xc6:
+		size := 1
+		switch c {
+		case 0xda, 0xc5:
+			size = 2
+		case 0xdb, 0xc6:
+			size = 4
+		}
+		n, err := uintN(b, 1, size)
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		return decodeString(b, 1+size, int(n))
+	case 0xde, 0xdf:
+		size := 2
+		if c == 0xdf {
+			size = 4
+		}
+		n, err := uintN(b, 1, size)
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		return decodeMap(b, 1+size, int(n))
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decodeExt(b, 1, 1<<(c-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		size := 1 << (c - 0xc7)
+		n, err := uintN(b, 1, size)
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		return decodeExt(b, 1+size, int(n))
+	}
+	return wslog.Value{}, 0, fmt.Errorf("msgpack: unsupported format 0x%02x", c)
+}
+
+// uintN reads the big-endian unsigned integer of size bytes at b[i:].
+func uintN(b []byte, i, size int) (uint64, error) {
+	if len(b) < i+size {
+		return 0, errShort
+	}
+	var u uint64
+	for _, c := range b[i : i+size] {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func decodeString(b []byte, i, n int) (wslog.Value, int, error) {
+	if n < 0 || len(b)-i < n {
+		return wslog.Value{}, 0, errShort
+	}
+	return slog.StringValue(string(b[i : i+n])), i + n, nil
+}
+
+func decodeMap(b []byte, i, n int) (wslog.Value, int, error) {
+	// each entry takes at least 2 bytes
+	if n < 0 || (len(b)-i)/2 < n {
+		return wslog.Value{}, 0, errShort
+	}
+	attrs := make([]wslog.Attr, 0, n)
+	for j := 0; j < n; j++ {
+		key, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		if key.Kind() != wslog.KindString {
+			return wslog.Value{}, 0, errors.New("msgpack: map key is not a string")
+		}
+		i += size
+		value, size, err := Codec{}.DecodeValue(b[i:])
+		if err != nil {
+			return wslog.Value{}, 0, err
+		}
+		i += size
+		attrs = append(attrs, slog.Attr{Key: key.String(), Value: value})
+	}
+	return slog.GroupValue(attrs...), i, nil
+}
+
+func decodeExt(b []byte, i, n int) (wslog.Value, int, error) {
+	if n < 0 || len(b)-i < 1+n {
+		return wslog.Value{}, 0, errShort
+	}
+	typ, data := int8(b[i]), b[i+1:i+1+n]
+	end := i + 1 + n
+	switch {
+	case typ == ExtDuration && n == 8:
+		return slog.DurationValue(time.Duration(binary.BigEndian.Uint64(data))), end, nil
+	case typ == ExtTimestamp && n == 4:
+		return slog.TimeValue(time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC()), end, nil
+	case typ == ExtTimestamp && n == 8:
+		u := binary.BigEndian.Uint64(data)
+		return slog.TimeValue(time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC()), end, nil
+	case typ == ExtTimestamp && n == 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return slog.TimeValue(time.Unix(sec, int64(nsec)).UTC()), end, nil
+	}
+	return wslog.Value{}, 0, fmt.Errorf("msgpack: unsupported extension %d of %d bytes", typ, n)
+}
@@ -0,0 +1,301 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package natslog publishes the records of wslog to NATS subjects.
+package natslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+const (
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// Publisher publishes messages fire-and-forget, which is implemented by *nats.Conn.
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// PublishFunc publishes a message and waits for its ack, e.g. with JetStream:
+//
+//	func(ctx context.Context, subject string, data []byte) error {
+//		_, err := js.Publish(ctx, subject, data)
+//		return err
+//	}
+type PublishFunc func(ctx context.Context, subject string, data []byte) error
+
+// Encoder creates the Handler rendering each record written to w,
+// which must write each record with a single call to w.Write.
+type Encoder func(w io.Writer, opts *wslog.HandlerOptions) wslog.Handler
+
+// JSONEncoder renders the records with the slog JSON handler, which is the default.
+func JSONEncoder(w io.Writer, opts *wslog.HandlerOptions) wslog.Handler {
+	return slog.NewJSONHandler(w, opts)
+}
+
+// Option configures the handler created by NewHandler.
+type Option func(p *publisher)
+
+// WithEncoder sets the encoder of the records, JSONEncoder by default.
+func WithEncoder(encoder Encoder) Option {
+	return func(p *publisher) {
+		p.encoder = encoder
+	}
+}
+
+// WithFallback sets the value of the subject variables
+// missing from a record, `_` by default.
+func WithFallback(fallback string) Option {
+	return func(p *publisher) {
+		p.fallback = fallback
+	}
+}
+
+// WithJetStream publishes the records with publish instead of the Publisher,
+// awaiting their acks, and retrying the failures up to retries times
+// with an exponential backoff.
+func WithJetStream(publish PublishFunc, retries int) Option {
+	return func(p *publisher) {
+		p.jetStream = publish
+		p.retries = retries
+	}
+}
+
+// NewHandler creates a Handler that publishes each record to the subject
+// of the template, e.g. `logs.myservice.{level}`.
+// A variable `{name}` is replaced by the lower-cased level for `level`,
+// or by the value of the top-level attr of the key, e.g. `{logger}` for
+// the name of wslog.Logger.Named. The variables missing from a record
+// are replaced by the fallback of WithFallback, and the characters not
+// allowed in a subject token, i.e. `.`, `*`, `>` and whitespaces, by `_`.
+// The records are published fire-and-forget with conn, unless WithJetStream is used.
+func NewHandler(conn Publisher, subject string, opts *wslog.HandlerOptions, options ...Option) wslog.Handler {
+	p := &publisher{
+		conn:     conn,
+		subject:  parseTemplate(subject),
+		encoder:  JSONEncoder,
+		fallback: "_",
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return &handler{
+		publisher: p,
+		encoded:   p.encoder(p, opts),
+		vars:      make(map[string]string),
+	}
+}
+
+type handler struct {
+	publisher *publisher // shared among all clones of this handler
+	encoded   wslog.Handler
+
+	grouped bool              // whether WithGroup was called, attrs are no longer top-level
+	vars    map[string]string // subject variables of WithAttrs
+}
+
+func (h *handler) NeedsSource() bool {
+	if sa, ok := h.encoded.(wslog.SourceAware); ok {
+		return sa.NeedsSource()
+	}
+	return true
+}
+
+func (h *handler) Enabled(ctx context.Context, level wslog.Level) bool {
+	return h.encoded.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record wslog.Record) error {
+	vars := make(map[string]string, len(h.vars)+1)
+	for name, value := range h.vars {
+		vars[name] = value
+	}
+	if !h.grouped {
+		record.Attrs(func(attr wslog.Attr) bool {
+			h.publisher.addVar(vars, attr)
+			return true
+		})
+	}
+	vars[wslog.LevelKey] = strings.ToLower(record.Level.String())
+	subject := h.publisher.subject.expand(vars, h.publisher.fallback)
+
+	data, err := h.publisher.encode(ctx, h.encoded, record)
+	if err != nil {
+		return err
+	}
+	return h.publisher.publish(ctx, subject, data)
+}
+
+func (h *handler) WithAttrs(attrs []wslog.Attr) wslog.Handler {
+	cp := &handler{
+		publisher: h.publisher,
+		encoded:   h.encoded.WithAttrs(attrs),
+		grouped:   h.grouped,
+		vars:      h.vars,
+	}
+	if !h.grouped {
+		cp.vars = make(map[string]string, len(h.vars))
+		for name, value := range h.vars {
+			cp.vars[name] = value
+		}
+		for _, attr := range attrs {
+			h.publisher.addVar(cp.vars, attr)
+		}
+	}
+	return cp
+}
+
+func (h *handler) WithGroup(name string) wslog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{
+		publisher: h.publisher,
+		encoded:   h.encoded.WithGroup(name),
+		grouped:   true,
+		vars:      h.vars,
+	}
+}
+
+type publisher struct {
+	conn      Publisher
+	subject   template
+	encoder   Encoder
+	fallback  string
+	jetStream PublishFunc
+	retries   int
+
+	// the encoded handlers write to the publisher, one record at a time
+	mu   sync.Mutex
+	data []byte
+}
+
+// Write implements io.Writer for the encoded handlers.
+func (p *publisher) Write(b []byte) (int, error) {
+	p.data = append(p.data[:0], b...)
+	return len(b), nil
+}
+
+// encode renders record with h, which writes to p.
+func (p *publisher) encode(ctx context.Context, h wslog.Handler, record wslog.Record) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.data = p.data[:0]
+	if err := h.Handle(ctx, record); err != nil {
+		return nil, err
+	}
+	return slices.Clone(p.data), nil
+}
+
+// addVar adds the value of a to vars if its key is a variable of the subject.
+func (p *publisher) addVar(vars map[string]string, a wslog.Attr) {
+	if _, ok := p.subject.vars[a.Key]; ok && a.Key != wslog.LevelKey {
+		vars[a.Key] = a.Value.Resolve().String()
+	}
+}
+
+func (p *publisher) publish(ctx context.Context, subject string, data []byte) error {
+	if p.jetStream == nil {
+		if err := p.conn.Publish(subject, data); err != nil {
+			return fmt.Errorf("nats publish failed: %w", err)
+		}
+		return nil
+	}
+
+	backoff := minBackoff
+	for attempt := 0; ; attempt++ {
+		err := p.jetStream(ctx, subject, data)
+		if err == nil {
+			return nil
+		}
+		if attempt >= p.retries {
+			return fmt.Errorf("jetstream publish failed: %w", err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("jetstream publish failed: %w", err)
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// template is a parsed subject template,
+// the odd parts are the names of the variables.
+type template struct {
+	parts []string
+	vars  map[string]struct{}
+}
+
+func parseTemplate(s string) template {
+	t := template{vars: make(map[string]struct{})}
+	for {
+		start := strings.IndexByte(s, '{')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end == -1 {
+			break
+		}
+		name := s[start+1 : start+end]
+		t.parts = append(t.parts, s[:start], name)
+		t.vars[name] = struct{}{}
+		s = s[start+end+1:]
+	}
+	t.parts = append(t.parts, s)
+	return t
+}
+
+func (t template) expand(vars map[string]string, fallback string) string {
+	if len(t.parts) == 1 {
+		return t.parts[0]
+	}
+	var b strings.Builder
+	for i, part := range t.parts {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		value, ok := vars[part]
+		if !ok || value == "" {
+			value = fallback
+		}
+		b.WriteString(subjectToken(value))
+	}
+	return b.String()
+}
+
+// subjectToken replaces the characters not allowed in a subject token.
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,103 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package natslog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zc2638/wslog"
+)
+
+type message struct {
+	subject string
+	data    map[string]any
+}
+
+type fakeConn struct {
+	mu       sync.Mutex
+	failures int
+	messages []message
+}
+
+func (c *fakeConn) Publish(subject string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("no ack")
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	c.messages = append(c.messages, message{subject: subject, data: m})
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	conn := new(fakeConn)
+	h := NewHandler(conn, "logs.{service}.{level}.{logger}", nil)
+	l := wslog.NewLogger(h)
+	l.Info("no service")
+	l.With("service", "api.v1").Named("http").Warn("hello", "a", 1)
+	l.WithGroup("g").Error("grouped", "service", "ignored")
+
+	want := []string{"logs._.info._", "logs.api_v1.warn.http", "logs._.error._"}
+	if len(conn.messages) != len(want) {
+		t.Fatalf("messages = %v, want %d", conn.messages, len(want))
+	}
+	for i, m := range conn.messages {
+		if m.subject != want[i] {
+			t.Errorf("subject %d = %q, want %q", i, m.subject, want[i])
+		}
+	}
+	if m := conn.messages[1].data; m["msg"] != "hello" || m["service"] != "api.v1" || m["a"] != float64(1) {
+		t.Errorf("data = %v", m)
+	}
+	if g, _ := conn.messages[2].data["g"].(map[string]any); g["service"] != "ignored" {
+		t.Errorf("data = %v", conn.messages[2].data)
+	}
+}
+
+func TestHandler_JetStream(t *testing.T) {
+	conn := &fakeConn{failures: 2}
+	publish := func(_ context.Context, subject string, data []byte) error {
+		return conn.Publish(subject, data)
+	}
+	h := NewHandler(nil, "logs", nil, WithJetStream(publish, 2))
+	if err := h.Handle(context.Background(), newRecord("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.messages) != 1 {
+		t.Errorf("messages = %v, want 1 after 2 retries", conn.messages)
+	}
+
+	conn.failures = 1
+	h = NewHandler(nil, "logs", nil, WithJetStream(publish, 0))
+	if err := h.Handle(context.Background(), newRecord("hello")); err == nil {
+		t.Error("error = nil, want publish failure")
+	}
+}
+
+func newRecord(msg string) wslog.Record {
+	return slog.NewRecord(time.Now(), wslog.LevelInfo, msg, 0)
+}
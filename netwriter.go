@@ -0,0 +1,166 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetWriterOption configures the writer created by NewNetWriter.
+type NetWriterOption func(w *netWriter)
+
+// WithNetWriteTimeout sets the timeout of the dials and of each write,
+// 5s by default, so that a hung collector can't block the logging.
+func WithNetWriteTimeout(timeout time.Duration) NetWriterOption {
+	return func(w *netWriter) {
+		if timeout > 0 {
+			w.timeout = timeout
+		}
+	}
+}
+
+// WithNetBufferSize sets the size in bytes of the writes buffered while
+// the remote is unreachable, 1MiB by default. The writes exceeding it
+// are dropped with an error.
+func WithNetBufferSize(size int) NetWriterOption {
+	return func(w *netWriter) {
+		w.maxBuffer = size
+	}
+}
+
+// WithNetLengthPrefix prefixes each write with its length as a 4-byte
+// big-endian header, to frame the records of stream connections.
+func WithNetLengthPrefix(enabled bool) NetWriterOption {
+	return func(w *netWriter) {
+		w.lengthPrefix = enabled
+	}
+}
+
+// NewNetWriter creates a writer that sends each write to addr, the network
+// is one of tcp, udp, unix or unixgram. The remote is dialed on the first
+// write, and a failed write reconnects with an exponential backoff.
+// The writes are buffered while the remote is unreachable, and sent in
+// order once it is reconnected. Close the writer to close the connection.
+func NewNetWriter(network, addr string, options ...NetWriterOption) io.WriteCloser {
+	w := &netWriter{
+		timeout:   5 * time.Second,
+		maxBuffer: 1 << 20,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	w.conn = &netConn{name: network + "://" + addr, dial: func() (net.Conn, error) {
+		return net.DialTimeout(network, addr, w.timeout)
+	}}
+	return w
+}
+
+type netWriter struct {
+	conn         *netConn
+	timeout      time.Duration
+	maxBuffer    int
+	lengthPrefix bool
+
+	mu          sync.Mutex
+	pending     [][]byte // the writes buffered while the remote is unreachable
+	pendingSize int
+}
+
+// Write implements io.Writer, and sends p, or buffers it if the remote is unreachable.
+func (w *netWriter) Write(p []byte) (int, error) {
+	msg := make([]byte, 0, len(p)+4)
+	if w.lengthPrefix {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(p)))
+	}
+	msg = append(msg, p...)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.send(msg)
+	if err == nil {
+		return len(p), nil
+	}
+	if w.conn.isClosed() {
+		return 0, err
+	}
+	if w.pendingSize+len(msg) > w.maxBuffer {
+		return 0, fmt.Errorf("net writer buffer is full, dropped a write: %w", err)
+	}
+	w.pending = append(w.pending, msg)
+	w.pendingSize += len(msg)
+	return len(p), nil
+}
+
+// send writes the pending writes and msg, if not nil, to the connection.
+func (w *netWriter) send(msg []byte) error {
+	return w.conn.write(func(conn net.Conn) error {
+		for len(w.pending) > 0 {
+			if err := w.writeConn(conn, w.pending[0]); err != nil {
+				return err
+			}
+			w.pendingSize -= len(w.pending[0])
+			w.pending[0] = nil
+			w.pending = w.pending[1:]
+		}
+		if msg == nil {
+			return nil
+		}
+		return w.writeConn(conn, msg)
+	})
+}
+
+func (w *netWriter) writeConn(conn net.Conn, msg []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(msg)
+	return err
+}
+
+// Flush implements Flusher, and sends the buffered writes.
+func (w *netWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	return w.send(nil)
+}
+
+// Close implements io.Closer, it sends the buffered writes and closes the connection.
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	if len(w.pending) > 0 {
+		if err := w.send(nil); err != nil {
+			errs = append(errs, fmt.Errorf("dropped %d buffered writes: %w", len(w.pending), err))
+		}
+		w.pending, w.pendingSize = nil, 0
+	}
+	if err := w.conn.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	// the remote is unreachable until the listener is restarted
+	_ = ln.Close()
+
+	w := NewNetWriter("tcp", addr, WithNetLengthPrefix(true), WithNetBufferSize(16), WithNetWriteTimeout(time.Second))
+	if _, err := w.Write([]byte("first")); err != nil {
+		t.Fatalf("Write() during the outage = %v, want buffered", err)
+	}
+	if _, err := w.Write([]byte("too large for the buffer")); err == nil {
+		t.Fatal("Write() exceeding the buffer = nil, want an error")
+	}
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("can't listen again on %s: %v", addr, err)
+	}
+	defer ln.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				received <- msgs
+				return
+			}
+			msg := make([]byte, size)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				received <- msgs
+				return
+			}
+			msgs = append(msgs, string(msg))
+		}
+	}()
+
+	// wait for the reconnect backoff to elapse
+	time.Sleep(netMinBackoff + 50*time.Millisecond)
+	if _, err := w.Write([]byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(<-received, ","); got != "first,second" {
+		t.Errorf("received %q, want first,second", got)
+	}
+	if _, err := w.Write([]byte("closed")); err == nil {
+		t.Error("Write() after Close() = nil, want an error")
+	}
+}
+
+func TestNew_Output(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		received <- string(b)
+	}()
+
+	l := New(Config{Format: "json", Output: "tcp://" + ln.Addr().String()})
+	l.Info("hello")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; !strings.Contains(got, `"msg":"hello"`) {
+		t.Errorf("received %q, want the record", got)
+	}
+
+}
@@ -0,0 +1,122 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"fmt"
+	"io"
+)
+
+// Option is an option of New and NewE, which also accept the
+// untyped options listed by New for backward compatibility.
+type Option interface {
+	apply(o *newOptions)
+}
+
+type optionFunc func(o *newOptions)
+
+func (f optionFunc) apply(o *newOptions) { f(o) }
+
+// newOptions are the options of New, overriding the defaults of the Config.
+type newOptions struct {
+	handlerOpts *HandlerOptions
+	handler     Handler
+	writer      io.Writer
+	rotators    []Rotator
+	newRotator  func(cfg Config) Rotator
+}
+
+// WithWriter replaces the output of Output and Filename with w.
+// A Rotator is owned by the Logger, see WithRotator.
+func WithWriter(w io.Writer) Option {
+	if r, ok := w.(Rotator); ok {
+		return WithRotator(r)
+	}
+	return optionFunc(func(o *newOptions) {
+		o.writer = w
+		o.rotators = o.rotators[:0]
+	})
+}
+
+// WithRotator replaces the output of Output and Filename with r,
+// which is owned by the Logger, see Logger.Rotators.
+func WithRotator(r Rotator) Option {
+	return optionFunc(func(o *newOptions) {
+		o.writer = r
+		o.rotators = append(o.rotators[:0], r)
+	})
+}
+
+// WithRotatorFunc creates the Rotators of Filename and LevelFiles instead of NewRotator.
+func WithRotatorFunc(fn RotatorFunc) Option {
+	return optionFunc(func(o *newOptions) {
+		o.newRotator = fn
+	})
+}
+
+// WithHandler replaces the handler of the Format with h.
+func WithHandler(h Handler) Option {
+	return optionFunc(func(o *newOptions) {
+		o.handler = h
+	})
+}
+
+// WithHandlerOptions replaces the HandlerOptions of the Config with opts, if not nil.
+func WithHandlerOptions(opts *HandlerOptions) Option {
+	return optionFunc(func(o *newOptions) {
+		if opts != nil {
+			o.handlerOpts = opts
+		}
+	})
+}
+
+// WithReplaceAttr sets the ReplaceAttr func of the HandlerOptions.
+func WithReplaceAttr(fn func(groups []string, a Attr) Attr) Option {
+	return optionFunc(func(o *newOptions) {
+		o.handlerOpts.ReplaceAttr = fn
+	})
+}
+
+// WithLeveler replaces the Level of the Config with l, e.g. a shared *LevelVar.
+func WithLeveler(l Leveler) Option {
+	return optionFunc(func(o *newOptions) {
+		o.handlerOpts.Level = l
+	})
+}
+
+// toOption converts an option of New to an Option.
+func toOption(opt any) (Option, error) {
+	switch v := opt.(type) {
+	case Option:
+		return v, nil
+	case Rotator:
+		return WithRotator(v), nil
+	case RotatorFunc:
+		return WithRotatorFunc(v), nil
+	case func(cfg Config) Rotator:
+		return WithRotatorFunc(v), nil
+	case io.Writer:
+		return WithWriter(v), nil
+	case *HandlerOptions:
+		return WithHandlerOptions(v), nil
+	case func(groups []string, a Attr) Attr:
+		return WithReplaceAttr(v), nil
+	case Leveler:
+		return WithLeveler(v), nil
+	case Handler:
+		return WithHandler(v), nil
+	}
+	return nil, fmt.Errorf("unknown option %T of New", opt)
+}
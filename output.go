@@ -0,0 +1,97 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+)
+
+// configOutput is the parsed Output of a Config.
+type configOutput struct {
+	std      string // stdout, stderr or discard
+	filename string
+	network  string
+	addr     string
+}
+
+// parseOutput parses the Output of a Config, see Config.Output.
+func parseOutput(output string) (configOutput, error) {
+	switch output {
+	case "stdout", "stderr", "discard":
+		return configOutput{std: output}, nil
+	}
+	u, err := url.Parse(output)
+	// a single letter is the volume of a Windows path, e.g. C:\logs
+	if err != nil || len(u.Scheme) < 2 {
+		return configOutput{filename: output}, nil
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return configOutput{}, fmt.Errorf("invalid output %q: missing path", output)
+		}
+		return configOutput{filename: u.Path}, nil
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+		if u.Host == "" {
+			return configOutput{}, fmt.Errorf("invalid output %q: missing host", output)
+		}
+		return configOutput{network: u.Scheme, addr: u.Host}, nil
+	case "unix", "unixgram":
+		if u.Path == "" {
+			return configOutput{}, fmt.Errorf("invalid output %q: missing path", output)
+		}
+		return configOutput{network: u.Scheme, addr: u.Path}, nil
+	}
+	return configOutput{}, fmt.Errorf("invalid output %q: unsupported scheme %q", output, u.Scheme)
+}
+
+// outputWriter creates the writer of the Output of the Config, and whether
+// it is owned by the Logger, unlike the standard streams. An invalid Output
+// is reported to the func set by SetErrorHandler, and os.Stderr is used instead.
+func (c *Config) outputWriter(newRotator func(cfg Config) Rotator) (io.Writer, bool) {
+	out, err := parseOutput(c.Output)
+	if err != nil {
+		reportError(err)
+		out = configOutput{std: "stderr"}
+	}
+	switch {
+	case out.filename != "":
+		fileCfg := *c
+		fileCfg.Filename = out.filename
+		return newRotator(fileCfg), true
+	case out.network != "":
+		return NewNetWriter(out.network, out.addr), true
+	case out.std == "stdout":
+		return os.Stdout, false
+	case out.std == "stderr":
+		return os.Stderr, false
+	}
+	return io.Discard, false
+}
+
+// outputHandler creates the handler writing to the Output of the Config,
+// and returns the writer it owns, nil if none.
+func (c *Config) outputHandler(handlerOpts *HandlerOptions, newRotator func(cfg Config) Rotator) (Handler, io.Closer) {
+	w, owned := c.outputWriter(newRotator)
+	if owned {
+		return c.newHandler(w, handlerOpts), w.(io.Closer)
+	}
+	stdCfg := *c
+	stdCfg.DisableColor = c.DisableColor || !isTerminal(w)
+	return stdCfg.newHandler(w, handlerOpts), nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    configOutput
+		wantErr bool
+	}{
+		{output: "stdout", want: configOutput{std: "stdout"}},
+		{output: "discard", want: configOutput{std: "discard"}},
+		{output: "logs/app.log", want: configOutput{filename: "logs/app.log"}},
+		{output: "/var/log/app.log", want: configOutput{filename: "/var/log/app.log"}},
+		{output: `C:\logs\app.log`, want: configOutput{filename: `C:\logs\app.log`}},
+		{output: "file:///var/log/app.log", want: configOutput{filename: "/var/log/app.log"}},
+		{output: "tcp://collector:5170", want: configOutput{network: "tcp", addr: "collector:5170"}},
+		{output: "unix:///run/collector.sock", want: configOutput{network: "unix", addr: "/run/collector.sock"}},
+		{output: "file://", wantErr: true},
+		{output: "tcp://", wantErr: true},
+		{output: "unix://", wantErr: true},
+		{output: "http://host:80", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseOutput(tt.output)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseOutput(%q) = %+v, %v, want %+v", tt.output, got, err, tt.want)
+		}
+	}
+}
+
+func TestNew_Output_Std(t *testing.T) {
+	dir := t.TempDir()
+	stderr := swapStdFile(t, &os.Stderr, filepath.Join(dir, "stderr"))
+	l := New(Config{Output: "stderr"})
+	l.Info("hello")
+	New(Config{Output: "discard"}).Info("discarded")
+
+	b, err := os.ReadFile(stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the colors are disabled, a file isn't a terminal
+	if got := string(b); !strings.Contains(got, "hello") || strings.Contains(got, "\x1b[") || strings.Contains(got, "discarded") {
+		t.Errorf("stderr = %q, want the record without colors", got)
+	}
+}
+
+func TestNew_Output_File(t *testing.T) {
+	dir := t.TempDir()
+	for _, output := range []string{filepath.Join(dir, "a.log"), "file://" + filepath.ToSlash(filepath.Join(dir, "b.log"))} {
+		l := New(Config{Format: "json", Output: output, Filename: filepath.Join(dir, "ignored.log")})
+		l.Info("hello")
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.log", "b.log"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !strings.Contains(string(b), `"msg":"hello"`) {
+			t.Errorf("%s = %q, %v, want the record", name, b, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ignored.log")); err == nil {
+		t.Error("Filename is written, want Output to take precedence")
+	}
+}
+
+func TestNew_AlsoStdout(t *testing.T) {
+	dir := t.TempDir()
+	stdout := swapStdFile(t, &os.Stdout, filepath.Join(dir, "stdout"))
+	cfg := Config{Format: "json", Filename: filepath.Join(dir, "app.log"), AlsoStdout: true, ConsoleFormat: "logfmt"}
+	l := New(cfg)
+	sub := l.With("a", 1).WithGroup("g")
+	sub.Info("hello", "b", 2)
+	sub.Debug("dropped")
+	cfg.Level = SLevelDebug
+	if err := cfg.Apply(l); err != nil {
+		t.Fatal(err)
+	}
+	sub.Debug("traced")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		cfg.Filename: {`"msg":"hello","a":1,"g":{"b":2}`, `"msg":"traced"`},
+		stdout:       {`msg=hello a=1 g.b=2`, `msg=traced`},
+	}
+	for file, want := range tests {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("%s = %q, want %d records", filepath.Base(file), b, len(want))
+		}
+		for i, s := range want {
+			if !strings.Contains(lines[i], s) {
+				t.Errorf("%s record %d = %q, want %s", filepath.Base(file), i, lines[i], s)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// The field numbers of the messages in record.proto.
+const (
+	protoRecordTime    = 1
+	protoRecordLevel   = 2
+	protoRecordMessage = 3
+	protoRecordAttrs   = 4
+	protoRecordSource  = 5
+
+	protoSourceFunction = 1
+	protoSourceFile     = 2
+	protoSourceLine     = 3
+
+	protoAttrKey   = 1
+	protoAttrValue = 2
+
+	protoValueString   = 1
+	protoValueInt      = 2
+	protoValueUint     = 3
+	protoValueFloat    = 4
+	protoValueBool     = 5
+	protoValueDuration = 6
+	protoValueTime     = 7
+	protoValueGroup    = 8
+
+	protoGroupAttrs = 1
+)
+
+// The protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// NewProtoHandler creates a Handler that writes each record to w as a
+// protobuf Record message of record.proto, prefixed with its length
+// as a 4-byte big-endian unsigned integer.
+// Groups are written as nested Group values, the same as the slog JSON handler.
+func NewProtoHandler(w io.Writer, opts *HandlerOptions) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	return &protoHandler{
+		w:     w,
+		opts:  *opts,
+		mu:    new(sync.Mutex),
+		attrs: make([][]byte, 1),
+	}
+}
+
+type protoHandler struct {
+	w    io.Writer
+	opts HandlerOptions
+	mu   *sync.Mutex
+
+	groups []string
+	// encoded attrs of WithAttrs per open group,
+	// the first ones belong to the Record itself
+	attrs [][]byte
+}
+
+func (h *protoHandler) clone() *protoHandler {
+	return &protoHandler{
+		w:      h.w,
+		opts:   h.opts,
+		mu:     h.mu, // mutex shared among all clones of this handler
+		groups: slices.Clip(h.groups),
+		attrs:  slices.Clip(slices.Clone(h.attrs)),
+	}
+}
+
+func (h *protoHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	cp := h.clone()
+	mutate(&cp.opts)
+	return cp, true
+}
+
+func (h *protoHandler) NeedsSource() bool {
+	return h.opts.AddSource
+}
+
+func (h *protoHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *protoHandler) Handle(_ context.Context, record Record) error {
+	// the attrs of the innermost group, then wrap them in the open groups
+	depth := len(h.groups)
+	attrs := slices.Clone(h.attrs[depth])
+	record.Attrs(func(attr Attr) bool {
+		attrs = h.appendAttr(attrs, protoAttrsField(depth), h.groups, attr)
+		return true
+	})
+	for i := depth; i > 0; i-- {
+		outer := slices.Clone(h.attrs[i-1])
+		if len(attrs) > 0 {
+			outer = appendProtoBytes(outer, protoAttrsField(i-1), protoGroupAttr(h.groups[i-1], attrs))
+		}
+		attrs = outer
+	}
+
+	b := make([]byte, 4, 64+len(attrs))
+	if !record.Time.IsZero() {
+		b = appendProtoVarint(b, protoRecordTime, uint64(record.Time.UnixNano()))
+	}
+	if record.Level != 0 {
+		b = appendProtoVarint(b, protoRecordLevel, protoZigZag(int64(record.Level)))
+	}
+	if record.Message != "" {
+		b = appendProtoString(b, protoRecordMessage, record.Message)
+	}
+	b = append(b, attrs...)
+	// source, records constructed without a caller have no PC
+	if h.opts.AddSource && record.PC != 0 {
+		fs := runtime.CallersFrames([]uintptr{record.PC})
+		f, _ := fs.Next()
+		var src []byte
+		src = appendProtoString(src, protoSourceFunction, f.Function)
+		src = appendProtoString(src, protoSourceFile, f.File)
+		src = appendProtoVarint(src, protoSourceLine, uint64(f.Line))
+		b = appendProtoBytes(b, protoRecordSource, src)
+	}
+
+	size := len(b) - 4
+	if uint64(size) > math.MaxUint32 {
+		return fmt.Errorf("record of %d bytes is too large", size)
+	}
+	binary.BigEndian.PutUint32(b, uint32(size))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b)
+	return err
+}
+
+func (h *protoHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	depth := len(cp.groups)
+	encoded := slices.Clip(cp.attrs[depth])
+	for _, attr := range attrs {
+		encoded = cp.appendAttr(encoded, protoAttrsField(depth), cp.groups, attr)
+	}
+	cp.attrs[depth] = encoded
+	return cp
+}
+
+func (h *protoHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	cp.attrs = append(cp.attrs, nil)
+	return cp
+}
+
+// appendAttr appends a as the repeated Attr field of the given number to b.
+func (h *protoHandler) appendAttr(b []byte, field int, groups []string, a Attr) []byte {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return b
+	}
+
+	if a.Value.Kind() != KindGroup {
+		var attr []byte
+		attr = appendProtoString(attr, protoAttrKey, a.Key)
+		attr = appendProtoBytes(attr, protoAttrValue, appendProtoValue(nil, a.Value))
+		return appendProtoBytes(b, field, attr)
+	}
+
+	// Inline a group with an empty key.
+	if a.Key == "" {
+		for _, ga := range a.Value.Group() {
+			b = h.appendAttr(b, field, groups, ga)
+		}
+		return b
+	}
+	g2 := append(slices.Clip(groups), a.Key)
+	var attrs []byte
+	for _, ga := range a.Value.Group() {
+		attrs = h.appendAttr(attrs, protoGroupAttrs, g2, ga)
+	}
+	// Output only non-empty groups.
+	if len(attrs) == 0 {
+		return b
+	}
+	return appendProtoBytes(b, field, protoGroupAttr(a.Key, attrs))
+}
+
+// protoAttrsField returns the field number of the attrs
+// of the group at depth, 0 being the Record.
+func protoAttrsField(depth int) int {
+	if depth == 0 {
+		return protoRecordAttrs
+	}
+	return protoGroupAttrs
+}
+
+// protoGroupAttr returns the Attr message of a group with the encoded attrs.
+func protoGroupAttr(key string, attrs []byte) []byte {
+	var attr []byte
+	attr = appendProtoString(attr, protoAttrKey, key)
+	value := appendProtoBytes(nil, protoValueGroup, attrs)
+	return appendProtoBytes(attr, protoAttrValue, value)
+}
+
+// appendProtoValue appends the fields of the Value message of v to b,
+// values of other kinds are written as strings.
+func appendProtoValue(b []byte, v Value) []byte {
+	switch v.Kind() {
+	case KindInt64:
+		return appendProtoVarint(b, protoValueInt, protoZigZag(v.Int64()))
+	case KindUint64:
+		return appendProtoVarint(b, protoValueUint, v.Uint64())
+	case KindFloat64:
+		b = binary.AppendUvarint(b, protoValueFloat<<3|protoFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v.Float64()))
+	case KindBool:
+		var u uint64
+		if v.Bool() {
+			u = 1
+		}
+		return appendProtoVarint(b, protoValueBool, u)
+	case KindDuration:
+		return appendProtoVarint(b, protoValueDuration, uint64(v.Duration()))
+	case KindTime:
+		return appendProtoVarint(b, protoValueTime, uint64(v.Time().UnixNano()))
+	default:
+		return appendProtoString(b, protoValueString, v.String())
+	}
+}
+
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, p []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+func appendProtoString(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// protoZigZag encodes v for the sint32 and sint64 types.
+func protoZigZag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
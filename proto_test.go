@@ -0,0 +1,208 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func TestProtoHandler_slogtest(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewProtoHandler(&buf, nil)
+
+	results := func() []map[string]any {
+		var ms []map[string]any
+		b := buf.Bytes()
+		for len(b) > 0 {
+			size := binary.BigEndian.Uint32(b)
+			m, err := decodeProtoRecord(b[4 : 4+size])
+			if err != nil {
+				t.Fatal(err)
+			}
+			ms = append(ms, m)
+			b = b[4+size:]
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(h, results); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProtoHandler_Values(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2023, 8, 18, 1, 2, 3, 4, time.UTC)
+	NewLogger(NewProtoHandler(&buf, &HandlerOptions{Level: LevelDebug})).Debug("msg",
+		"s", "", "i", -1, "u", uint64(math.MaxUint64), "f", 1.5, "b", false,
+		"d", time.Second, "t", now, "a", []int{1})
+
+	m, err := decodeProtoRecord(buf.Bytes()[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		LevelKey:   int64(LevelDebug),
+		MessageKey: "msg",
+		"s":        "",
+		"i":        int64(-1),
+		"u":        uint64(math.MaxUint64),
+		"f":        1.5,
+		"b":        false,
+		"d":        time.Second,
+		"t":        now,
+		"a":        "[1]",
+	}
+	for key, value := range want {
+		if got := m[key]; got != value {
+			t.Errorf("%s = %#v, want %#v", key, got, value)
+		}
+	}
+}
+
+type protoField struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProtoFields decodes the fields of a protobuf message.
+func decodeProtoFields(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("bad tag")
+		}
+		b = b[n:]
+		f := protoField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case protoVarint:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, fmt.Errorf("bad varint")
+			}
+			b = b[n:]
+		case protoFixed64:
+			f.varint = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, fmt.Errorf("bad length")
+			}
+			f.bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			return nil, fmt.Errorf("unknown wire type %d", tag&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func protoUnZigZag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// decodeProtoRecord decodes a Record message into a map
+// in the form expected by slogtest.
+func decodeProtoRecord(b []byte) (map[string]any, error) {
+	fields, err := decodeProtoFields(b)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]any{LevelKey: int64(0)}
+	for _, f := range fields {
+		switch f.num {
+		case protoRecordTime:
+			m[TimeKey] = time.Unix(0, int64(f.varint))
+		case protoRecordLevel:
+			m[LevelKey] = protoUnZigZag(f.varint)
+		case protoRecordMessage:
+			m[MessageKey] = string(f.bytes)
+		case protoRecordAttrs:
+			if err := decodeProtoAttr(f.bytes, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m, nil
+}
+
+func decodeProtoAttr(b []byte, m map[string]any) error {
+	fields, err := decodeProtoFields(b)
+	if err != nil {
+		return err
+	}
+	var (
+		key   string
+		value any
+	)
+	for _, f := range fields {
+		switch f.num {
+		case protoAttrKey:
+			key = string(f.bytes)
+		case protoAttrValue:
+			if value, err = decodeProtoValue(f.bytes); err != nil {
+				return err
+			}
+		}
+	}
+	m[key] = value
+	return nil
+}
+
+func decodeProtoValue(b []byte) (any, error) {
+	fields, err := decodeProtoFields(b)
+	if err != nil || len(fields) != 1 {
+		return nil, fmt.Errorf("bad value: %v", err)
+	}
+	f := fields[0]
+	switch f.num {
+	case protoValueString:
+		return string(f.bytes), nil
+	case protoValueInt:
+		return protoUnZigZag(f.varint), nil
+	case protoValueUint:
+		return f.varint, nil
+	case protoValueFloat:
+		return math.Float64frombits(f.varint), nil
+	case protoValueBool:
+		return f.varint == 1, nil
+	case protoValueDuration:
+		return time.Duration(f.varint), nil
+	case protoValueTime:
+		return time.Unix(0, int64(f.varint)).UTC(), nil
+	case protoValueGroup:
+		attrs, err := decodeProtoFields(f.bytes)
+		if err != nil {
+			return nil, err
+		}
+		group := make(map[string]any)
+		for _, attr := range attrs {
+			if err := decodeProtoAttr(attr.bytes, group); err != nil {
+				return nil, err
+			}
+		}
+		return group, nil
+	}
+	return nil, fmt.Errorf("unknown value field %d", f.num)
+}
@@ -0,0 +1,292 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitSummaryInterval is how long after the first suppressed record
+// of a level its `suppressed N messages` summary is emitted.
+var rateLimitSummaryInterval = time.Second
+
+// NewRateLimitHandler returns a Handler that lets at most limits[level]
+// records per second through to h. Each limit covers the records from its
+// level up to the next limited level, e.g. the limit of LevelWarn covers
+// LevelWarn+2 and LevelError unless LevelError has its own limit, which
+// can be rate.Inf. The records below the lowest limited level are passed
+// through unchanged.
+// Records whose bucket is empty are dropped, and a `suppressed N messages`
+// record of the level of the limit is emitted a second after the first
+// dropped one, or before the next record let through if it comes first.
+// Flush and Close emit the pending summaries right away, e.g. before
+// a shutdown.
+func NewRateLimitHandler(h Handler, limits map[Level]rate.Limit) Handler {
+	levels := make([]Level, 0, len(limits))
+	for level := range limits {
+		levels = append(levels, level)
+	}
+	slices.Sort(levels)
+
+	limiters := make([]*levelLimiter, len(levels))
+	for i, level := range levels {
+		limit := limits[level]
+		burst := 1
+		if limit != rate.Inf && int(limit) > burst {
+			burst = int(limit)
+		}
+		limiters[i] = &levelLimiter{level: level, limiter: rate.NewLimiter(limit, burst)}
+	}
+	return &rateLimitHandler{handler: h, levels: levels, limiters: limiters}
+}
+
+type levelLimiter struct {
+	level      Level
+	limiter    *rate.Limiter
+	suppressed atomic.Uint64
+	scheduled  atomic.Bool // whether a summary is scheduled
+}
+
+type rateLimitHandler struct {
+	handler Handler
+	// shared among all clones of this handler
+	levels   []Level // sorted
+	limiters []*levelLimiter
+}
+
+// limiter returns the limiter of the highest limited level up to level, nil if none.
+func (h *rateLimitHandler) limiter(level Level) *levelLimiter {
+	i, found := slices.BinarySearch(h.levels, level)
+	if found {
+		return h.limiters[i]
+	}
+	if i == 0 {
+		return nil
+	}
+	return h.limiters[i-1]
+}
+
+func (h *rateLimitHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *rateLimitHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *rateLimitHandler) Handle(ctx context.Context, record Record) error {
+	ll := h.limiter(record.Level)
+	if ll == nil {
+		return h.handler.Handle(ctx, record)
+	}
+	if !ll.limiter.Allow() {
+		ll.suppressed.Add(1)
+		if ll.scheduled.CompareAndSwap(false, true) {
+			time.AfterFunc(rateLimitSummaryInterval, func() {
+				ll.scheduled.Store(false)
+				if err := h.handleSuppressed(ll); err != nil {
+					reportError(err)
+				}
+			})
+		}
+		return nil
+	}
+
+	if err := h.handleSuppressed(ll); err != nil {
+		return err
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+// handleSuppressed emits the pending summary of ll, if any.
+func (h *rateLimitHandler) handleSuppressed(ll *levelLimiter) error {
+	n := ll.suppressed.Swap(0)
+	if n == 0 {
+		return nil
+	}
+	return h.handler.Handle(emptyCtx, suppressedRecord(ll.level, n))
+}
+
+// suppressedRecord returns the summary record of n suppressed records of level.
+func suppressedRecord(level Level, n uint64) Record {
+	return slog.NewRecord(time.Now(), level, fmt.Sprintf("suppressed %d messages", n), 0)
+}
+
+// handleAllSuppressed emits the pending summaries of the levels in order.
+func (h *rateLimitHandler) handleAllSuppressed() error {
+	var errs []error
+	for _, ll := range h.limiters {
+		if err := h.handleSuppressed(ll); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Flush implements Flusher. It emits the pending summaries,
+// and flushes the wrapped handler if it implements Flusher.
+func (h *rateLimitHandler) Flush() error {
+	err := h.handleAllSuppressed()
+	if f, ok := h.handler.(Flusher); ok {
+		err = errors.Join(err, f.Flush())
+	}
+	return err
+}
+
+// Close implements io.Closer. It emits the pending summaries,
+// and closes the wrapped handler if it implements io.Closer.
+func (h *rateLimitHandler) Close() error {
+	err := h.handleAllSuppressed()
+	if c, ok := h.handler.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+func (h *rateLimitHandler) WithAttrs(attrs []Attr) Handler {
+	return &rateLimitHandler{handler: h.handler.WithAttrs(attrs), levels: h.levels, limiters: h.limiters}
+}
+
+func (h *rateLimitHandler) WithGroup(name string) Handler {
+	return &rateLimitHandler{handler: h.handler.WithGroup(name), levels: h.levels, limiters: h.limiters}
+}
+
+// KeyFunc returns the key of the rate limit bucket of a record.
+type KeyFunc func(ctx context.Context, record Record) string
+
+// ContextKey returns a KeyFunc of the value of ctx for key,
+// e.g. a tenant ID, or an empty key if ctx has no such value.
+func ContextKey(key any) KeyFunc {
+	return func(ctx context.Context, _ Record) string {
+		v := ctx.Value(key)
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+// NewKeyedRateLimitHandler returns a Handler that lets at most limit records
+// per second, with bursts of burst records, of each key of keyFn through to h.
+// Records of a key whose bucket is empty are dropped, and a
+// `suppressed N messages` record is emitted once the bucket refills.
+// At most maxKeys buckets are kept, evicting the least recently used ones,
+// so an evicted key starts again with a full bucket.
+func NewKeyedRateLimitHandler(h Handler, keyFn KeyFunc, limit rate.Limit, burst, maxKeys int) Handler {
+	return &keyedRateLimitHandler{
+		handler: h,
+		keyFn:   keyFn,
+		limiters: &keyedLimiters{
+			limit:   limit,
+			burst:   burst,
+			maxKeys: maxKeys,
+			lru:     list.New(),
+			index:   make(map[string]*list.Element),
+		},
+	}
+}
+
+type keyedLimiter struct {
+	key        string
+	limiter    *rate.Limiter
+	suppressed uint64
+}
+
+// keyedLimiters is an LRU cache of the limiters of the keys.
+type keyedLimiters struct {
+	limit   rate.Limit
+	burst   int
+	maxKeys int
+
+	mu    sync.Mutex
+	lru   *list.List // of *keyedLimiter, the most recently used first
+	index map[string]*list.Element
+}
+
+// allow reports whether a record of key is allowed, and the number of
+// records of key suppressed since the last allowed one.
+func (l *keyedLimiters) allow(key string) (bool, uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var kl *keyedLimiter
+	if e, ok := l.index[key]; ok {
+		l.lru.MoveToFront(e)
+		kl = e.Value.(*keyedLimiter)
+	} else {
+		kl = &keyedLimiter{key: key, limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.index[key] = l.lru.PushFront(kl)
+		if l.maxKeys > 0 && l.lru.Len() > l.maxKeys {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.index, oldest.Value.(*keyedLimiter).key)
+		}
+	}
+
+	if !kl.limiter.Allow() {
+		kl.suppressed++
+		return false, 0
+	}
+	suppressed := kl.suppressed
+	kl.suppressed = 0
+	return true, suppressed
+}
+
+type keyedRateLimitHandler struct {
+	handler  Handler
+	keyFn    KeyFunc
+	limiters *keyedLimiters // shared among all clones of this handler
+}
+
+func (h *keyedRateLimitHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *keyedRateLimitHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *keyedRateLimitHandler) Handle(ctx context.Context, record Record) error {
+	ok, n := h.limiters.allow(h.keyFn(ctx, record))
+	if !ok {
+		return nil
+	}
+
+	if n > 0 {
+		if err := h.handler.Handle(ctx, suppressedRecord(record.Level, n)); err != nil {
+			return err
+		}
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *keyedRateLimitHandler) WithAttrs(attrs []Attr) Handler {
+	return &keyedRateLimitHandler{handler: h.handler.WithAttrs(attrs), keyFn: h.keyFn, limiters: h.limiters}
+}
+
+func (h *keyedRateLimitHandler) WithGroup(name string) Handler {
+	return &keyedRateLimitHandler{handler: h.handler.WithGroup(name), keyFn: h.keyFn, limiters: h.limiters}
+}
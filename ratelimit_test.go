@@ -0,0 +1,166 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type tenantKey struct{}
+
+func TestKeyedRateLimitHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewKeyedRateLimitHandler(NewLogHandler(&buf, nil, true), ContextKey(tenantKey{}), 0, 2, 2)
+	l := NewLogger(h)
+	a := context.WithValue(context.Background(), tenantKey{}, "a")
+	b := context.WithValue(context.Background(), tenantKey{}, "b")
+	c := context.WithValue(context.Background(), tenantKey{}, "c")
+
+	for i := 0; i < 5; i++ {
+		l.InfoCtx(a, "noisy")
+	}
+	l.InfoCtx(b, "quiet")
+	// c evicts the bucket of a, which starts again with a full bucket
+	l.InfoCtx(c, "quiet")
+	l.InfoCtx(a, "noisy")
+
+	if got := strings.Count(buf.String(), "noisy"); got != 3 {
+		t.Errorf("noisy records = %d, want 3", got)
+	}
+	if got := strings.Count(buf.String(), "quiet"); got != 2 {
+		t.Errorf("quiet records = %d, want 2", got)
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	defer func(d time.Duration) { rateLimitSummaryInterval = d }(rateLimitSummaryInterval)
+	rateLimitSummaryInterval = time.Hour
+
+	var buf bytes.Buffer
+	h := NewRateLimitHandler(NewLogfmtHandler(&buf, &HandlerOptions{Level: LevelDebug}, WithDisableTime(true)), map[Level]rate.Limit{
+		LevelInfo:  20,
+		LevelWarn:  0,
+		LevelError: rate.Inf,
+	})
+	l := NewLogger(h).With("a", 1)
+
+	// a burst of 20 infos, the 5 last ones are dropped
+	for i := 0; i < 25; i++ {
+		l.Info("info")
+	}
+	// the limit of warn covers warn+2
+	for i := 0; i < 2; i++ {
+		l.Warn("warn")
+	}
+	l.Log(LevelWarn+2, "warn+2")
+	l.Error("error")
+	l.Log(LevelError+4, "error+4")
+	l.Debug("debug")
+	if got := strings.Count(buf.String(), "msg=info"); got != 20 {
+		t.Errorf("info records = %d, want 20", got)
+	}
+	if got := strings.Count(buf.String(), "msg=warn"); got != 1 {
+		t.Errorf("warn records = %d, want 1", got)
+	}
+	for _, msg := range []string{"msg=error ", "msg=error+4 ", "msg=debug "} {
+		if !strings.Contains(buf.String(), msg) {
+			t.Errorf("output = %q, want the unlimited %s", buf.String(), msg)
+		}
+	}
+
+	// the summary comes with the next info once the bucket refills
+	time.Sleep(100 * time.Millisecond)
+	buf.Reset()
+	l.Info("info")
+	if got, want := buf.String(), "level=INFO msg=\"suppressed 5 messages\" a=1\nlevel=INFO msg=info a=1\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// no more warn is let through, Flush emits the pending summary
+	buf.Reset()
+	if err := h.(Flusher).Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "level=WARN msg=\"suppressed 2 messages\"\n"; got != want {
+		t.Errorf("Flush() output = %q, want %q", got, want)
+	}
+	buf.Reset()
+	if err := h.(Flusher).Flush(); err != nil || buf.Len() != 0 {
+		t.Errorf("second Flush() = %v, output %q, want nothing", err, buf.String())
+	}
+}
+
+func TestRateLimitHandler_Enabled(t *testing.T) {
+	h := NewRateLimitHandler(NewLogHandler(&bytes.Buffer{}, &HandlerOptions{Level: LevelWarn}, true), map[Level]rate.Limit{
+		LevelInfo: rate.Inf,
+	})
+	if h.Enabled(context.Background(), LevelInfo) || !h.Enabled(context.Background(), LevelWarn) {
+		t.Error("Enabled() doesn't delegate to the wrapped handler")
+	}
+}
+
+// lockedWriter serializes the writes of the timers with the reads of the test.
+type lockedWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *lockedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestRateLimitHandler_Summary(t *testing.T) {
+	defer func(d time.Duration) { rateLimitSummaryInterval = d }(rateLimitSummaryInterval)
+	rateLimitSummaryInterval = 20 * time.Millisecond
+
+	var w lockedWriter
+	l := NewLogger(NewRateLimitHandler(NewLogfmtHandler(&w, nil, WithDisableTime(true)), map[Level]rate.Limit{
+		LevelWarn: 0,
+	}))
+	// the level is saturated and then goes quiet
+	for i := 0; i < 4; i++ {
+		l.Error("error")
+	}
+	for i := 0; i < 100 && !strings.Contains(w.String(), "suppressed"); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := w.String(), "level=ERROR msg=error\nlevel=WARN msg=\"suppressed 3 messages\"\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// a summary is scheduled again for the next dropped records
+	l.Error("error")
+	for i := 0; i < 100 && strings.Count(w.String(), "suppressed") < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := w.String(); !strings.HasSuffix(got, "level=WARN msg=\"suppressed 1 messages\"\n") {
+		t.Errorf("output = %q, want a second summary", got)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// WithSwallowPanics returns a Logger whose Recover swallows
+// the panics it logs instead of re-panicking.
+func (l *Logger) WithSwallowPanics(enabled bool) *Logger {
+	c := l.clone()
+	c.swallow = enabled
+	return c
+}
+
+// Recover logs the recovered panic with its stack trace at LevelError,
+// then panics again with the same value unless WithSwallowPanics is enabled.
+// It must be called directly by defer:
+//
+//	defer l.Recover()
+func (l *Logger) Recover() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	l.logPanic(emptyCtx, v)
+	if !l.swallow {
+		panic(v)
+	}
+}
+
+// RecoverMiddleware returns an HTTP middleware that logs the panics of
+// the handlers like Logger.Recover, and responds with 500 instead.
+// The http.ErrAbortHandler panics are passed through to abort the response.
+func RecoverMiddleware(l *Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				l.logPanic(r.Context(), v, slog.String("method", r.Method), slog.String("path", r.URL.Path))
+				w.WriteHeader(http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logPanic logs the panic value v at LevelError, with the source and
+// the stack trace of the panicking function.
+func (l *Logger) logPanic(ctx context.Context, v any, attrs ...Attr) {
+	if !l.EnabledCtx(ctx, LevelError) {
+		return
+	}
+	pc, stack := panicStack()
+	attrs = append(attrs, slog.String(StackKey, stack))
+	l.logAttrsPC(ctx, LevelError, pc, fmt.Sprintf("panic: %v", v), attrs...)
+}
+
+// panicStack returns the PC of the panicking function and the stack trace
+// from it, when called by the deferred function recovering the panic.
+func panicStack() (uintptr, string) {
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	var stack []runtime.Frame
+	frames := runtime.CallersFrames(pcs[:n])
+	inRuntime := false
+	for {
+		f, more := frames.Next()
+		switch {
+		case f.Function == "runtime.gopanic":
+			// skip the frames of the recovery
+			stack = stack[:0]
+			inRuntime = true
+		case inRuntime && strings.HasPrefix(f.Function, "runtime."):
+			// skip the runtime frames raising the panic,
+			// e.g. runtime.panicmem and runtime.sigpanic
+		default:
+			inRuntime = false
+			stack = append(stack, f)
+		}
+		if !more {
+			break
+		}
+	}
+	if len(stack) == 0 {
+		return 0, ""
+	}
+
+	var b strings.Builder
+	for _, f := range stack {
+		b.WriteString(f.Function)
+		b.WriteString("\n\t")
+		b.WriteString(f.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(f.Line))
+		b.WriteByte('\n')
+	}
+	return stack[0].PC + 1, b.String()
+}
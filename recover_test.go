@@ -0,0 +1,107 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// panicLine is the line before the panic of panicking.
+var panicLine int
+
+func panicking() {
+	_, _, panicLine, _ = runtime.Caller(0)
+	panic("boom")
+}
+
+func TestLogger_Recover(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewLogHandler(&buf, &HandlerOptions{AddSource: true}, true, WithSourceFormat(SourceShort))
+	l := NewLogger(h)
+
+	func() {
+		defer l.WithSwallowPanics(true).Recover()
+		panicking()
+	}()
+	_, file, _, _ := runtime.Caller(0)
+	got := buf.String()
+	want := `source="` + filepath.Base(file) + ":" + strconv.Itoa(panicLine+1) + `"`
+	if !strings.Contains(got, "ERROR") || !strings.Contains(got, "panic: boom") || !strings.Contains(got, want) {
+		t.Errorf("Recover() = %q, want %s", got, want)
+	}
+	if !strings.Contains(got, StackKey+`="github.com/zc2638/wslog.panicking\n`) {
+		t.Errorf("Recover() = %q, want the stack from the panic", got)
+	}
+
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("recover() = %v, want the panic again", v)
+		}
+	}()
+	defer l.Recover()
+	panicking()
+}
+
+//go:noinline
+func derefNil(p *int) int {
+	return *p
+}
+
+//go:noinline
+func indexOutOfRange(s []int, i int) int {
+	return s[i]
+}
+
+func TestLogger_Recover_RuntimeError(t *testing.T) {
+	for name, fn := range map[string]func(){
+		"derefNil":        func() { derefNil(nil) },
+		"indexOutOfRange": func() { indexOutOfRange(nil, 1) },
+	} {
+		var buf bytes.Buffer
+		l := NewLogger(NewLogHandler(&buf, nil, true))
+		func() {
+			defer l.WithSwallowPanics(true).Recover()
+			fn()
+		}()
+		// the runtime frames raising the panic are skipped
+		if got := buf.String(); !strings.Contains(got, StackKey+`="github.com/zc2638/wslog.`+name+`\n`) {
+			t.Errorf("Recover() of %s = %q, want the stack from %s", name, got, name)
+		}
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLogger(NewLogHandler(&buf, nil, true))
+	h := RecoverMiddleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panicking()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/path", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+	if got := buf.String(); !strings.Contains(got, "panic: boom") || !strings.Contains(got, "path=/path") {
+		t.Errorf("output = %q", got)
+	}
+}
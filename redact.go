@@ -0,0 +1,185 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RedactMask is the value that replaces redacted attributes.
+const RedactMask = "***"
+
+// RedactRule matches the attributes to redact.
+type RedactRule struct {
+	match func(key, fullKey string) bool
+	hash  bool
+}
+
+// RedactKey matches attributes whose key equals key, in any group.
+// The comparison is case-insensitive.
+func RedactKey(key string) RedactRule {
+	return RedactRule{match: func(k, _ string) bool {
+		return strings.EqualFold(k, key)
+	}}
+}
+
+// RedactGlob matches attributes whose dot-separated group-qualified key
+// matches the glob pattern, e.g. `*.secret` matches `db.secret`.
+// See path.Match for the pattern syntax.
+func RedactGlob(pattern string) RedactRule {
+	return RedactRule{match: func(_, fullKey string) bool {
+		matched, _ := path.Match(pattern, fullKey)
+		return matched
+	}}
+}
+
+// RedactRegexp matches attributes whose dot-separated group-qualified key
+// matches re.
+func RedactRegexp(re *regexp.Regexp) RedactRule {
+	return RedactRule{match: func(_, fullKey string) bool {
+		return re.MatchString(fullKey)
+	}}
+}
+
+// Hash returns a copy of r that replaces the matched values with a
+// sha256 hash of the value instead of RedactMask, so that equal values
+// can still be correlated.
+func (r RedactRule) Hash() RedactRule {
+	r.hash = true
+	return r
+}
+
+// parseRedactKey converts a Config redact key to a RedactRule,
+// keys containing glob meta characters are matched as globs.
+func parseRedactKey(key string) RedactRule {
+	if strings.ContainsAny(key, `*?[\`) {
+		return RedactGlob(key)
+	}
+	return RedactKey(key)
+}
+
+// DefaultRedactRules are the rules of NewRedactHandler without rules,
+// matching the common secret keys in any group.
+var DefaultRedactRules = []RedactRule{
+	RedactKey("password"),
+	RedactKey("authorization"),
+	RedactKey("token"),
+}
+
+// NewRedactHandler returns a Handler that masks the values of the attributes
+// matched by any of the rules before passing the records to h, or by
+// DefaultRedactRules if no rules are given.
+// LogValuer values are resolved and groups are descended before matching.
+func NewRedactHandler(h Handler, rules ...RedactRule) Handler {
+	if len(rules) == 0 {
+		rules = DefaultRedactRules
+	}
+	return &redactHandler{handler: h, rules: rules}
+}
+
+type redactHandler struct {
+	handler Handler
+	rules   []RedactRule
+	groups  []string
+}
+
+func (h *redactHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	handler, ok := handlerWithOptions(h.handler, mutate)
+	if !ok {
+		return nil, false
+	}
+	cp := *h
+	cp.handler = handler
+	return &cp, true
+}
+
+func (h *redactHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record Record) error {
+	r := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr Attr) bool {
+		r.AddAttrs(h.redact(h.groups, attr, 0))
+		return true
+	})
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *redactHandler) WithAttrs(attrs []Attr) Handler {
+	redacted := make([]Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, h.redact(h.groups, attr, 0))
+	}
+	return &redactHandler{handler: h.handler.WithAttrs(redacted), rules: h.rules, groups: h.groups}
+}
+
+func (h *redactHandler) WithGroup(name string) Handler {
+	return &redactHandler{
+		handler: h.handler.WithGroup(name),
+		rules:   h.rules,
+		groups:  append(slices.Clip(h.groups), name),
+	}
+}
+
+func (h *redactHandler) redact(groups []string, a Attr, depth int) Attr {
+	a.Value = resolveValue(a.Value)
+	if a.Value.Kind() == KindGroup && depth >= maxAttrDepth {
+		a.Value = slog.StringValue(BadValue)
+	}
+
+	if a.Value.Kind() == KindGroup {
+		as := a.Value.Group()
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		redacted := make([]Attr, 0, len(as))
+		for _, ga := range as {
+			redacted = append(redacted, h.redact(g2, ga, depth+1))
+		}
+		a.Value = slog.GroupValue(redacted...)
+		return a
+	}
+
+	fullKey := a.Key
+	if len(groups) > 0 {
+		fullKey = strings.Join(groups, ".") + "." + a.Key
+	}
+	for _, rule := range h.rules {
+		if !rule.match(a.Key, fullKey) {
+			continue
+		}
+		if rule.hash {
+			sum := sha256.Sum256([]byte(a.Value.String()))
+			a.Value = slog.StringValue("sha256:" + hex.EncodeToString(sum[:8]))
+		} else {
+			a.Value = slog.StringValue(RedactMask)
+		}
+		break
+	}
+	return a
+}
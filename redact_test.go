@@ -0,0 +1,142 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+type secretValuer string
+
+func (s secretValuer) LogValue() slog.Value {
+	return slog.StringValue(string(s))
+}
+
+func TestNewRedactHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []RedactRule
+		log   func(l *Logger)
+		want  map[string]any
+	}{
+		{
+			name:  "key",
+			rules: []RedactRule{RedactKey("password")},
+			log: func(l *Logger) {
+				l.Info("msg", "password", "123456", "user", "admin")
+			},
+			want: map[string]any{"password": RedactMask, "user": "admin"},
+		},
+		{
+			name:  "nested groups",
+			rules: []RedactRule{RedactKey("token"), RedactGlob("*.secret")},
+			log: func(l *Logger) {
+				l.Info("msg",
+					slog.Group("db", "secret", "s1", "name", "n1"),
+					slog.Group("http", slog.Group("header", "token", "t1")),
+					"secret", "top",
+				)
+			},
+			want: map[string]any{
+				"db":     map[string]any{"secret": RedactMask, "name": "n1"},
+				"http":   map[string]any{"header": map[string]any{"token": RedactMask}},
+				"secret": "top",
+			},
+		},
+		{
+			name:  "with attrs and groups",
+			rules: []RedactRule{RedactRegexp(regexp.MustCompile(`^req\.auth`))},
+			log: func(l *Logger) {
+				l.With("authorization", "a0").
+					WithGroup("req").
+					With("authorization", "a1").
+					Info("msg", "auth", "a2")
+			},
+			want: map[string]any{
+				"authorization": "a0",
+				"req":           map[string]any{"authorization": RedactMask, "auth": RedactMask},
+			},
+		},
+		{
+			name:  "log valuer",
+			rules: []RedactRule{RedactKey("password")},
+			log: func(l *Logger) {
+				l.Info("msg", "password", secretValuer("123456"))
+			},
+			want: map[string]any{"password": RedactMask},
+		},
+		{
+			name: "default rules",
+			log: func(l *Logger) {
+				l.Info("msg", "Password", "p", slog.Group("http", "authorization", "a", "token", "t"), "user", "admin")
+			},
+			want: map[string]any{
+				"Password": RedactMask,
+				"http":     map[string]any{"authorization": RedactMask, "token": RedactMask},
+				"user":     "admin",
+			},
+		},
+		{
+			name:  "self valuer",
+			rules: []RedactRule{RedactKey("password")},
+			log: func(l *Logger) {
+				l.Info("msg", "v", selfValuer{}, "password", "123456")
+			},
+			want: map[string]any{"v": BadValue, "password": RedactMask},
+		},
+		{
+			name:  "inline group valuer",
+			rules: []RedactRule{RedactKey("password")},
+			log: func(l *Logger) {
+				l.Info("msg", "v", groupValuer{""}, "password", "123456")
+			},
+			want: map[string]any{"v": map[string]any{"": BadValue}, "password": RedactMask},
+		},
+		{
+			name:  "hash",
+			rules: []RedactRule{RedactKey("password").Hash()},
+			log: func(l *Logger) {
+				l.Info("msg", "password", "123456")
+			},
+			want: map[string]any{"password": "sha256:8d969eef6ecad3c2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			replaceAttr := func(groups []string, a Attr) Attr {
+				if len(groups) == 0 && (a.Key == TimeKey || a.Key == LevelKey || a.Key == MessageKey) {
+					return Attr{}
+				}
+				return a
+			}
+			h := slog.NewJSONHandler(&buf, &HandlerOptions{ReplaceAttr: replaceAttr})
+			tt.log(NewLogger(NewRedactHandler(h, tt.rules...)))
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewRedactHandler() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRingHandler returns a Handler that keeps the last size records
+// in memory and delegates to h, along with a function that returns
+// a snapshot of the kept records from oldest to newest, e.g. to dump
+// the recent context when recovering from a panic.
+// The ring is allocated once and shared by the handlers derived by
+// WithAttrs and WithGroup. The kept records don't include the attrs added by WithAttrs.
+func NewRingHandler(h Handler, size int) (Handler, func() []Record) {
+	r := newRing(size)
+	return &ringHandler{handler: h, ring: r}, r.records
+}
+
+type ringHandler struct {
+	handler Handler
+	ring    *ring // shared among all clones of this handler
+}
+
+func (h *ringHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *ringHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *ringHandler) Handle(ctx context.Context, record Record) error {
+	h.ring.add(record, nil, nil)
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *ringHandler) WithAttrs(attrs []Attr) Handler {
+	return &ringHandler{handler: h.handler.WithAttrs(attrs), ring: h.ring}
+}
+
+func (h *ringHandler) WithGroup(name string) Handler {
+	return &ringHandler{handler: h.handler.WithGroup(name), ring: h.ring}
+}
+
+// ring keeps the last records in fixed slots.
+type ring struct {
+	mu    sync.Mutex
+	slots []ringSlot
+	next  int
+	full  bool
+}
+
+// ringSlot is a record of the ring, whose attrs reuse the array
+// of the previous record of the slot, so that the ring stops
+// allocating once each slot held a record with as many attrs.
+type ringSlot struct {
+	time    time.Time
+	level   Level
+	message string
+	pc      uintptr
+	attrs   []Attr
+	groups  []string
+	with    *ringAttrs // attrs of WithAttrs, nil if none
+}
+
+// ringAttrs are the attrs added by WithAttrs,
+// which are immutable once the handler is derived.
+type ringAttrs struct {
+	parent *ringAttrs
+	groups []string
+	attrs  []Attr
+}
+
+func newRing(size int) *ring {
+	if size < 1 {
+		size = 1
+	}
+	return &ring{slots: make([]ringSlot, size)}
+}
+
+func (r *ring) add(record Record, groups []string, with *ringAttrs) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	slot := &r.slots[r.next]
+	slot.time, slot.level, slot.message, slot.pc = record.Time, record.Level, record.Message, record.PC
+	slot.attrs = slot.attrs[:0]
+	record.Attrs(func(a Attr) bool {
+		slot.attrs = append(slot.attrs, a)
+		return true
+	})
+	slot.groups, slot.with = groups, with
+	r.next++
+	if r.next == len(r.slots) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// snapshot returns the kept slots from oldest to newest,
+// with their records rebuilt from the attrs of the slots.
+func (r *ring) snapshot() []ringEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var slots []ringSlot
+	if r.full {
+		slots = append(slots, r.slots[r.next:]...)
+	}
+	slots = append(slots, r.slots[:r.next]...)
+	entries := make([]ringEntry, len(slots))
+	for i, slot := range slots {
+		record := slog.NewRecord(slot.time, slot.level, slot.message, slot.pc)
+		record.AddAttrs(slot.attrs...)
+		entries[i] = ringEntry{record: record, groups: slot.groups, attrs: slot.with}
+	}
+	return entries
+}
+
+// records returns the kept records from oldest to newest.
+func (r *ring) records() []Record {
+	entries := r.snapshot()
+	records := make([]Record, len(entries))
+	for i, entry := range entries {
+		records[i] = entry.record
+	}
+	return records
+}
+
+type ringEntry struct {
+	record Record
+	groups []string
+	attrs  *ringAttrs
+}
+
+// RingEntry is a record kept by RingBuffer, with its attrs
+// flattened to their group-qualified keys, e.g. `http.method`.
+type RingEntry struct {
+	Time    time.Time         `json:"time"`
+	Level   Level             `json:"level"`
+	Message string            `json:"msg"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// NewRingBuffer creates a RingBuffer keeping the last capacity records
+// of at least level, LevelInfo if nil, in memory. Combine it with the
+// main handler with NewMultiHandler.
+func NewRingBuffer(capacity int, level Leveler) *RingBuffer {
+	return &RingBuffer{ring: newRing(capacity), level: level}
+}
+
+// RingBuffer is a Handler keeping the last records in a fixed-size ring,
+// e.g. to serve them on a debug page as an http.Handler. Unlike the
+// handler of NewRingHandler, it keeps the attrs added by WithAttrs.
+// The ring is allocated once and shared by the handlers derived by
+// WithAttrs and WithGroup. The records are rendered when they are read.
+type RingBuffer struct {
+	ring  *ring // shared among all clones of this handler
+	level Leveler
+
+	groups []string
+	attrs  *ringAttrs // attrs of WithAttrs, nil if none
+}
+
+func (h *RingBuffer) NeedsSource() bool {
+	return false
+}
+
+func (h *RingBuffer) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.level)
+}
+
+func (h *RingBuffer) Handle(_ context.Context, record Record) error {
+	h.ring.add(record, h.groups, h.attrs)
+	return nil
+}
+
+func (h *RingBuffer) WithAttrs(attrs []Attr) Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &RingBuffer{
+		ring:   h.ring,
+		level:  h.level,
+		groups: h.groups,
+		attrs:  &ringAttrs{parent: h.attrs, groups: h.groups, attrs: slices.Clone(attrs)},
+	}
+}
+
+func (h *RingBuffer) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	return &RingBuffer{
+		ring:   h.ring,
+		level:  h.level,
+		groups: append(slices.Clip(h.groups), name),
+		attrs:  h.attrs,
+	}
+}
+
+// Records returns a copy of the kept records from oldest to newest,
+// without the attrs added by WithAttrs.
+func (h *RingBuffer) Records() []Record {
+	return h.ring.records()
+}
+
+// Last returns the last n kept records from oldest to newest,
+// all of them if n isn't positive.
+func (h *RingBuffer) Last(n int) []RingEntry {
+	entries := h.ring.snapshot()
+	if n > 0 && n < len(entries) {
+		entries = entries[len(entries)-n:]
+	}
+	result := make([]RingEntry, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.render()
+	}
+	return result
+}
+
+// Filter returns the kept records from oldest to newest of at least level,
+// whose message, attr keys or attr values contain substring.
+func (h *RingBuffer) Filter(level Level, substring string) []RingEntry {
+	var result []RingEntry
+	for _, entry := range h.ring.snapshot() {
+		if entry.record.Level < level {
+			continue
+		}
+		if e := entry.render(); e.contains(substring) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// ServeHTTP implements http.Handler, and serves the kept records
+// from oldest to newest. The query parameters are
+//   - level: the minimum level, e.g. `warn` or `error+2`
+//   - q: a substring to search for, see Filter
+//   - limit: the maximum number of the newest records to serve
+//   - format: `json` (default) for an array of RingEntry,
+//     or `text` for a line per record
+func (h *RingBuffer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	level := Level(math.MinInt)
+	if s := query.Get("level"); s != "" {
+		level = SLevel(s).Level()
+	}
+	limit := 0
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries := h.Filter(level, query.Get("q"))
+	if limit > 0 && limit < len(entries) {
+		entries = entries[len(entries)-limit:]
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		if entries == nil {
+			entries = []RingEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(entries)
+	case "text":
+		var buf bytes.Buffer
+		for _, e := range entries {
+			e.appendText(&buf)
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(buf.Bytes())
+	default:
+		http.Error(w, "invalid format", http.StatusBadRequest)
+	}
+}
+
+func (e ringEntry) render() RingEntry {
+	result := RingEntry{
+		Time:    e.record.Time,
+		Level:   e.record.Level,
+		Message: e.record.Message,
+	}
+	add := func(groups []string, a Attr) {
+		if result.Attrs == nil {
+			result.Attrs = make(map[string]string)
+		}
+		flattenRingAttr(result.Attrs, groups, a)
+	}
+	// the attrs of WithAttrs from outermost to innermost
+	var chain []*ringAttrs
+	for ra := e.attrs; ra != nil; ra = ra.parent {
+		chain = append(chain, ra)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, a := range chain[i].attrs {
+			add(chain[i].groups, a)
+		}
+	}
+	e.record.Attrs(func(a Attr) bool {
+		add(e.groups, a)
+		return true
+	})
+	return result
+}
+
+// flattenRingAttr adds a to attrs with its group-qualified key.
+func flattenRingAttr(attrs map[string]string, groups []string, a Attr) {
+	a.Value = resolveValue(a.Value)
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			flattenRingAttr(attrs, g2, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	attrs[key] = a.Value.String()
+}
+
+func (e RingEntry) contains(substring string) bool {
+	if substring == "" || strings.Contains(e.Message, substring) {
+		return true
+	}
+	for key, value := range e.Attrs {
+		if strings.Contains(key, substring) || strings.Contains(value, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendText writes e as a line, e.g.
+// `2023-08-18T01:02:03.000Z INFO hello key=value`.
+func (e RingEntry) appendText(buf *bytes.Buffer) {
+	buf.WriteString(e.Time.Format(logfmtTimeLayout))
+	buf.WriteByte(' ')
+	buf.WriteString(e.Level.String())
+	buf.WriteByte(' ')
+	buf.WriteString(messageEscaper.Replace(e.Message))
+
+	keys := make([]string, 0, len(e.Attrs))
+	for key := range e.Attrs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		if value := e.Attrs[key]; needsQuoting(value) {
+			buf.WriteString(strconv.Quote(value))
+		} else {
+			buf.WriteString(value)
+		}
+	}
+	buf.WriteByte('\n')
+}
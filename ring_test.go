@@ -0,0 +1,126 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h, snapshot := NewRingHandler(NewLogfmtHandler(&buf, &HandlerOptions{Level: LevelWarn}, WithDisableTime(true)), 2)
+	logger := NewLogger(h)
+	logger.Info("dropped")
+	logger.With("a", 1).Warn("first", "b", 1)
+	logger.WithGroup("g").Error("second", "c", 1)
+	logger.Error("third", "d", 1, "e", 2, "f", 3, "g", 4, "h", 5, "i", 6)
+
+	// the records are delegated to h
+	if got := strings.Count(buf.String(), "\n"); got != 3 || strings.Contains(buf.String(), "dropped") {
+		t.Errorf("output = %q, want the 3 enabled records", buf.String())
+	}
+	records := snapshot()
+	if len(records) != 2 || records[0].Message != "second" || records[1].Message != "third" || records[1].NumAttrs() != 6 {
+		t.Fatalf("snapshot() = %v, want second and third", records)
+	}
+	// the snapshot doesn't share the attrs of the ring
+	records[1].AddAttrs(slog.Int("j", 7))
+	if got := snapshot()[1].NumAttrs(); got != 6 {
+		t.Errorf("NumAttrs() = %d, want 6", got)
+	}
+
+	// the ring reuses its slots once they held as many attrs
+	record := slog.NewRecord(time.Now(), LevelError, "msg", 0)
+	record.AddAttrs(slog.Int("a", 1), slog.Int("b", 2), slog.Int("c", 3), slog.Int("d", 4), slog.Int("e", 5), slog.Int("f", 6))
+	h, _ = NewRingHandler(nopHandler{}, 2)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = h.Handle(context.Background(), record)
+	})
+	if allocs != 0 {
+		t.Errorf("Handle() allocs = %v, want 0", allocs)
+	}
+}
+
+type nopHandler struct{}
+
+func (nopHandler) Enabled(context.Context, Level) bool  { return true }
+func (nopHandler) Handle(context.Context, Record) error { return nil }
+func (h nopHandler) WithAttrs([]Attr) Handler           { return h }
+func (h nopHandler) WithGroup(string) Handler           { return h }
+
+func TestRingBuffer(t *testing.T) {
+	ring := NewRingBuffer(3, LevelInfo)
+	var buf bytes.Buffer
+	logger := NewLogger(NewMultiHandler(NewLogHandler(&buf, &HandlerOptions{Level: LevelDebug}, true), ring))
+	logger.Debug("dropped")
+	if ring.Enabled(context.Background(), LevelDebug) || !ring.Enabled(WithLevel(context.Background(), LevelDebug), LevelDebug) {
+		t.Error("Enabled() ignores the level")
+	}
+	logger.With("app", "test").WithGroup("req").Info("first", "id", 1)
+	logger.Warn("second", "path", "/a b")
+	logger.Error("third", slog.Group("db", "table", "users"))
+
+	last := ring.Last(0)
+	if len(last) != 3 {
+		t.Fatalf("Last(0) = %v, want 3 records", last)
+	}
+	if e := last[0]; e.Message != "first" || e.Level != LevelInfo ||
+		e.Attrs["app"] != "test" || e.Attrs["req.id"] != "1" {
+		t.Errorf("Last(0)[0] = %+v", e)
+	}
+	if got := ring.Last(1); len(got) != 1 || got[0].Message != "third" {
+		t.Errorf("Last(1) = %+v", got)
+	}
+	if got := ring.Filter(LevelWarn, "users"); len(got) != 1 || got[0].Attrs["db.table"] != "users" {
+		t.Errorf("Filter() = %+v", got)
+	}
+	if got := ring.Records(); len(got) != 3 || got[2].Message != "third" {
+		t.Errorf("Records() = %v", got)
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 4 {
+		t.Errorf("the main handler wrote %d records, want 4", got)
+	}
+
+	rec := httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?level=warn&limit=1", nil))
+	var entries []RingEntry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "third" || entries[0].Level != LevelError {
+		t.Errorf("ServeHTTP() = %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?format=text&q=second", nil))
+	body, _ := io.ReadAll(rec.Body)
+	if got, want := string(body), ` WARN second path="/a b"`+"\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("ServeHTTP() = %q, want suffix %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	ring.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?limit=x", nil))
+	if rec.Code != 400 {
+		t.Errorf("ServeHTTP() status = %d, want 400", rec.Code)
+	}
+}
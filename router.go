@@ -0,0 +1,205 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+)
+
+// NewLevelRouter creates a Handler that passes the records below threshold
+// to below, and the others to above, e.g. to write the warnings and errors
+// to os.Stderr and the rest to os.Stdout.
+func NewLevelRouter(threshold Leveler, below, above Handler) Handler {
+	return &levelRouter{threshold: threshold, below: below, above: above}
+}
+
+type levelRouter struct {
+	threshold Leveler
+	below     Handler
+	above     Handler
+}
+
+// route returns the handler of level.
+func (h *levelRouter) route(level Level) Handler {
+	if level < h.threshold.Level() {
+		return h.below
+	}
+	return h.above
+}
+
+func (h *levelRouter) NeedsSource() bool {
+	return needsSource(h.below) || needsSource(h.above)
+}
+
+func (h *levelRouter) Enabled(ctx context.Context, level Level) bool {
+	return h.route(level).Enabled(ctx, level)
+}
+
+func (h *levelRouter) Handle(ctx context.Context, record Record) error {
+	return h.route(record.Level).Handle(ctx, record)
+}
+
+func (h *levelRouter) WithAttrs(attrs []Attr) Handler {
+	return &levelRouter{threshold: h.threshold, below: h.below.WithAttrs(attrs), above: h.above.WithAttrs(attrs)}
+}
+
+func (h *levelRouter) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	return &levelRouter{threshold: h.threshold, below: h.below.WithGroup(name), above: h.above.WithGroup(name)}
+}
+
+// Flush implements Flusher, and flushes the handlers implementing it.
+func (h *levelRouter) Flush() error {
+	var errs []error
+	for _, handler := range []Handler{h.below, h.above} {
+		if f, ok := handler.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close implements io.Closer, and closes the handlers implementing it.
+func (h *levelRouter) Close() error {
+	var errs []error
+	for _, handler := range []Handler{h.below, h.above} {
+		if c, ok := handler.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// minLevelHandler passes the records of at least level to handler.
+// Unlike HandlerOptions.Level, level ignores the level override of the
+// context set by WithLevel, so that e.g. a file of the errors never
+// receives the debug records of a traced request.
+type minLevelHandler struct {
+	handler Handler
+	level   Level
+}
+
+func (h *minLevelHandler) withOptions(mutate func(opts *HandlerOptions)) (Handler, bool) {
+	handler, ok := handlerWithOptions(h.handler, mutate)
+	if !ok {
+		return nil, false
+	}
+	return &minLevelHandler{handler: handler, level: h.level}, true
+}
+
+func (h *minLevelHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *minLevelHandler) Enabled(ctx context.Context, level Level) bool {
+	return level >= h.level && h.handler.Enabled(ctx, level)
+}
+
+func (h *minLevelHandler) Handle(ctx context.Context, record Record) error {
+	if record.Level < h.level {
+		return nil
+	}
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *minLevelHandler) WithAttrs(attrs []Attr) Handler {
+	return &minLevelHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *minLevelHandler) WithGroup(name string) Handler {
+	return &minLevelHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
+
+// NewLevelFileHandler creates a Handler writing the records of at least
+// each level of files to the file of its Config, with the rotation
+// settings and the Format of the Config, e.g.
+//
+//	wslog.NewLevelFileHandler(map[wslog.Level]wslog.Config{
+//		wslog.LevelInfo:  {Filename: "app.log", Format: "json"},
+//		wslog.LevelError: {Filename: "error.log", Format: "json"},
+//	})
+//
+// The levels overlap, e.g. the errors are written to both files above,
+// and the level override of the context set by WithLevel doesn't lower
+// them. The Level of each Config is ignored, and a Config without Filename
+// writes to os.Stderr. Close the returned handler to close the files.
+func NewLevelFileHandler(files map[Level]Config) Handler {
+	levels := make([]Level, 0, len(files))
+	for level := range files {
+		levels = append(levels, level)
+	}
+	slices.Sort(levels)
+
+	var (
+		handlers = make([]Handler, 0, len(levels))
+		writers  []io.Closer
+	)
+	for _, level := range levels {
+		cfg := files[level]
+		w := NewWriter(cfg)
+		if cfg.Filename != "" {
+			writers = append(writers, w)
+		}
+		opts := cfg.HandlerOptions()
+		opts.Level = level
+		handlers = append(handlers, &minLevelHandler{handler: cfg.newHandler(w, opts), level: level})
+	}
+	return &levelFileHandler{handler: NewMultiHandler(handlers...), writers: writers}
+}
+
+type levelFileHandler struct {
+	handler Handler
+	writers []io.Closer // shared among all clones of this handler
+}
+
+func (h *levelFileHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *levelFileHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *levelFileHandler) Handle(ctx context.Context, record Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *levelFileHandler) WithAttrs(attrs []Attr) Handler {
+	return &levelFileHandler{handler: h.handler.WithAttrs(attrs), writers: h.writers}
+}
+
+func (h *levelFileHandler) WithGroup(name string) Handler {
+	return &levelFileHandler{handler: h.handler.WithGroup(name), writers: h.writers}
+}
+
+// Close implements io.Closer, and closes the files.
+func (h *levelFileHandler) Close() error {
+	var errs []error
+	for _, w := range h.writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLevelRouter(t *testing.T) {
+	var below, above bytes.Buffer
+	h := NewLevelRouter(LevelWarn,
+		NewJSONHandler(&below, &HandlerOptions{Level: LevelDebug}, ""),
+		NewJSONHandler(&above, nil, ""),
+	)
+	l := NewLogger(h).With("a", 1).WithGroup("g")
+	l.Debug("debug", "b", 2)
+	l.Info("info")
+	l.Warn("warn")
+	l.Error("error")
+
+	if got := strings.Count(below.String(), "\n"); got != 2 {
+		t.Fatalf("below = %q, want 2 records", below.String())
+	}
+	if !strings.Contains(below.String(), `"msg":"debug","a":1,"g":{"b":2}`) {
+		t.Errorf("below = %q, want the attrs and group", below.String())
+	}
+	if strings.Contains(below.String(), "warn") || strings.Contains(above.String(), "info") {
+		t.Errorf("below = %q, above = %q, records are misrouted", below.String(), above.String())
+	}
+	if got := strings.Count(above.String(), "\n"); got != 2 {
+		t.Fatalf("above = %q, want 2 records", above.String())
+	}
+}
+
+func TestNew_SplitStdStreams(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		below []string
+		above []string
+	}{
+		{
+			name:  "colored",
+			cfg:   Config{Level: SLevelDebug, SplitStdStreams: true},
+			below: []string{"debug", "info"},
+			above: []string{"warn", "error"},
+		},
+		{
+			name:  "json",
+			cfg:   Config{Level: SLevelDebug, Format: "json", SplitStdStreams: true, SplitLevel: "error"},
+			below: []string{"debug", "info", "warn"},
+			above: []string{"error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			stdout, stderr := swapStdFile(t, &os.Stdout, filepath.Join(dir, "stdout")), swapStdFile(t, &os.Stderr, filepath.Join(dir, "stderr"))
+
+			l := New(tt.cfg)
+			l.Debug("debug")
+			l.Info("info")
+			l.Warn("warn")
+			l.Error("error")
+
+			for file, want := range map[string][]string{stdout: tt.below, stderr: tt.above} {
+				b, err := os.ReadFile(file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+				if len(lines) != len(want) {
+					t.Fatalf("%s = %q, want %d records", filepath.Base(file), b, len(want))
+				}
+				for i, msg := range want {
+					if !strings.Contains(lines[i], msg) {
+						t.Errorf("%s record %d = %q, want %s", filepath.Base(file), i, lines[i], msg)
+					}
+				}
+			}
+		})
+	}
+}
+
+// swapStdFile replaces *std with the file of name until the end of the test.
+func swapStdFile(t *testing.T, std **os.File, name string) string {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := *std
+	*std = f
+	t.Cleanup(func() {
+		*std = prev
+		_ = f.Close()
+	})
+	return name
+}
+
+func TestNewLevelFileHandler(t *testing.T) {
+	dir := t.TempDir()
+	h := NewLevelFileHandler(map[Level]Config{
+		LevelInfo:  {Filename: filepath.Join(dir, "app.log"), Format: "logfmt", Level: SLevelError},
+		LevelError: {Filename: filepath.Join(dir, "error.log"), Format: "json"},
+	})
+	l := NewLogger(h).With("a", 1)
+	l.Debug("debug")
+	l.DebugCtx(WithLevel(context.Background(), LevelDebug), "traced")
+	l.Info("info")
+	l.Error("error")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"app.log":   {"msg=info a=1", "msg=error a=1"},
+		"error.log": {`"msg":"error","a":1`},
+	}
+	for name, want := range tests {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("%s = %q, want %d records", name, b, len(want))
+		}
+		for i, s := range want {
+			if !strings.Contains(lines[i], s) {
+				t.Errorf("%s record %d = %q, want %s", name, i, lines[i], s)
+			}
+		}
+	}
+}
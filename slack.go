@@ -0,0 +1,302 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SlackOption configures the handler created by NewSlackHandler.
+type SlackOption func(a *slackAlerter)
+
+// WithSlackLevel sets the minimum level of the records to alert, LevelError by default.
+func WithSlackLevel(level Leveler) SlackOption {
+	return func(a *slackAlerter) {
+		a.level = level
+	}
+}
+
+// WithSlackAttrs sets the group-qualified keys of the attrs
+// written as the fields of the alerts, e.g. `http.method`.
+func WithSlackAttrs(keys ...string) SlackOption {
+	return func(a *slackAlerter) {
+		for _, key := range keys {
+			a.keys[key] = struct{}{}
+		}
+	}
+}
+
+// WithSlackInterval sets the minimum interval between two alerts of
+// the same message, 5m by default. The alert following the suppressed
+// ones has a `suppressed` field with their number.
+func WithSlackInterval(interval time.Duration) SlackOption {
+	return func(a *slackAlerter) {
+		a.interval = interval
+	}
+}
+
+// WithSlackTimeout sets how long Handle waits for an alert to be posted, 5s by default.
+func WithSlackTimeout(timeout time.Duration) SlackOption {
+	return func(a *slackAlerter) {
+		a.timeout = timeout
+	}
+}
+
+// WithSlackHTTPClient sets the client used to post, http.DefaultClient by default.
+func WithSlackHTTPClient(client *http.Client) SlackOption {
+	return func(a *slackAlerter) {
+		a.client = client
+	}
+}
+
+// NewSlackHandler creates a Handler that posts the records at or above
+// the level of WithSlackLevel to the Slack-compatible incoming webhook url,
+// as an attachment with the message and the attrs of WithSlackAttrs.
+// All records enabled by next are passed to it, next may be nil
+// to drop the records below the level.
+// The alerts are posted synchronously, the failed ones are returned by Handle.
+func NewSlackHandler(url string, next Handler, options ...SlackOption) Handler {
+	a := &slackAlerter{
+		url:      url,
+		client:   http.DefaultClient,
+		level:    LevelError,
+		keys:     make(map[string]struct{}),
+		interval: 5 * time.Minute,
+		timeout:  5 * time.Second,
+		lru:      list.New(),
+		index:    make(map[string]*list.Element),
+	}
+	for _, option := range options {
+		option(a)
+	}
+	return &slackHandler{next: next, alerter: a}
+}
+
+type slackHandler struct {
+	next    Handler
+	alerter *slackAlerter // shared among all clones of this handler
+
+	groups []string
+	fields []slackField // fields of WithAttrs
+}
+
+func (h *slackHandler) NeedsSource() bool {
+	return h.next != nil && needsSource(h.next)
+}
+
+func (h *slackHandler) Enabled(ctx context.Context, level Level) bool {
+	if level >= h.alerter.level.Level() {
+		return true
+	}
+	return h.next != nil && h.next.Enabled(ctx, level)
+}
+
+func (h *slackHandler) Handle(ctx context.Context, record Record) error {
+	var errs []error
+	if record.Level >= h.alerter.level.Level() {
+		fields := slices.Clip(h.fields)
+		record.Attrs(func(attr Attr) bool {
+			fields = h.alerter.appendFields(fields, h.groups, attr)
+			return true
+		})
+		errs = append(errs, h.alerter.alert(ctx, record, fields))
+	}
+	if h.next != nil && h.next.Enabled(ctx, record.Level) {
+		errs = append(errs, h.next.Handle(ctx, record))
+	}
+	return errors.Join(errs...)
+}
+
+func (h *slackHandler) WithAttrs(attrs []Attr) Handler {
+	cp := &slackHandler{
+		next:    h.next,
+		alerter: h.alerter,
+		groups:  h.groups,
+		fields:  slices.Clip(h.fields),
+	}
+	if h.next != nil {
+		cp.next = h.next.WithAttrs(attrs)
+	}
+	for _, attr := range attrs {
+		cp.fields = h.alerter.appendFields(cp.fields, h.groups, attr)
+	}
+	return cp
+}
+
+func (h *slackHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := &slackHandler{
+		next:    h.next,
+		alerter: h.alerter,
+		groups:  append(slices.Clip(h.groups), name),
+		fields:  h.fields,
+	}
+	if h.next != nil {
+		cp.next = h.next.WithGroup(name)
+	}
+	return cp
+}
+
+// slackField is a field of an attachment.
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackMaxMessages bounds the messages whose alerts are tracked,
+// the least recently alerted ones are forgotten first.
+var slackMaxMessages = 1024
+
+// slackMessage is the state of the alerts of a message.
+type slackMessage struct {
+	msg        string
+	last       time.Time
+	suppressed int
+}
+
+// slackAlerter posts the alerts, at most one per message per interval.
+type slackAlerter struct {
+	url      string
+	client   *http.Client
+	level    Leveler
+	keys     map[string]struct{} // keys of the fields
+	interval time.Duration
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	lru   *list.List // of *slackMessage, the most recently used first
+	index map[string]*list.Element
+}
+
+// appendFields appends the fields of a, and of the attrs of a group, whose key is selected.
+func (a *slackAlerter) appendFields(fields []slackField, groups []string, attr Attr) []slackField {
+	attr.Value = attr.Value.Resolve()
+	if attr.Value.Kind() == KindGroup {
+		g2 := groups
+		if attr.Key != "" {
+			g2 = append(slices.Clip(groups), attr.Key)
+		}
+		for _, ga := range attr.Value.Group() {
+			fields = a.appendFields(fields, g2, ga)
+		}
+		return fields
+	}
+
+	key := attr.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	if _, ok := a.keys[key]; !ok {
+		return fields
+	}
+	return append(fields, slackField{Title: key, Value: attr.Value.String(), Short: true})
+}
+
+// allow reports whether the message can be alerted now, and the number
+// of its alerts suppressed since the last one.
+func (a *slackAlerter) allow(msg string, now time.Time) (bool, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var m *slackMessage
+	if e, ok := a.index[msg]; ok {
+		a.lru.MoveToFront(e)
+		m = e.Value.(*slackMessage)
+		if now.Sub(m.last) < a.interval {
+			m.suppressed++
+			return false, 0
+		}
+	} else {
+		m = &slackMessage{msg: msg}
+		a.index[msg] = a.lru.PushFront(m)
+		if a.lru.Len() > slackMaxMessages {
+			oldest := a.lru.Back()
+			a.lru.Remove(oldest)
+			delete(a.index, oldest.Value.(*slackMessage).msg)
+		}
+	}
+	suppressed := m.suppressed
+	m.last = now
+	m.suppressed = 0
+	return true, suppressed
+}
+
+func (a *slackAlerter) alert(ctx context.Context, record Record, fields []slackField) error {
+	ok, suppressed := a.allow(record.Message, time.Now())
+	if !ok {
+		return nil
+	}
+	if suppressed > 0 {
+		fields = append(fields, slackField{Title: "suppressed", Value: strconv.Itoa(suppressed), Short: true})
+	}
+
+	color := "good"
+	switch {
+	case record.Level >= LevelError:
+		color = "danger"
+	case record.Level >= LevelWarn:
+		color = "warning"
+	}
+	attachment := map[string]any{
+		"color":    color,
+		"fallback": record.Level.String() + ": " + record.Message,
+		"title":    record.Level.String(),
+		"text":     record.Message,
+		"fields":   fields,
+	}
+	if !record.Time.IsZero() {
+		attachment["ts"] = record.Time.Unix()
+	}
+	body, err := json.Marshal(map[string]any{"attachments": []any{attachment}})
+	if err != nil {
+		return err
+	}
+
+	// the alert isn't canceled with the context of the log call,
+	// it is only bounded by the timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack alert failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("slack alert failed: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text"`
+	Fields []slackField `json:"fields"`
+}
+
+func TestSlackHandler(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		attachments []slackAttachment
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Attachments []slackAttachment `json:"attachments"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		attachments = append(attachments, body.Attachments...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	h := NewSlackHandler(srv.URL, NewLogHandler(&buf, nil, true),
+		WithSlackLevel(LevelWarn),
+		WithSlackAttrs("user", "http.status"),
+	)
+	l := NewLogger(h).With("user", "a")
+	l.Info("info")
+	for i := 0; i < 3; i++ {
+		l.WithGroup("http").Error("crash", "status", 500, "other", 1)
+	}
+	l.Warn("slow")
+
+	if got := strings.Count(buf.String(), "\n"); got != 5 {
+		t.Errorf("next handled %d records, want 5", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attachments) != 2 {
+		t.Fatalf("attachments = %+v, want 2", attachments)
+	}
+	crash := attachments[0]
+	if crash.Color != "danger" || crash.Title != "ERROR" || crash.Text != "crash" {
+		t.Errorf("attachment = %+v", crash)
+	}
+	want := []slackField{{"user", "a", true}, {"http.status", "500", true}}
+	if len(crash.Fields) != len(want) || crash.Fields[0] != want[0] || crash.Fields[1] != want[1] {
+		t.Errorf("fields = %+v, want %+v", crash.Fields, want)
+	}
+	if slow := attachments[1]; slow.Color != "warning" || slow.Text != "slow" {
+		t.Errorf("attachment = %+v", slow)
+	}
+}
+
+func TestSlackHandler_Standalone(t *testing.T) {
+	h := NewSlackHandler("http://127.0.0.1:0", nil)
+	if h.Enabled(context.Background(), LevelWarn) {
+		t.Error("Enabled(LevelWarn) = true, want false")
+	}
+	if !h.Enabled(context.Background(), LevelError) {
+		t.Error("Enabled(LevelError) = false, want true")
+	}
+}
+
+func TestSlackHandler_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	h := NewSlackHandler(srv.URL, nil, WithSlackTimeout(50*time.Millisecond), WithSlackInterval(0))
+	start := time.Now()
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), LevelError, "crash", 0))
+	if err == nil {
+		t.Error("error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Handle() blocked for %v", elapsed)
+	}
+}
+
+func TestSlackAlerter_allow(t *testing.T) {
+	defer func(n int) { slackMaxMessages = n }(slackMaxMessages)
+	slackMaxMessages = 2
+
+	a := NewSlackHandler("http://127.0.0.1:0", nil).(*slackHandler).alerter
+	now := time.Now()
+	for _, msg := range []string{"a", "b", "a", "c"} {
+		a.allow(msg, now)
+	}
+	if a.lru.Len() != 2 || len(a.index) != 2 {
+		t.Fatalf("tracked %d messages, want 2", a.lru.Len())
+	}
+	// b was the least recently used, it is forgotten and alerted again
+	if ok, _ := a.allow("b", now); !ok {
+		t.Error("allow(b) = false, want the evicted message alerted")
+	}
+	if ok, _ := a.allow("c", now); ok {
+		t.Error("allow(c) = true, want it suppressed within the interval")
+	}
+	if ok, suppressed := a.allow("c", now.Add(5*time.Minute)); !ok || suppressed != 1 {
+		t.Errorf("allow(c) = %v, %d, want true, 1", ok, suppressed)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SourceFormat decides how the source of a log call is rendered.
+type SourceFormat string
+
+const (
+	// SourceFull renders the absolute file path and line, which is the default.
+	SourceFull SourceFormat = "full"
+	// SourceShort renders the file base name and line.
+	SourceShort SourceFormat = "short"
+	// SourceRelative renders the file path relative to the module root and line.
+	SourceRelative SourceFormat = "relative"
+	// SourceFunction renders the package-qualified function name and line.
+	SourceFunction SourceFormat = "function"
+)
+
+// Format renders src according to f.
+func (f SourceFormat) Format(src *slog.Source) string {
+	var s string
+	switch SourceFormat(strings.ToLower(string(f))) {
+	case SourceShort:
+		s = filepath.Base(src.File)
+	case SourceRelative:
+		s = trimSourcePrefix(src.File)
+	case SourceFunction:
+		s = src.Function
+		if index := strings.LastIndexByte(s, '/'); index != -1 {
+			s = s[index+1:]
+		}
+	default:
+		s = src.File
+	}
+	return s + ":" + strconv.Itoa(src.Line)
+}
+
+// sourcePrefixes returns the path prefixes trimmed by SourceRelative,
+// derived once from the build info, GOROOT and the working directory.
+var sourcePrefixes = sync.OnceValue(func() []string {
+	var prefixes []string
+	// builds with -trimpath report the main module files under the module path
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Path != "" {
+		prefixes = append(prefixes, info.Main.Path+"/")
+	}
+	if goroot := runtime.GOROOT(); goroot != "" {
+		prefixes = append(prefixes, filepath.ToSlash(goroot)+"/src/")
+	}
+	if wd, err := os.Getwd(); err == nil {
+		prefixes = append(prefixes, filepath.ToSlash(wd)+"/")
+	}
+	return prefixes
+})
+
+func trimSourcePrefix(file string) string {
+	for _, prefix := range sourcePrefixes() {
+		if strings.HasPrefix(file, prefix) {
+			return file[len(prefix):]
+		}
+	}
+	// dependencies in the module cache
+	if index := strings.Index(file, "/pkg/mod/"); index != -1 {
+		return file[index+len("/pkg/mod/"):]
+	}
+	return file
+}
+
+// sourceReplaceAttr returns a ReplaceAttr func which calls next
+// and then renders the source attribute according to format.
+func sourceReplaceAttr(format SourceFormat, next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		if next != nil {
+			a = next(groups, a)
+		}
+		if len(groups) == 0 && a.Key == SourceKey {
+			if src, ok := a.Value.Any().(*slog.Source); ok {
+				a.Value = slog.StringValue(format.Format(src))
+			}
+		}
+		return a
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SyslogSDID is the SD-ID of the structured data element holding
+// the attributes of a record, 32473 is the example enterprise number
+// reserved for documentation by RFC 5612.
+const SyslogSDID = "wslog@32473"
+
+// syslogTimeLayout is the RFC 5424 timestamp with microseconds.
+const syslogTimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// syslogSockets are the local syslog sockets tried in order
+// when NewSyslogHandler is called with an empty network.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogOption configures the handler created by NewSyslogHandler.
+type SyslogOption func(h *syslogHandler)
+
+// WithSyslogAppName sets the APP-NAME of the messages,
+// the base name of the executable by default.
+func WithSyslogAppName(name string) SyslogOption {
+	return func(h *syslogHandler) {
+		h.appName = name
+	}
+}
+
+// NewSyslogHandler creates a Handler that sends RFC 5424 messages to the
+// syslog server at addr, the network is one of udp, tcp, unix or unixgram.
+// An empty network connects to the local syslog socket.
+// The facility is a name like daemon or local0, user by default.
+// The attributes are sent as the params of the SyslogSDID structured data
+// element, and the level as the severity, see syslogSeverity.
+// A failed write reconnects with an exponential backoff, and the
+// message is retried once. Close the returned handler to close the connection.
+func NewSyslogHandler(network, addr, facility string, opts *HandlerOptions, options ...SyslogOption) (Handler, error) {
+	if facility == "" {
+		facility = "user"
+	}
+	code, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	conn := &netConn{name: "syslog", dial: syslogDial(network, addr)}
+	if err := conn.connect(); err != nil {
+		return nil, err
+	}
+	h := &syslogHandler{
+		conn:     conn,
+		opts:     *opts,
+		facility: code,
+		hostname: hostname,
+		appName:  filepath.Base(os.Args[0]),
+		procID:   strconv.Itoa(os.Getpid()),
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h, nil
+}
+
+type syslogHandler struct {
+	conn     *netConn // shared among all clones of this handler
+	opts     HandlerOptions
+	facility int
+	hostname string
+	appName  string
+	procID   string
+
+	groups []string
+	params []byte // preformatted params of WithAttrs
+}
+
+// Close implements io.Closer, and closes the syslog connection.
+func (h *syslogHandler) Close() error {
+	return h.conn.close()
+}
+
+func (h *syslogHandler) clone() *syslogHandler {
+	return &syslogHandler{
+		conn:     h.conn,
+		opts:     h.opts,
+		facility: h.facility,
+		hostname: h.hostname,
+		appName:  h.appName,
+		procID:   h.procID,
+		groups:   slices.Clip(h.groups),
+		params:   slices.Clip(h.params),
+	}
+}
+
+func (h *syslogHandler) NeedsSource() bool {
+	return false
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level Level) bool {
+	return levelEnabled(ctx, level, h.opts.Level)
+}
+
+func (h *syslogHandler) Handle(_ context.Context, record Record) error {
+	var params bytes.Buffer
+	params.Write(h.params)
+	record.Attrs(func(attr Attr) bool {
+		h.appendParam(&params, h.groups, attr)
+		return true
+	})
+
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+	var buf bytes.Buffer
+	buf.WriteByte('<')
+	buf.WriteString(strconv.Itoa(h.facility*8 + syslogSeverity(record.Level)))
+	buf.WriteString(">1 ")
+	if record.Time.IsZero() {
+		buf.WriteString("-")
+	} else {
+		buf.WriteString(record.Time.Format(syslogTimeLayout))
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(h.hostname, 255))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(h.appName, 48))
+	buf.WriteByte(' ')
+	buf.WriteString(syslogHeaderField(h.procID, 128))
+	buf.WriteString(" - ")
+	if params.Len() == 0 {
+		buf.WriteString("-")
+	} else {
+		buf.WriteString("[" + SyslogSDID)
+		buf.Write(params.Bytes())
+		buf.WriteString("]")
+	}
+	if record.Message != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(record.Message)
+	}
+	return h.conn.write(func(conn net.Conn) error {
+		return writeSyslogMessage(conn, buf.Bytes())
+	})
+}
+
+func (h *syslogHandler) WithAttrs(attrs []Attr) Handler {
+	cp := h.clone()
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		cp.appendParam(&buf, cp.groups, attr)
+	}
+	cp.params = append(cp.params, buf.Bytes()...)
+	return cp
+}
+
+func (h *syslogHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	cp := h.clone()
+	cp.groups = append(cp.groups, name)
+	return cp
+}
+
+// appendParam appends a as the ` name="value"` params of the structured data.
+func (h *syslogHandler) appendParam(buf *bytes.Buffer, groups []string, a Attr) {
+	if raFn := h.opts.ReplaceAttr; raFn != nil && a.Value.Kind() != KindGroup {
+		a.Value = a.Value.Resolve()
+		a = raFn(groups, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Key == "" && a.Value.Kind() != KindGroup {
+		return
+	}
+
+	if a.Value.Kind() == KindGroup {
+		g2 := groups
+		if a.Key != "" {
+			g2 = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendParam(buf, g2, ga)
+		}
+		return
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(syslogParamName(key))
+	buf.WriteString(`="`)
+	syslogParamValueReplacer.WriteString(buf, a.Value.String())
+	buf.WriteByte('"')
+}
+
+var syslogParamValueReplacer = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// syslogParamName converts key to a valid SD-NAME, which consists of
+// at most 32 printable ASCII characters other than '=', ' ', ']' and '"'.
+func syslogParamName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return string(name)
+}
+
+// syslogHeaderField returns s as a header field of at most n
+// printable ASCII characters, or the nil value "-" if s is empty.
+func syslogHeaderField(s string, n int) string {
+	field := []byte(s)
+	for i, c := range field {
+		if c <= ' ' || c >= 0x7f {
+			field[i] = '_'
+		}
+	}
+	if len(field) > n {
+		field = field[:n]
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
+
+// syslogDial returns a dial func for the syslog server, which connects
+// to the first available local syslog socket if the network is empty.
+func syslogDial(network, addr string) func() (net.Conn, error) {
+	if network != "" {
+		return func() (net.Conn, error) {
+			return net.Dial(network, addr)
+		}
+	}
+	return func() (net.Conn, error) {
+		var errs []error
+		for _, socket := range syslogSockets {
+			for _, network := range []string{"unixgram", "unix"} {
+				conn, err := net.Dial(network, socket)
+				if err == nil {
+					return conn, nil
+				}
+				errs = append(errs, err)
+			}
+		}
+		return nil, errors.Join(errs...)
+	}
+}
+
+// writeSyslogMessage writes msg to conn, with octet-counting framing
+// (RFC 6587) for stream connections.
+func writeSyslogMessage(conn net.Conn, msg []byte) error {
+	if isStreamConn(conn) {
+		framed := strconv.AppendInt(make([]byte, 0, len(msg)+8), int64(len(msg)), 10)
+		framed = append(framed, ' ')
+		msg = append(framed, msg...)
+	}
+	_, err := conn.Write(msg)
+	return err
+}
+
+// syslogSeverity maps level to a syslog severity, levels above
+// LevelError map to critical and levels between LevelInfo and LevelWarn
+// to notice.
+func syslogSeverity(level Level) int {
+	switch {
+	case level > LevelError:
+		return 2 // crit
+	case level == LevelError:
+		return 3 // err
+	case level >= LevelWarn:
+		return 4 // warning
+	case level > LevelInfo:
+		return 5 // notice
+	case level == LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
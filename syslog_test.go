@@ -0,0 +1,59 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestSyslogHandler(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer pc.Close()
+
+	h, err := NewSyslogHandler("udp", pc.LocalAddr().String(), "local0", nil, WithSyslogAppName("app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.(io.Closer).Close()
+
+	l := NewLogger(h)
+	l.WithGroup("g").With("a", `x"]`).Log(LevelError+4, "hello", "b", 1)
+
+	buf := make([]byte, 1024)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local0 * 8 + crit
+	want := `^<130>1 \S+ \S+ app ` + strconv.Itoa(os.Getpid()) +
+		` - \[` + regexp.QuoteMeta(SyslogSDID) + ` g\.a="x\\"\\]" g\.b="1"\] hello$`
+	if got := string(buf[:n]); !regexp.MustCompile(want).MatchString(got) {
+		t.Errorf("Handle() = %q, want match %q", got, want)
+	}
+}
+
+func TestSyslogHandler_UnknownFacility(t *testing.T) {
+	if _, err := NewSyslogHandler("udp", "127.0.0.1:514", "unknown", nil); err == nil {
+		t.Error("NewSyslogHandler() error = nil, want unknown facility")
+	}
+}
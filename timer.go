@@ -0,0 +1,73 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// ElapsedKey is the key used for the elapsed time of Logger.WithTimer.
+const ElapsedKey = "elapsed"
+
+// logTimer is a LogValuer of the time elapsed since it was started,
+// shared by the Loggers derived from the one of WithTimer.
+type logTimer struct {
+	start atomic.Pointer[time.Time]
+}
+
+func newLogTimer() *logTimer {
+	t := new(logTimer)
+	t.reset()
+	return t
+}
+
+// reset restarts t, and returns the time elapsed until now.
+func (t *logTimer) reset() time.Duration {
+	now := time.Now()
+	if start := t.start.Swap(&now); start != nil {
+		return now.Sub(*start)
+	}
+	return 0
+}
+
+// LogValue implements LogValuer, the elapsed time is measured when the record is handled.
+func (t *logTimer) LogValue() Value {
+	return slog.DurationValue(time.Since(*t.start.Load()))
+}
+
+// WithTimer returns a Logger that adds an attribute with the key ElapsedKey
+// and the time elapsed since the call to WithTimer, or the last Lap,
+// to each record.
+func (l *Logger) WithTimer() *Logger {
+	c := l.clone()
+	c.timer = newLogTimer()
+	return c
+}
+
+// Lap logs msg at LevelInfo with the time elapsed since the call to
+// WithTimer or the last Lap, and restarts the timer. If l has no timer,
+// it logs msg only.
+func (l *Logger) Lap(msg string) {
+	if l.timer == nil {
+		l.log(emptyCtx, LevelInfo, msg)
+		return
+	}
+	elapsed := l.timer.reset()
+	c := l.clone()
+	c.timer = nil
+	c.log(emptyCtx, LevelInfo, msg, slog.Duration(ElapsedKey, elapsed))
+}
@@ -0,0 +1,140 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+)
+
+// TruncatedKey is the key of the group appended to a record
+// whose attrs exceed the MaxAttrs limit.
+const TruncatedKey = "!TRUNCATED"
+
+// truncateString shortens s to at most max runes,
+// appending a marker with the number of dropped bytes.
+func truncateString(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	count := 0
+	for index := range s {
+		if count == max {
+			return s[:index] + "…(truncated " + strconv.Itoa(len(s)-index) + " bytes)"
+		}
+		count++
+	}
+	return s
+}
+
+// truncateAttrs keeps the first max attrs, replacing the rest
+// with a TruncatedKey group that records how many were dropped.
+func truncateAttrs(attrs []Attr, max int) []Attr {
+	if max <= 0 || len(attrs) <= max {
+		return attrs
+	}
+	dropped := len(attrs) - max
+	return append(attrs[:max:max], slog.Group(TruncatedKey, slog.Int("dropped", dropped)))
+}
+
+// truncateReplaceAttr returns a ReplaceAttr func which calls next
+// and then truncates string values longer than max runes, except the
+// builtin attrs, e.g. the source. The other values of KindAny, e.g.
+// a []byte or an error, are truncated in their string form, and are
+// then rendered as strings. The JSON handlers pass the attrs of the
+// source group without groups, so with jsonSource the source is turned
+// into that group here, of values which are not truncated.
+func truncateReplaceAttr(max int, jsonSource bool, next func(groups []string, a Attr) Attr) func(groups []string, a Attr) Attr {
+	return func(groups []string, a Attr) Attr {
+		if next != nil {
+			a = next(groups, a)
+		}
+		if len(groups) == 0 {
+			switch a.Key {
+			case SourceKey:
+				if src, ok := a.Value.Any().(*slog.Source); ok && jsonSource && src.File != "" {
+					a.Value = slog.GroupValue(
+						slog.Any("function", sourceValue(src.Function)),
+						slog.Any("file", sourceValue(src.File)),
+						slog.Int("line", src.Line),
+					)
+				}
+				return a
+			case LevelKey, TimeKey, MessageKey:
+				return a
+			}
+		}
+		switch a.Value.Kind() {
+		case KindString:
+			a.Value = slog.StringValue(truncateString(a.Value.String(), max))
+		case KindAny:
+			if _, ok := a.Value.Any().(sourceValue); ok {
+				break
+			}
+			if str := a.Value.String(); max > 0 && len(str) > max {
+				if truncated := truncateString(str, max); truncated != str {
+					a.Value = slog.StringValue(truncated)
+				}
+			}
+		}
+		return a
+	}
+}
+
+// sourceValue is a string of the source, which is not truncated.
+type sourceValue string
+
+// NewMaxAttrsHandler returns a Handler that passes at most max attrs
+// of each record to h, replacing the rest with a TruncatedKey group.
+// Attrs added by WithAttrs are not counted.
+func NewMaxAttrsHandler(h Handler, max int) Handler {
+	return &maxAttrsHandler{handler: h, max: max}
+}
+
+type maxAttrsHandler struct {
+	handler Handler
+	max     int
+}
+
+func (h *maxAttrsHandler) NeedsSource() bool {
+	return needsSource(h.handler)
+}
+
+func (h *maxAttrsHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *maxAttrsHandler) Handle(ctx context.Context, record Record) error {
+	if h.max <= 0 || record.NumAttrs() <= h.max {
+		return h.handler.Handle(ctx, record)
+	}
+	attrs := make([]Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	r := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	r.AddAttrs(truncateAttrs(attrs, h.max)...)
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *maxAttrsHandler) WithAttrs(attrs []Attr) Handler {
+	return &maxAttrsHandler{handler: h.handler.WithAttrs(attrs), max: h.max}
+}
+
+func (h *maxAttrsHandler) WithGroup(name string) Handler {
+	return &maxAttrsHandler{handler: h.handler.WithGroup(name), max: h.max}
+}
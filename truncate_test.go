@@ -0,0 +1,104 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTruncateString(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"abcdef", 3, "abc…(truncated 3 bytes)"},
+		{"abc", 3, "abc"},
+		{"abc", 0, "abc"},
+		{"日本語テキスト", 3, "日本語…(truncated 12 bytes)"},
+		{"日本語", 3, "日本語"}, // 9 bytes but 3 runes
+		{"a😀b", 1, "a…(truncated 5 bytes)"},
+		{"a😀b", 2, "a😀…(truncated 1 bytes)"},
+	}
+	for _, tt := range tests {
+		if got := truncateString(tt.s, tt.max); got != tt.want {
+			t.Errorf("truncateString(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestConfig_MaxValueLength(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "json", MaxValueLength: 4}, &buf)
+	l.Info("a long message", "s", "日本語テキスト", "short", "日本", "err", errors.New("a long error"), "n", 123456789)
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	want := map[string]any{
+		MessageKey: "a long message",
+		"s":        "日本語テ…(truncated 9 bytes)",
+		"short":    "日本",
+		"err":      "a lo…(truncated 8 bytes)",
+		"n":        float64(123456789),
+	}
+	for key, value := range want {
+		if m[key] != value {
+			t.Errorf("%s = %v, want %v", key, m[key], value)
+		}
+	}
+}
+
+func TestConfig_MaxValueLength_Source(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "json", MaxValueLength: 4, Source: true}, &buf)
+	l.Info("msg")
+
+	var m map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	source, ok := m[SourceKey].(map[string]any)
+	if !ok || !strings.HasSuffix(source["file"].(string), "truncate_test.go") || source["line"] == nil {
+		t.Errorf("%s = %v, want the source untruncated", SourceKey, m[SourceKey])
+	}
+
+	buf.Reset()
+	l = New(Config{Format: "text", MaxValueLength: 4, Source: true}, &buf)
+	l.Info("msg", "file", "a long file")
+	if got := buf.String(); !strings.Contains(got, "truncate_test.go:") || !strings.Contains(got, `file="a lo…`) {
+		t.Errorf("output = %q, want only the source untruncated", got)
+	}
+}
+
+func TestConfig_MaxAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(Config{Format: "logfmt", MaxAttrs: 2}, &buf)
+	l.With("w", 0).Info("msg", "a", 1, "b", 2, "c", 3, "d", 4)
+	if got := buf.String(); !strings.HasSuffix(got, " w=0 a=1 b=2 "+TruncatedKey+".dropped=2\n") {
+		t.Errorf("output = %q, want the dropped attrs counted", got)
+	}
+
+	buf.Reset()
+	l.Info("msg", "a", 1, "b", 2)
+	if got := buf.String(); strings.Contains(got, TruncatedKey) {
+		t.Errorf("output = %q, want no marker at the limit", got)
+	}
+}
@@ -15,12 +15,12 @@
 package wslog
 
 import (
-	"bytes"
 	"log/slog"
 )
 
 type (
 	Attr           = slog.Attr
+	Value          = slog.Value
 	Record         = slog.Record
 	Handler        = slog.Handler
 	HandlerOptions = slog.HandlerOptions
@@ -33,6 +33,8 @@ type (
 )
 
 const (
+	// LevelTrace is below LevelDebug, for the very verbose records.
+	LevelTrace = slog.LevelDebug - 4
 	LevelDebug = slog.LevelDebug
 	LevelInfo  = slog.LevelInfo
 	LevelWarn  = slog.LevelWarn
@@ -71,6 +73,32 @@ const (
 
 const BadKey = "!BADKEY"
 
+// BadValue is written by the default log handler instead of the values of
+// the LogValuers that don't resolve, e.g. because they resolve to themselves.
+const BadValue = "!BADVALUE"
+
+// HostnameKey and PIDKey are the keys used for the hostname and the
+// process ID of Config.AddHostname and Config.AddPID.
+const (
+	HostnameKey = "host"
+	PIDKey      = "pid"
+)
+
+// LoggerKey is the key used for the name of a Logger, see Logger.Named.
+const LoggerKey = "logger"
+
+// ContextErrorKey is the key used for the error of a canceled context,
+// see Logger.WithContextError.
+const ContextErrorKey = "ctx_err"
+
+// StackKey is the key used for the stack trace of a recovered panic,
+// see Logger.Recover.
+const StackKey = "stack"
+
+// ErrorKey is the key used by WithError and ErrorErr for the error attribute.
+// It should be set before any logging, e.g. in an init function.
+var ErrorKey = "error"
+
 func argsToAttrSlice(args []any) []Attr {
 	var (
 		attr  Attr
@@ -115,75 +143,67 @@ func needsQuoting(s string) bool {
 }
 
 const (
-	quoteChar  = 34
-	splitChar  = 61
-	sepChar    = 32
-	escapeChar = 92
+	quoteChar  = '"'
+	splitChar  = '='
+	sepChar    = ' '
+	escapeChar = '\\'
 )
 
-var quoteSuffix = []byte{quoteChar, sepChar}
-
+// convertToColorKey wraps every key of the logfmt-style attrs in b with
+// colorPrefix and colorSuffix. All other bytes are copied through unchanged,
+// so stripping the color sequences from the result always yields b.
+// A raw newline separates tokens like a space, even in an unterminated
+// quoted value, so that one malformed value can't swallow the next lines.
 func convertToColorKey(b []byte, colorPrefix, colorSuffix []byte) []byte {
-	bl := len(b)
-	if bl == 0 {
+	if len(b) == 0 {
 		return b
 	}
 
-	var buf bytes.Buffer
-	for {
-		index := bytes.IndexByte(b, splitChar)
-		if index == -1 {
-			buf.Write(b)
-			break
+	buf := make([]byte, 0, len(b)+8*(len(colorPrefix)+len(colorSuffix)))
+	for i := 0; i < len(b); {
+		// copy separators between tokens
+		if b[i] == sepChar || b[i] == '\n' {
+			buf = append(buf, b[i])
+			i++
+			continue
 		}
 
-		key := b[:index]
-		buf.Write(colorPrefix)
-		buf.Write(key)
-		buf.Write(colorSuffix)
-		buf.WriteByte(splitChar)
-
-		val := b[index+1:]
-		index = bytes.IndexByte(val, quoteChar)
-		// match quote prefix
-		if index == 0 {
-			buf.WriteByte(quoteChar)
-			val = val[1:]
-
-			// 循环查找 结束符，如果找到转义的结束符，继续查找
-			var eof bool
-			for {
-				index = bytes.Index(val, quoteSuffix)
-				// break when the quote suffix is not matched
-				if index == -1 {
-					buf.Write(val)
-					eof = true
-					break
-				}
+		// scan the key up to the first split char
+		start := i
+		for i < len(b) && b[i] != splitChar && b[i] != sepChar && b[i] != '\n' && b[i] != quoteChar {
+			i++
+		}
+		if i < len(b) && b[i] == splitChar && i > start {
+			buf = append(buf, colorPrefix...)
+			buf = append(buf, b[start:i]...)
+			buf = append(buf, colorSuffix...)
+			// an inline group `key={k=v}` continues with the keys of the group
+			if i+1 < len(b) && b[i+1] == '{' {
+				buf = append(buf, splitChar, '{')
+				i += 2
+				continue
+			}
+		} else {
+			buf = append(buf, b[start:i]...)
+		}
 
-				buf.Write(val[:index])
-				buf.Write(quoteSuffix)
-				if index > 0 && val[index-1] != escapeChar {
-					b = val[index+2:]
-					break
+		// scan the value up to the next separator outside of quotes
+		start = i
+		for i < len(b) && b[i] != sepChar && b[i] != '\n' {
+			if b[i] != quoteChar {
+				i++
+				continue
+			}
+			for i++; i < len(b) && b[i] != quoteChar && b[i] != '\n'; i++ {
+				if b[i] == escapeChar && i+1 < len(b) && b[i+1] != '\n' {
+					i++
 				}
-				val = val[index+2:]
 			}
-			if eof {
-				break
+			if i < len(b) && b[i] == quoteChar {
+				i++
 			}
-			continue
-		}
-
-		index = bytes.IndexByte(val, sepChar)
-		// break when the sep is not matched
-		if index == -1 {
-			buf.Write(val)
-			break
 		}
-		buf.Write(val[:index])
-		buf.WriteByte(sepChar)
-		b = val[index+1:]
+		buf = append(buf, b[start:i]...)
 	}
-	return buf.Bytes()
+	return buf
 }
@@ -3,7 +3,9 @@
 package wslog
 
 import (
+	"bytes"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -25,6 +27,50 @@ func Test_convertToColorKey(t *testing.T) {
 			},
 			want: []byte(prefix + `a` + suffix + `=1 ` + prefix + `b` + suffix + `="1 2" ` + prefix + `c` + suffix + `=1=2 ` + prefix + `d` + suffix + `="1\n\"2"`),
 		},
+		{
+			name: "quoted value containing split char",
+			args: args{
+				b: []byte(` q="a=b c" next=1`),
+			},
+			want: []byte(` ` + prefix + `q` + suffix + `="a=b c" ` + prefix + `next` + suffix + `=1`),
+		},
+		{
+			name: "quote after value prefix",
+			args: args{
+				b: []byte(`key=prefix"a b" next=1`),
+			},
+			want: []byte(prefix + `key` + suffix + `=prefix"a b" ` + prefix + `next` + suffix + `=1`),
+		},
+		{
+			name: "unterminated quote",
+			args: args{
+				b: []byte(`a="1 2\"`),
+			},
+			want: []byte(prefix + `a` + suffix + `="1 2\"`),
+		},
+		{
+			name: "multi-byte value",
+			args: args{
+				b: []byte(`a="你好\"世界" b=é`),
+			},
+			want: []byte(prefix + `a` + suffix + `="你好\"世界" ` + prefix + `b` + suffix + `=é`),
+		},
+		{
+			name: "inline group",
+			args: args{
+				b: []byte(` g={a=1 h={b="x y"}} c=2`),
+			},
+			want: []byte(` ` + prefix + `g` + suffix + `={` + prefix + `a` + suffix + `=1 ` + prefix + `h` + suffix +
+				`={` + prefix + `b` + suffix + `="x y"}} ` + prefix + `c` + suffix + `=2`),
+		},
+		{
+			name: "raw newlines",
+			args: args{
+				b: []byte("a=1\nb=\"x\ny=2\\\nc=3"),
+			},
+			want: []byte(prefix + `a` + suffix + "=1\n" + prefix + `b` + suffix + "=\"x\n" + prefix + `y` + suffix +
+				"=2\\\n" + prefix + `c` + suffix + `=3`),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -34,3 +80,22 @@ func Test_convertToColorKey(t *testing.T) {
 		})
 	}
 }
+
+var ansiRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func Fuzz_convertToColorKey(f *testing.F) {
+	f.Add([]byte(`a=1 b="1 2" c=1=2 d="1\n\"2"`))
+	f.Add([]byte(` q="a=b" next=1`))
+	f.Add([]byte(`key=prefix"a b" next=1`))
+	f.Add([]byte(`a="\`))
+	f.Add([]byte("a=\"1\nb=2"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if bytes.IndexByte(b, 0x1b) != -1 {
+			t.Skip()
+		}
+		got := convertToColorKey(b, []byte("\x1b[31m"), []byte("\x1b[0m"))
+		if stripped := ansiRegexp.ReplaceAll(got, nil); !bytes.Equal(stripped, b) {
+			t.Errorf("convertToColorKey() stripped = %q, want %q", stripped, b)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// configFormats are the Formats of a Config, an empty Format is the default colored format.
+var configFormats = []string{"json", "json-pretty", "text", "logfmt", "proto", "cef", "journald"}
+
+// NewE is like New, but returns the errors of Config.Validate and of the
+// unknown opts instead of replacing the invalid fields with their defaults.
+func NewE(cfg Config, opts ...any) (*Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	l, err := newLogger(cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Validate reports the invalid fields of the Config, joined with errors.Join.
+// New replaces them with their defaults, and reports the ones it uses to
+// the func set by SetErrorHandler, see NewE to fail instead.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	oneOf := func(field, value string, values ...string) {
+		if value != "" && !containsFold(values, value) {
+			invalid("unknown %s %q, one of %s", field, value, strings.Join(values, ", "))
+		}
+	}
+
+	oneOf("format", c.Format, configFormats...)
+	oneOf("consoleFormat", c.ConsoleFormat, configFormats...)
+	oneOf("sourceFormat", string(c.SourceFormat),
+		string(SourceFull), string(SourceShort), string(SourceRelative), string(SourceFunction))
+	oneOf("multiline", string(c.Multiline), string(MultilineEscape), string(MultilineBlock))
+	oneOf("duplicateKeys", c.DuplicateKeys, "keep-all", "keep-last", "keep-first", "error")
+	oneOf("preset", c.Preset, PresetECS)
+
+	levels := []SLevel{c.Level, c.SyncLevel, c.SplitLevel}
+	for level := range c.LevelFiles {
+		levels = append(levels, level)
+	}
+	slices.Sort(levels[3:])
+	for _, level := range levels {
+		if level != "" {
+			if _, err := ParseLevelStrict(level); err != nil {
+				invalid("%w, register it with RegisterLevel", err)
+			}
+		}
+	}
+
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"maxSize", c.MaxSize},
+		{"maxAge", c.MaxAge},
+		{"maxBackups", c.MaxBackups},
+		{"maxValueLength", c.MaxValueLength},
+		{"maxAttrs", c.MaxAttrs},
+	} {
+		if f.value < 0 {
+			invalid("negative %s %d, use 0 for the default", f.name, f.value)
+		}
+	}
+
+	hasFile := c.Filename != ""
+	if c.Output != "" {
+		out, err := parseOutput(c.Output)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		hasFile = out.filename != ""
+	}
+	if !hasFile {
+		if c.MaxSize > 0 || c.MaxAge > 0 || c.MaxBackups > 0 || c.Compress || c.RotateInterval != "" {
+			invalid("maxSize, maxAge, maxBackups, compress and rotateInterval require a filename")
+		}
+		if c.SyncEveryWrite || c.SyncLevel != "" {
+			invalid("syncEveryWrite and syncLevel require a filename")
+		}
+	}
+	if c.SplitStdStreams && (c.Filename != "" || c.Output != "") {
+		invalid("splitStdStreams requires an empty filename and output")
+	}
+	if _, err := c.parseTimeLocation(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := c.parseRotateInterval(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
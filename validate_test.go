@@ -0,0 +1,79 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	RegisterLevel("audit", LevelInfo+2)
+
+	valid := []Config{
+		{},
+		{Level: "audit", Format: "JSON", Output: "stderr", TimeZone: "UTC"},
+		{Level: "warn-2", Filename: "app.log", MaxSize: 10, Compress: true, RotateInterval: "daily", SyncEveryWrite: true},
+		{Output: "file:///var/log/app.log", MaxBackups: 3, LevelFiles: map[SLevel]string{"error": "app.err.log"}},
+		{SplitStdStreams: true, SplitLevel: "error", AlsoStdout: true, ConsoleFormat: "logfmt"},
+	}
+	for _, cfg := range valid {
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%+v: Validate() = %v, want nil", cfg, err)
+		}
+	}
+
+	invalid := Config{
+		Level:          "verbose",
+		Format:         "yaml",
+		SourceFormat:   "long",
+		DuplicateKeys:  "merge",
+		MaxSize:        -1,
+		Compress:       true,
+		Output:         "http://host",
+		TimeZone:       "Nowhere/Invalid",
+		RotateInterval: "weekly",
+		SyncEveryWrite: true,
+		LevelFiles:     map[SLevel]string{"nope+1": "app.nope.log"},
+	}
+	err := invalid.Validate()
+	if err == nil {
+		t.Fatal("Validate() = nil, want an error")
+	}
+	for _, want := range []string{
+		`unknown level "verbose", register it with RegisterLevel`,
+		`unknown format "yaml", one of json, json-pretty, text, logfmt, proto, cef, journald`,
+		`unknown sourceFormat "long"`,
+		`unknown duplicateKeys "merge"`,
+		`unknown level "nope"`,
+		"negative maxSize -1",
+		"compress and rotateInterval require a filename",
+		"invalid output",
+		"invalid time zone",
+		"invalid rotate interval",
+		"syncEveryWrite and syncLevel require a filename",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want %q", err, want)
+		}
+	}
+
+	if _, err := NewE(invalid); err == nil {
+		t.Error("NewE() = nil error, want the errors of Validate")
+	}
+	if l, err := NewE(Config{Format: "logfmt"}); err != nil || l == nil {
+		t.Errorf("NewE() = %v, %v, want a Logger", l, err)
+	}
+}
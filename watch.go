@@ -0,0 +1,361 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	configDecodersMu sync.Mutex
+	configDecoders   = map[string]func(data []byte, cfg *Config) error{
+		".json": func(data []byte, cfg *Config) error {
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			return dec.Decode(cfg)
+		},
+	}
+)
+
+// RegisterConfigDecoder registers the decoder of the config files with
+// the extension ext for Watch, e.g. for YAML:
+//
+//	wslog.RegisterConfigDecoder(".yaml", func(data []byte, cfg *wslog.Config) error {
+//		return yaml.Unmarshal(data, cfg)
+//	})
+//
+// The decoder overrides the fields of cfg present in data. JSON is supported by default.
+func RegisterConfigDecoder(ext string, decode func(data []byte, cfg *Config) error) {
+	configDecodersMu.Lock()
+	configDecoders[strings.ToLower(ext)] = decode
+	configDecodersMu.Unlock()
+}
+
+// watchInterval is how often Watch checks the config file for changes.
+var watchInterval = time.Second
+
+// Watch creates a Logger from the config file at path, decoded over base,
+// and polls the file for changes to apply them to the Logger and the
+// Loggers derived from it. A change of the Level is applied in place, the
+// other changes replace the handler, keeping the attrs and groups of the
+// derived Loggers. The files of the replaced config are closed once the
+// records in flight are written. An invalid config is passed to onErr,
+// if not nil, and the previous one stays active.
+// JSON is decoded by default, YAML and the other formats need a decoder
+// registered by RegisterConfigDecoder for the extension of path.
+// Reopen and Sync of the Logger apply to the files of the current config.
+// stop, like Close of the Logger, stops watching and closes the files of
+// the current config, the records logged afterwards are dropped.
+func Watch(path string, base Config, onErr func(err error)) (l *Logger, stop func(), err error) {
+	if onErr == nil {
+		onErr = func(error) {}
+	}
+	w := &configWatcher{path: path, base: base, level: new(LevelVar), onErr: onErr, done: make(chan struct{})}
+	cfg, info, err := w.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := NewE(cfg, w.level)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.level.Set(cfg.Level.Level())
+	w.cfg, w.info, w.current = cfg, info, current
+	w.handler = &swapRoot{}
+	w.handler.store(current.Handler(), w.closer(current))
+
+	l = NewLogger(&swapHandler{root: w.handler})
+	l.level = w.level
+	l.goid = cfg.GoroutineID
+	l.closers = []io.Closer{w}
+
+	w.wg.Add(1)
+	go w.run()
+	return l, w.stop, nil
+}
+
+type configWatcher struct {
+	path  string
+	base  Config
+	level *LevelVar // shared by the configs, see Config.Apply
+	onErr func(err error)
+
+	cfg     Config
+	info    os.FileInfo
+	handler *swapRoot
+
+	mu      sync.Mutex // guards current against the reloads
+	current *Logger    // the Logger of cfg, which owns its files
+	stopped bool
+
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+// closer returns the func closing the files of l,
+// once the handler of l is replaced and unused.
+func (w *configWatcher) closer(l *Logger) func() {
+	return func() {
+		if err := l.Close(); err != nil {
+			w.onErr(err)
+		}
+	}
+}
+
+func (w *configWatcher) stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.stopped = true
+		w.handler.stop()
+	})
+}
+
+// Close implements io.Closer for the Logger of Watch, and stops watching.
+func (w *configWatcher) Close() error {
+	w.stop()
+	return nil
+}
+
+// Flush implements Flusher for Logger.Sync, and syncs the current config.
+func (w *configWatcher) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return nil
+	}
+	return w.current.Sync()
+}
+
+// Reopen implements Reopener for Logger.Reopen, and reopens the files of the current config.
+func (w *configWatcher) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return nil
+	}
+	return w.current.Reopen()
+}
+
+// load reads and validates the config file.
+func (w *configWatcher) load() (Config, os.FileInfo, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(w.path))
+	configDecodersMu.Lock()
+	decode, ok := configDecoders[ext]
+	configDecodersMu.Unlock()
+	if !ok {
+		return Config{}, nil, fmt.Errorf("no config decoder for %q, see RegisterConfigDecoder", ext)
+	}
+	cfg := w.base
+	if err := decode(data, &cfg); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid config %s: %w", w.path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid config %s: %w", w.path, err)
+	}
+	return cfg, info, nil
+}
+
+func (w *configWatcher) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(w.path)
+		if err != nil {
+			w.onErr(err)
+			continue
+		}
+		// a file replaced by a rename, like most editors save, is a change too
+		if os.SameFile(info, w.info) && info.ModTime().Equal(w.info.ModTime()) && info.Size() == w.info.Size() {
+			continue
+		}
+		if err := w.reload(); err != nil {
+			w.onErr(err)
+		}
+	}
+}
+
+// reload applies the changed config file, or keeps the current config if it is invalid.
+func (w *configWatcher) reload() error {
+	cfg, info, err := w.load()
+	if err != nil {
+		if info, statErr := os.Stat(w.path); statErr == nil {
+			// report it once, until the file changes again
+			w.info = info
+		}
+		return err
+	}
+	w.info = info
+
+	prev, next := w.cfg, cfg
+	prev.Level, next.Level = "", ""
+	if !reflect.DeepEqual(prev, next) {
+		current := New(cfg, w.level)
+		w.mu.Lock()
+		w.current = current
+		w.handler.store(current.Handler(), w.closer(current))
+		w.mu.Unlock()
+	}
+	w.cfg = cfg
+	return cfg.Apply(w.current)
+}
+
+// swapRoot is the handler of Watch, which is replaced when the config changes.
+type swapRoot struct {
+	current atomic.Pointer[swapGeneration] // nil once stopped
+}
+
+// swapGeneration is a handler of swapRoot, whose files are closed
+// once it is replaced and its records in flight are handled.
+type swapGeneration struct {
+	handler   Handler
+	refs      atomic.Int64 // the records in flight, plus one while current
+	close     func()
+	closeOnce sync.Once
+}
+
+func (g *swapGeneration) release() {
+	if g.refs.Add(-1) == 0 {
+		g.closeOnce.Do(g.close)
+	}
+}
+
+// store makes h the current handler, close is called once h is replaced and unused.
+func (r *swapRoot) store(h Handler, close func()) {
+	g := &swapGeneration{handler: h, close: close}
+	g.refs.Store(1)
+	if prev := r.current.Swap(g); prev != nil {
+		prev.release()
+	}
+}
+
+// stop releases the current handler, the records are dropped afterwards.
+func (r *swapRoot) stop() {
+	if prev := r.current.Swap(nil); prev != nil {
+		prev.release()
+	}
+}
+
+// acquire returns the current generation, which must be released
+// once the record is handled, or nil once stopped.
+func (r *swapRoot) acquire() *swapGeneration {
+	for {
+		g := r.current.Load()
+		if g == nil {
+			return nil
+		}
+		g.refs.Add(1)
+		// g may have been replaced before it was acquired
+		if r.current.Load() == g {
+			return g
+		}
+		g.release()
+	}
+}
+
+// swapHandler applies its WithAttrs and WithGroup to the current handler of
+// root, and caches the derived handler until the handler is replaced.
+type swapHandler struct {
+	root   *swapRoot
+	parent *swapHandler
+	derive func(h Handler) Handler // WithAttrs or WithGroup of the parent, nil for the root
+
+	cache atomic.Pointer[swapCache]
+}
+
+type swapCache struct {
+	generation *swapGeneration
+	handler    Handler
+}
+
+// handler returns the handler derived from the current handler of the root,
+// nil once stopped.
+func (h *swapHandler) handler() Handler {
+	generation := h.root.current.Load()
+	if generation == nil {
+		return nil
+	}
+	return h.handlerOf(generation)
+}
+
+func (h *swapHandler) handlerOf(generation *swapGeneration) Handler {
+	if h.derive == nil {
+		return generation.handler
+	}
+	if c := h.cache.Load(); c != nil && c.generation == generation {
+		return c.handler
+	}
+	handler := h.derive(h.parent.handlerOf(generation))
+	h.cache.Store(&swapCache{generation: generation, handler: handler})
+	return handler
+}
+
+func (h *swapHandler) NeedsSource() bool {
+	handler := h.handler()
+	return handler != nil && needsSource(handler)
+}
+
+func (h *swapHandler) Enabled(ctx context.Context, level Level) bool {
+	handler := h.handler()
+	return handler != nil && handler.Enabled(ctx, level)
+}
+
+func (h *swapHandler) Handle(ctx context.Context, record Record) error {
+	generation := h.root.acquire()
+	if generation == nil {
+		return nil
+	}
+	defer generation.release()
+	return h.handlerOf(generation).Handle(ctx, record)
+}
+
+func (h *swapHandler) WithAttrs(attrs []Attr) Handler {
+	return &swapHandler{root: h.root, parent: h, derive: func(h Handler) Handler { return h.WithAttrs(attrs) }}
+}
+
+func (h *swapHandler) WithGroup(name string) Handler {
+	if name == "" {
+		return h
+	}
+	return &swapHandler{root: h.root, parent: h, derive: func(h Handler) Handler { return h.WithGroup(name) }}
+}
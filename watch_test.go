@@ -0,0 +1,216 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	output := filepath.Join(dir, "app.log")
+	// write replaces the config atomically, the watcher may poll in the middle of a write
+	write := func(content string) {
+		t.Helper()
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"format": "logfmt", "level": "warn"}`)
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	onErr := func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+	l, stop, err := Watch(path, Config{Filename: output}, onErr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	sub := l.With("a", 1).WithGroup("g")
+
+	// waitFor logs with sub until the record of msg contains want
+	waitFor := func(msg, want string) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			sub.Info(msg, "b", 2)
+			b, _ := os.ReadFile(output)
+			if strings.Contains(string(b), want) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		b, _ := os.ReadFile(output)
+		t.Fatalf("output = %q, want %s", b, want)
+	}
+
+	sub.Info("dropped")
+	write(`{"format": "logfmt", "level": "info"}`)
+	waitFor("level", "msg=level a=1 g.b=2")
+
+	write(`{"format": "json"}`)
+	waitFor("format", `"msg":"format","a":1,"g":{"b":2}`)
+
+	write(`{"format": "yaml"}`)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `unknown format "yaml"`) {
+		t.Errorf("errors = %v, want the unknown format once", errs)
+	}
+	mu.Unlock()
+	waitFor("kept", `"msg":"kept"`)
+
+	stop()
+	b, _ := os.ReadFile(output)
+	if strings.Contains(string(b), "dropped") {
+		t.Errorf("output = %q, want the info record dropped at the warn level", b)
+	}
+
+	toml := filepath.Join(dir, "log.toml")
+	if err := os.WriteFile(toml, []byte(`format = "json"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Watch(toml, Config{}, nil); err == nil || !strings.Contains(err.Error(), "no config decoder") {
+		t.Errorf("Watch() of an unknown extension = %v, want no config decoder", err)
+	}
+}
+
+func TestWatch_RegisterConfigDecoder(t *testing.T) {
+	defer func(interval time.Duration, decoders map[string]func([]byte, *Config) error) {
+		watchInterval, configDecoders = interval, decoders
+	}(watchInterval, maps.Clone(configDecoders))
+	watchInterval = 10 * time.Millisecond
+
+	// a decoder of the flat `key: value` YAML documents
+	RegisterConfigDecoder(".YAML", func(data []byte, cfg *Config) error {
+		m := make(map[string]string)
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(line, ":"); ok {
+				m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+			}
+		}
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, cfg)
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.yaml")
+	output := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("format: json\nlevel: warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, stop, err := Watch(path, Config{Filename: output}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	l.Info("dropped")
+	l.Warn("kept")
+	b, _ := os.ReadFile(output)
+	if got := string(b); strings.Contains(got, "dropped") || !strings.Contains(got, `"msg":"kept"`) {
+		t.Errorf("output = %q, want the warn record as json", got)
+	}
+}
+
+func TestWatch_ReopenSync(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	output := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte(`{"format": "logfmt"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, stop, err := Watch(path, Config{Filename: output}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	l.Info("before")
+	if err := os.Rename(output, output+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.With("a", 1).Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after")
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(output)
+	if got := string(b); strings.Contains(got, "before") || !strings.Contains(got, "msg=after") {
+		t.Errorf("output = %q, want only the record after Reopen", got)
+	}
+
+	// the records logged after Close are dropped, the file stays closed
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("closed")
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ = os.ReadFile(output)
+	if strings.Contains(string(b), "closed") {
+		t.Errorf("output = %q, want the record after Close dropped", b)
+	}
+}
+
+func TestSwapRoot(t *testing.T) {
+	var closed []string
+	root := &swapRoot{}
+	root.store(nopHandler{}, func() { closed = append(closed, "first") })
+	inFlight := root.acquire()
+	root.store(nopHandler{}, func() { closed = append(closed, "second") })
+	if len(closed) != 0 {
+		t.Fatalf("closed = %v, want the first handler kept for the record in flight", closed)
+	}
+	inFlight.release()
+	if len(closed) != 1 {
+		t.Fatalf("closed = %v, want the first handler closed once released", closed)
+	}
+
+	g := root.acquire()
+	g.release()
+	root.stop()
+	if root.acquire() != nil {
+		t.Error("acquire() after stop != nil")
+	}
+	if !slices.Equal(closed, []string{"first", "second"}) {
+		t.Errorf("closed = %v, want first and second closed once", closed)
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// HTTPOption configures the handler created by NewHTTPHandler.
+type HTTPOption func(s *httpSink)
+
+// WithHTTPHeader adds a header to the requests.
+func WithHTTPHeader(key, value string) HTTPOption {
+	return func(s *httpSink) {
+		s.header.Add(key, value)
+	}
+}
+
+// WithHTTPBasicAuth sets the basic auth of the requests.
+func WithHTTPBasicAuth(username, password string) HTTPOption {
+	return func(s *httpSink) {
+		req := http.Request{Header: make(http.Header)}
+		req.SetBasicAuth(username, password)
+		s.header.Set("Authorization", req.Header.Get("Authorization"))
+	}
+}
+
+// WithHTTPBearerToken sets the bearer token of the requests.
+func WithHTTPBearerToken(token string) HTTPOption {
+	return func(s *httpSink) {
+		s.header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// WithHTTPBatch posts the records once there are size of them or interval
+// has elapsed since the last post, 100 and 5s by default.
+// A size of 1 or less posts each record from Handle instead,
+// which returns the error of the post. A non-positive interval
+// keeps the default one.
+func WithHTTPBatch(size int, interval time.Duration) HTTPOption {
+	return func(s *httpSink) {
+		s.batchSize = size
+		if interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+// WithHTTPNDJSON posts the records as newline-delimited JSON
+// instead of a JSON array.
+func WithHTTPNDJSON() HTTPOption {
+	return func(s *httpSink) {
+		s.ndjson = true
+	}
+}
+
+// WithHTTPMarshal reshapes each record before it is posted, fn is called
+// with the record as rendered by the slog JSON handler, and its result
+// is marshaled as the JSON of the record.
+func WithHTTPMarshal(fn func(entry map[string]any) any) HTTPOption {
+	return func(s *httpSink) {
+		s.marshal = fn
+	}
+}
+
+// WithHTTPRetries sets how many times a post failing with a network error,
+// 429 or 5xx is retried with an exponential backoff, 3 by default.
+func WithHTTPRetries(retries int) HTTPOption {
+	return func(s *httpSink) {
+		s.retries = retries
+	}
+}
+
+// WithHTTPClient sets the client used to post, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) HTTPOption {
+	return func(s *httpSink) {
+		s.client = client
+	}
+}
+
+// NewHTTPHandler creates a Handler that posts the records to url
+// in batches, as a JSON array of the records rendered by the slog
+// JSON handler, or as NDJSON with WithHTTPNDJSON.
+// The failed posts of batches are reported to the func set by SetErrorHandler.
+// Close the returned handler to post the pending records.
+func NewHTTPHandler(url string, opts *HandlerOptions, options ...HTTPOption) Handler {
+	if opts == nil {
+		opts = new(HandlerOptions)
+	}
+	s := &httpSink{
+		url:       url,
+		client:    http.DefaultClient,
+		header:    make(http.Header),
+		batchSize: 100,
+		interval:  5 * time.Second,
+		retries:   3,
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.ndjson {
+		s.header.Set("Content-Type", "application/x-ndjson")
+	} else {
+		s.header.Set("Content-Type", "application/json")
+	}
+	if s.batchSize > 1 {
+		s.batcher = newBatcher("http", s.batchSize, 0, 0, s.interval, s.post)
+	}
+	return &httpHandler{handler: slog.NewJSONHandler(s, opts), sink: s, addSource: opts.AddSource}
+}
+
+type httpHandler struct {
+	handler   Handler
+	sink      *httpSink // shared among all clones of this handler
+	addSource bool
+}
+
+// Close implements io.Closer, and posts the pending records.
+func (h *httpHandler) Close() error {
+	if h.sink.batcher == nil {
+		return nil
+	}
+	return h.sink.batcher.close()
+}
+
+// Flush implements Flusher, and posts the pending records.
+func (h *httpHandler) Flush() error {
+	if h.sink.batcher == nil {
+		return nil
+	}
+	return h.sink.batcher.flushPending()
+}
+
+func (h *httpHandler) NeedsSource() bool {
+	return h.addSource
+}
+
+func (h *httpHandler) Enabled(ctx context.Context, level Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *httpHandler) Handle(ctx context.Context, record Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *httpHandler) WithAttrs(attrs []Attr) Handler {
+	return &httpHandler{handler: h.handler.WithAttrs(attrs), sink: h.sink, addSource: h.addSource}
+}
+
+func (h *httpHandler) WithGroup(name string) Handler {
+	return &httpHandler{handler: h.handler.WithGroup(name), sink: h.sink, addSource: h.addSource}
+}
+
+// httpSink is the writer of the slog JSON handler,
+// which is called once per record.
+type httpSink struct {
+	url       string
+	client    *http.Client
+	header    http.Header
+	batchSize int
+	interval  time.Duration
+	ndjson    bool
+	marshal   func(entry map[string]any) any
+	retries   int
+	batcher   *batcher[[]byte]
+}
+
+func (s *httpSink) Write(p []byte) (int, error) {
+	entry := bytes.TrimSpace(p)
+	if s.marshal != nil {
+		var m map[string]any
+		if err := json.Unmarshal(entry, &m); err != nil {
+			return 0, err
+		}
+		b, err := json.Marshal(s.marshal(m))
+		if err != nil {
+			return 0, err
+		}
+		entry = b
+	} else {
+		// p is reused by the JSON handler
+		entry = slices.Clone(entry)
+	}
+
+	if s.batcher == nil {
+		if err := s.post([][]byte{entry}); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if err := s.batcher.add(entry, len(entry)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// post posts entries as a JSON array or as NDJSON.
+func (s *httpSink) post(entries [][]byte) error {
+	var body []byte
+	if s.ndjson {
+		body = append(bytes.Join(entries, []byte{'\n'}), '\n')
+	} else {
+		body = append([]byte{'['}, bytes.Join(entries, []byte{','})...)
+		body = append(body, ']')
+	}
+	if _, err := postWithRetry(s.client, s.url, s.header, body, s.retries); err != nil {
+		return fmt.Errorf("http post failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type httpServer struct {
+	mu     sync.Mutex
+	header http.Header
+	bodies []string
+}
+
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	s.header = r.Header
+	s.bodies = append(s.bodies, string(body))
+}
+
+func TestHTTPHandler(t *testing.T) {
+	s := new(httpServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	h := NewHTTPHandler(srv.URL, nil,
+		WithHTTPBatch(2, time.Hour),
+		WithHTTPBearerToken("token"),
+		WithHTTPHeader("X-Test", "1"),
+		WithHTTPMarshal(func(entry map[string]any) any {
+			delete(entry, TimeKey)
+			return entry
+		}),
+	)
+	l := NewLogger(h).With("a", 1).WithGroup("g")
+	l.Info("one", "b", 2)
+	l.Info("two")
+	l.Info("three")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{
+		`[{"a":1,"g":{"b":2},"level":"INFO","msg":"one"},{"a":1,"level":"INFO","msg":"two"}]`,
+		`[{"a":1,"level":"INFO","msg":"three"}]`,
+	}
+	if strings.Join(s.bodies, "\n") != strings.Join(want, "\n") {
+		t.Errorf("bodies = %q, want %q", s.bodies, want)
+	}
+	if got := s.header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := s.header.Get("X-Test"); got != "1" {
+		t.Errorf("X-Test = %q", got)
+	}
+	if got := s.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestHTTPHandler_PerRecord(t *testing.T) {
+	s := new(httpServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	h := NewHTTPHandler(srv.URL, &HandlerOptions{ReplaceAttr: func(groups []string, a Attr) Attr {
+		if len(groups) == 0 && a.Key == TimeKey {
+			return Attr{}
+		}
+		return a
+	}},
+		WithHTTPBatch(1, 0),
+		WithHTTPNDJSON(),
+		WithHTTPBasicAuth("user", "pass"),
+	)
+	l := NewLogger(h)
+	l.Info("one")
+	l.Warn("two")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want := []string{
+		`{"level":"INFO","msg":"one"}` + "\n",
+		`{"level":"WARN","msg":"two"}` + "\n",
+	}
+	if strings.Join(s.bodies, "|") != strings.Join(want, "|") {
+		t.Errorf("bodies = %q, want %q", s.bodies, want)
+	}
+	if got := s.header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if user, pass, ok := (&http.Request{Header: s.header}).BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("basic auth = %q %q %v", user, pass, ok)
+	}
+}
+
+func TestHTTPHandler_PerRecordError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	h := NewHTTPHandler(srv.URL, nil, WithHTTPBatch(0, 0))
+	if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, LevelInfo, "hello", 0)); err == nil {
+		t.Error("error = nil, want post failure")
+	}
+}
+
+func TestHTTPHandler_ZeroInterval(t *testing.T) {
+	s := new(httpServer)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	h := NewHTTPHandler(srv.URL, nil, WithHTTPBatch(10, 0))
+	NewLogger(h).Info("one")
+	if err := h.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.bodies) != 1 || !strings.Contains(s.bodies[0], `"msg":"one"`) {
+		t.Errorf("bodies = %q, want the record posted on Close", s.bodies)
+	}
+}
+
+func TestBatcher_ZeroWait(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		flushed [][]int
+	)
+	b := newBatcher("test", 2, 0, 0, 0, func(items []int) error {
+		mu.Lock()
+		flushed = append(flushed, items)
+		mu.Unlock()
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		if err := b.add(i, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(flushed) != 2 || len(flushed[0]) != 2 || len(flushed[1]) != 1 {
+		t.Errorf("flushed = %v, want a full batch and the pending one", flushed)
+	}
+}
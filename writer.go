@@ -37,10 +37,23 @@ const (
 	megabyte = 1024 * 1024
 )
 
-func NewWriter(cfg Config) io.WriteCloser {
-	if len(cfg.Filename) == 0 {
-		return os.Stderr
-	}
+// currentTime exists so it can be mocked out by tests.
+var currentTime = time.Now
+
+// Rotator is a writer of log files which can be rotated, e.g. *Writer.
+// Implement it to plug in another rotation backend, see New.
+type Rotator interface {
+	io.WriteCloser
+	// Rotate closes the current file, and opens a new one.
+	Rotate() error
+}
+
+// RotatorFunc creates the Rotator writing to the file of cfg.Filename
+// with the rotation settings of cfg.
+type RotatorFunc func(cfg Config) Rotator
+
+// NewRotator creates the default Rotator, a Writer with the rotation settings of cfg.
+func NewRotator(cfg Config) Rotator {
 	return &Writer{
 		Filename:   cfg.Filename,
 		MaxSize:    cfg.MaxSize,
@@ -48,11 +61,22 @@ func NewWriter(cfg Config) io.WriteCloser {
 		MaxBackups: cfg.MaxBackups,
 		LocalTime:  cfg.LocalTime,
 		Compress:   cfg.Compress,
+
+		RotateInterval: cfg.rotateInterval(),
+	}
+}
+
+// NewWriter creates the default Rotator of cfg, see NewRotator,
+// or returns os.Stderr if cfg has no Filename.
+func NewWriter(cfg Config) io.WriteCloser {
+	if len(cfg.Filename) == 0 {
+		return os.Stderr
 	}
+	return NewRotator(cfg)
 }
 
-// ensure we always implement io.WriteCloser
-var _ io.WriteCloser = (*Writer)(nil)
+// ensure we always implement Rotator
+var _ Rotator = (*Writer)(nil)
 
 type Writer struct {
 	// Filename is the file to write logs to.  Backup log files will be retained
@@ -85,9 +109,15 @@ type Writer struct {
 	// using gzip. The default is not to perform compression.
 	Compress bool
 
-	size int64
-	file *os.File
-	mu   sync.Mutex
+	// RotateInterval rotates the log file at the first write of each interval,
+	// e.g. 24h for a file per day. The intervals are aligned to the midnight of
+	// the time zone of LocalTime. The default is to rotate by size only.
+	RotateInterval time.Duration
+
+	size   int64
+	period time.Time // start of the RotateInterval of the file
+	file   *os.File
+	mu     sync.Mutex
 
 	millCh    chan bool
 	startMill sync.Once
@@ -114,7 +144,7 @@ func (l *Writer) Write(p []byte) (n int, err error) {
 		}
 	}
 
-	if l.size+writeLen > l.max() {
+	if l.size+writeLen > l.max() || l.RotateInterval > 0 && !l.periodOf(currentTime()).Equal(l.period) {
 		if err := l.rotate(); err != nil {
 			return 0, err
 		}
@@ -143,6 +173,27 @@ func (l *Writer) close() error {
 	return err
 }
 
+// Reopen closes the log file and opens the file of the name again, e.g. after
+// logrotate moved it away. It doesn't rotate the file, unless it is too large.
+func (l *Writer) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.close(); err != nil {
+		return err
+	}
+	return l.openExistingOrNew(0)
+}
+
+// Sync commits the current file to stable storage, see Syncer.
+func (l *Writer) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
 // Rotate causes Logger to close the existing log file and immediately create a
 // new one.  This is a helper function for applications that want to initiate
 // rotations outside of the normal rotation rules, such as in response to
@@ -203,6 +254,7 @@ func (l *Writer) openNew() error {
 	}
 	l.file = f
 	l.size = 0
+	l.period = l.periodOf(currentTime())
 	return nil
 }
 
@@ -224,6 +276,10 @@ func (l *Writer) openExistingOrNew(writeLen int) error {
 	if info.Size()+int64(writeLen) >= l.max() {
 		return l.rotate()
 	}
+	period := l.periodOf(currentTime())
+	if l.RotateInterval > 0 && !l.periodOf(info.ModTime()).Equal(period) {
+		return l.rotate()
+	}
 
 	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
@@ -233,9 +289,25 @@ func (l *Writer) openExistingOrNew(writeLen int) error {
 	}
 	l.file = file
 	l.size = info.Size()
+	l.period = period
 	return nil
 }
 
+// periodOf returns the start of the RotateInterval of t,
+// the zero time if it isn't set.
+func (l *Writer) periodOf(t time.Time) time.Time {
+	if l.RotateInterval <= 0 {
+		return time.Time{}
+	}
+	if !l.LocalTime {
+		return t.UTC().Truncate(l.RotateInterval)
+	}
+	// Truncate works on the absolute time, shift it to align to the local midnight
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(l.RotateInterval).Add(-shift)
+}
+
 // filename generates the name of the logfile from the current time.
 func (l *Writer) filename() string {
 	if l.Filename != "" {
@@ -283,7 +355,7 @@ func (l *Writer) millRunOnce() error {
 	}
 	if l.MaxAge > 0 {
 		diff := time.Duration(int64(24*time.Hour) * int64(l.MaxAge))
-		cutoff := time.Now().Add(-1 * diff)
+		cutoff := currentTime().Add(-1 * diff)
 
 		var remaining []logInfo
 		for _, f := range files {
@@ -419,7 +491,7 @@ func backupName(name string, local bool) string {
 	filename := filepath.Base(name)
 	ext := filepath.Ext(filename)
 	prefix := filename[:len(filename)-len(ext)]
-	t := time.Now()
+	t := currentTime()
 	if !local {
 		t = t.UTC()
 	}
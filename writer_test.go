@@ -0,0 +1,225 @@
+// Copyright © 2023 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wslog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter_RotateInterval(t *testing.T) {
+	now := time.Date(2023, 8, 18, 23, 59, 0, 0, time.UTC)
+	currentTime = func() time.Time { return now }
+	defer func() { currentTime = time.Now }()
+
+	dir := t.TempDir()
+	w := NewWriter(Config{Filename: filepath.Join(dir, "app.log"), RotateInterval: "daily"})
+	defer w.Close()
+
+	write := func(s string) {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a\n")
+	now = now.Add(30 * time.Second)
+	write("b\n")
+	now = now.Add(time.Minute)
+	write("c\n")
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "c\n" {
+		t.Errorf("app.log = %q, want %q", b, "c\n")
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "app-2023-08-19T00-00-30.000.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a\nb\n" {
+		t.Errorf("backup = %q, want %q", b, "a\nb\n")
+	}
+}
+
+func TestWriter_periodOf(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	ts := time.Date(2023, 8, 18, 7, 30, 0, 0, loc)
+	tests := []struct {
+		local bool
+		want  time.Time
+	}{
+		{false, time.Date(2023, 8, 17, 0, 0, 0, 0, time.UTC)},
+		{true, time.Date(2023, 8, 18, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		w := &Writer{RotateInterval: 24 * time.Hour, LocalTime: tt.local}
+		if got := w.periodOf(ts); !got.Equal(tt.want) {
+			t.Errorf("periodOf(%v) local=%v = %v, want %v", ts, tt.local, got, tt.want)
+		}
+	}
+}
+
+func TestLogger_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	l := New(Config{Format: "logfmt", Filename: name})
+	defer l.Close()
+
+	l.Info("one")
+	// logrotate moves the file away, the writes go on to the moved file
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("two")
+	if err := l.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("three")
+
+	for file, want := range map[string]string{name + ".1": "msg=one msg=two", name: "msg=three"} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msgs []string
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			msgs = append(msgs, line[strings.Index(line, "msg="):])
+		}
+		if got := strings.Join(msgs, " "); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), got, want)
+		}
+	}
+}
+
+type memRotator struct {
+	name    string
+	buf     bytes.Buffer
+	rotated int
+	synced  int
+	closed  bool
+}
+
+func (r *memRotator) Write(p []byte) (int, error) { return r.buf.Write(p) }
+func (r *memRotator) Close() error                { r.closed = true; return nil }
+func (r *memRotator) Rotate() error               { r.rotated++; return nil }
+func (r *memRotator) Sync() error                 { r.synced++; return nil }
+
+func TestNew_Rotator(t *testing.T) {
+	var created []*memRotator
+	newRotator := func(cfg Config) Rotator {
+		r := &memRotator{name: cfg.Filename}
+		created = append(created, r)
+		return r
+	}
+	l := New(Config{Format: "logfmt", Filename: "app.log", ErrorFilename: "app.err.log"}, newRotator)
+	l.Info("info")
+	l.Error("error")
+	rotators := l.Rotators()
+	if len(rotators) != 2 || rotators[0] != created[0] || rotators[1] != created[1] {
+		t.Fatalf("Rotators() = %v, want %v", rotators, created)
+	}
+	for _, r := range rotators {
+		if err := r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if created[0].name != "app.log" || strings.Count(created[0].buf.String(), "\n") != 2 ||
+		!created[0].closed || created[0].rotated != 1 {
+		t.Errorf("app.log = %+v", created[0])
+	}
+	if created[1].name != "app.err.log" || strings.Count(created[1].buf.String(), "\n") != 1 || !created[1].closed {
+		t.Errorf("app.err.log = %+v", created[1])
+	}
+
+	r := new(memRotator)
+	l = New(Config{Format: "logfmt"}, r)
+	l.Info("info")
+	if got := l.Rotators(); len(got) != 1 || got[0] != r || r.buf.Len() == 0 {
+		t.Errorf("Rotators() = %v, want the Rotator passed to New", got)
+	}
+}
+
+func TestConfig_Sync(t *testing.T) {
+	tests := []struct {
+		cfg  Config
+		want int
+	}{
+		{cfg: Config{SyncEveryWrite: true}, want: 3},
+		{cfg: Config{SyncLevel: SLevelWarn}, want: 2},
+		{cfg: Config{}, want: 0},
+	}
+	for _, tt := range tests {
+		r := new(memRotator)
+		l := New(tt.cfg, r).With("a", 1)
+		l.Info("info")
+		l.Warn("warn")
+		l.Error("error")
+		if r.synced != tt.want {
+			t.Errorf("%+v: synced %d times, want %d", tt.cfg, r.synced, tt.want)
+		}
+		if err := l.Sync(); err != nil || r.synced != tt.want+1 {
+			t.Errorf("%+v: Sync() = %v, synced %d times, want %d", tt.cfg, err, r.synced, tt.want+1)
+		}
+	}
+
+	var reported error
+	SetErrorHandler(func(err error) { reported = err })
+	defer SetErrorHandler(nil)
+	var buf bytes.Buffer
+	New(Config{SyncEveryWrite: true}, &buf).Info("info")
+	if reported == nil || buf.Len() == 0 {
+		t.Errorf("SyncEveryWrite without a file: reported %v, output %q, want an error and the record", reported, buf.String())
+	}
+}
+
+func TestWriter_Sync(t *testing.T) {
+	w := NewRotator(Config{Filename: filepath.Join(t.TempDir(), "app.log")}).(*Writer)
+	defer w.Close()
+	if err := w.Sync(); err != nil {
+		t.Fatalf("Sync() before the first write = %v", err)
+	}
+	if _, err := w.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Sync(); err != nil {
+		t.Errorf("Sync() = %v", err)
+	}
+}
+
+// BenchmarkConfig_Sync compares the cost of fsyncing the file after each record.
+func BenchmarkConfig_Sync(b *testing.B) {
+	for _, sync := range []bool{false, true} {
+		b.Run(fmt.Sprintf("SyncEveryWrite=%v", sync), func(b *testing.B) {
+			cfg := Config{Format: "json", Filename: filepath.Join(b.TempDir(), "app.log"), SyncEveryWrite: sync}
+			l := New(cfg)
+			defer l.Close()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Info("benchmark", "user", "admin", "id", i)
+			}
+		})
+	}
+}
@@ -15,21 +15,103 @@
 package wslog
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"os"
+	"slices"
 	"strings"
 	"sync/atomic"
+	"time"
 )
 
 type Config struct {
 	Level  SLevel `json:"level,omitempty" yaml:"level,omitempty"`
 	Format string `json:"format,omitempty" yaml:"format,omitempty"`
 	Source bool   `json:"source,omitempty" yaml:"source,omitempty"`
+	// SourceFormat is one of full (default), short, relative or function.
+	SourceFormat SourceFormat `json:"sourceFormat,omitempty" yaml:"sourceFormat,omitempty"`
 
-	// only use for default log handler
+	// Preset renames the built-in keys of the json and text formats
+	// for a well-known schema, e.g. ecs.
+	Preset string `json:"preset,omitempty" yaml:"preset,omitempty"`
+	// TimeField, LevelField and MessageField rename the built-in keys
+	// of the json and text formats, they take precedence over the Preset.
+	TimeField    string `json:"timeField,omitempty" yaml:"timeField,omitempty"`
+	LevelField   string `json:"levelField,omitempty" yaml:"levelField,omitempty"`
+	MessageField string `json:"messageField,omitempty" yaml:"messageField,omitempty"`
+	// SafeIntegers writes the integers of the json and text formats beyond
+	// ±(2^53-1) as strings, which JavaScript consumers can't parse exactly.
+	// StringIntKeys always writes the integers of the keys as strings, the
+	// keys are attr keys or group-qualified keys like `order.id`.
+	SafeIntegers  bool     `json:"safeIntegers,omitempty" yaml:"safeIntegers,omitempty"`
+	StringIntKeys []string `json:"stringIntKeys,omitempty" yaml:"stringIntKeys,omitempty"`
+	// Indent pretty-prints each record of the json format, only use for development
+	Indent string `json:"indent,omitempty" yaml:"indent,omitempty"`
+	// CEFVendor, CEFProduct and CEFVersion identify the device of the cef format.
+	CEFVendor  string `json:"cefVendor,omitempty" yaml:"cefVendor,omitempty"`
+	CEFProduct string `json:"cefProduct,omitempty" yaml:"cefProduct,omitempty"`
+	CEFVersion string `json:"cefVersion,omitempty" yaml:"cefVersion,omitempty"`
+
+	// only use for default log handler, the logfmt format never writes colors
 	DisableColor bool `json:"disableColor,omitempty" yaml:"disableColor,omitempty"`
+	// Multiline is how the default log handler writes messages
+	// containing newlines, one of escape (default) or block.
+	Multiline MultilineStyle `json:"multiline,omitempty" yaml:"multiline,omitempty"`
+	// TimeUTC writes the record times in UTC, TimeZone writes them in the
+	// IANA time zone of the name, e.g. Asia/Shanghai, instead of the local time.
+	TimeUTC  bool   `json:"timeUTC,omitempty" yaml:"timeUTC,omitempty"`
+	TimeZone string `json:"timeZone,omitempty" yaml:"timeZone,omitempty"`
+	// PadLevel pads the levels of the default log handler to the same width.
+	PadLevel bool `json:"padLevel,omitempty" yaml:"padLevel,omitempty"`
+	// ShortLevels writes the levels of the default log handler
+	// as three letters, e.g. INF, see ShortLevelLabels.
+	ShortLevels bool `json:"shortLevels,omitempty" yaml:"shortLevels,omitempty"`
+	// ExpandCollections renders the slices and maps of the default log handler
+	// as groups, e.g. `tags.0=a tags.1=b`, see WithExpandCollections.
+	ExpandCollections bool `json:"expandCollections,omitempty" yaml:"expandCollections,omitempty"`
+	// DisableTime omits the time of the records, e.g. under journald or
+	// Docker which already timestamp the lines, and DisableLevel omits
+	// their level, e.g. when the lines are colored or prefixed externally.
+	DisableTime  bool `json:"disableTime,omitempty" yaml:"disableTime,omitempty"`
+	DisableLevel bool `json:"disableLevel,omitempty" yaml:"disableLevel,omitempty"`
+	// GoroutineID adds the ID of the logging goroutine to the records,
+	// only use for development, see Logger.WithGoroutineID.
+	GoroutineID bool `json:"goroutineID,omitempty" yaml:"goroutineID,omitempty"`
+
+	// Fields are added to every record, before any group, e.g.
+	// {service: api, env: prod}. AddHostname and AddPID also add the
+	// hostname and the process ID with HostnameKey and PIDKey.
+	Fields      map[string]any `json:"fields,omitempty" yaml:"fields,omitempty"`
+	AddHostname bool           `json:"addHostname,omitempty" yaml:"addHostname,omitempty"`
+	AddPID      bool           `json:"addPID,omitempty" yaml:"addPID,omitempty"`
+
+	// MaxValueLength truncates string values longer than the given number of runes.
+	MaxValueLength int `json:"maxValueLength,omitempty" yaml:"maxValueLength,omitempty"`
+	// MaxAttrs caps the number of attrs per record.
+	MaxAttrs int `json:"maxAttrs,omitempty" yaml:"maxAttrs,omitempty"`
+	// RedactKeys masks the values of the matching attribute keys,
+	// keys containing glob meta characters like `*.secret` are matched as globs.
+	RedactKeys []string `json:"redactKeys,omitempty" yaml:"redactKeys,omitempty"`
+	// SortKeys sorts the attrs of each record by key.
+	SortKeys bool `json:"sortKeys,omitempty" yaml:"sortKeys,omitempty"`
+	// DuplicateKeys is the policy for attributes sharing a key,
+	// one of keep-all (default), keep-last, keep-first or error.
+	DuplicateKeys string `json:"duplicateKeys,omitempty" yaml:"duplicateKeys,omitempty"`
+
+	// Output is where the records are written, instead of Filename:
+	//   - stdout, stderr or discard, the colors of the default format are
+	//     disabled if the stream isn't a terminal
+	//   - a path, or a file:///path URL, rotated with the settings of Filename
+	//   - a network collector, e.g. tcp://collector:5170, udp://host:514
+	//     or unix:///path/to.sock, see NewNetWriter
+	// An invalid Output is reported to the func set by SetErrorHandler,
+	// and os.Stderr is used instead, see Validate.
+	Output string `json:"output,omitempty" yaml:"output,omitempty"`
 
 	Filename   string `json:"filename,omitempty" yaml:"filename,omitempty"`
 	MaxSize    int    `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
@@ -37,57 +119,406 @@ type Config struct {
 	MaxBackups int    `json:"maxBackups,omitempty" yaml:"maxBackups,omitempty"`
 	LocalTime  bool   `json:"localTime,omitempty" yaml:"localTime,omitempty"`
 	Compress   bool   `json:"compress,omitempty" yaml:"compress,omitempty"`
+	// RotateInterval also rotates the files at each interval, one of daily,
+	// hourly or a duration like 12h, aligned to midnight per LocalTime.
+	RotateInterval string `json:"rotateInterval,omitempty" yaml:"rotateInterval,omitempty"`
+	// LevelFiles also writes the records of at least each level to the
+	// file of the name, e.g. {error: app.err.log}, with the rotation
+	// settings of Filename. ErrorFilename is a shortcut for the error level.
+	LevelFiles    map[SLevel]string `json:"levelFiles,omitempty" yaml:"levelFiles,omitempty"`
+	ErrorFilename string            `json:"errorFilename,omitempty" yaml:"errorFilename,omitempty"`
+	// SyncEveryWrite fsyncs the files of Filename and LevelFiles after
+	// each record, e.g. for an audit trail, and SyncLevel only after the
+	// records of at least the level. They require Filename, and slow
+	// down the logging a lot, see BenchmarkConfig_Sync.
+	SyncEveryWrite bool   `json:"syncEveryWrite,omitempty" yaml:"syncEveryWrite,omitempty"`
+	SyncLevel      SLevel `json:"syncLevel,omitempty" yaml:"syncLevel,omitempty"`
+	// AlsoStdout also writes the records to os.Stdout in ConsoleFormat,
+	// the default colored format if empty, e.g. to ship the JSON of
+	// Filename and read the text of the console. The colors are disabled
+	// if os.Stdout isn't a terminal.
+	AlsoStdout    bool   `json:"alsoStdout,omitempty" yaml:"alsoStdout,omitempty"`
+	ConsoleFormat string `json:"consoleFormat,omitempty" yaml:"consoleFormat,omitempty"`
+	// SplitStdStreams writes the records below SplitLevel to os.Stdout,
+	// and the others to os.Stderr, only if Filename is empty.
+	SplitStdStreams bool `json:"splitStdStreams,omitempty" yaml:"splitStdStreams,omitempty"`
+	// SplitLevel is the level of SplitStdStreams, warn by default.
+	SplitLevel SLevel `json:"splitLevel,omitempty" yaml:"splitLevel,omitempty"`
 }
 
+// HandlerOptions returns the options of the handlers from the Config.
+// An unknown Level is reported to the func set by SetErrorHandler,
+// and LevelInfo is used instead, see NewE to fail instead.
 func (c *Config) HandlerOptions() *HandlerOptions {
 	level := new(LevelVar)
-	level.Set(c.Level.Level())
+	if c.Level != "" {
+		l, err := ParseLevelStrict(c.Level)
+		if err != nil {
+			reportError(err)
+		}
+		level.Set(l)
+	}
 	return &HandlerOptions{
 		AddSource: c.Source,
 		Level:     level,
 	}
 }
 
+// slogHandlerOptions returns a copy of opts extended with the
+// Config features that the slog handlers don't support natively.
+func (c *Config) slogHandlerOptions(opts *HandlerOptions) *HandlerOptions {
+	cp := *opts
+	if loc := c.timeLocation(); loc != nil {
+		cp.ReplaceAttr = timeReplaceAttr(loc, cp.ReplaceAttr)
+	}
+	if c.SourceFormat != "" {
+		cp.ReplaceAttr = sourceReplaceAttr(c.SourceFormat, cp.ReplaceAttr)
+	}
+	if c.MaxValueLength > 0 {
+		jsonSource := strings.HasPrefix(strings.ToLower(c.Format), "json")
+		cp.ReplaceAttr = truncateReplaceAttr(c.MaxValueLength, jsonSource, cp.ReplaceAttr)
+	}
+	if c.SafeIntegers || len(c.StringIntKeys) > 0 {
+		cp.ReplaceAttr = intStringReplaceAttr(c.SafeIntegers, c.StringIntKeys, cp.ReplaceAttr)
+	}
+	// the label is rendered before the level is renamed
+	cp.ReplaceAttr = levelLabelReplaceAttr(cp.ReplaceAttr)
+	if c.Preset != "" || c.TimeField != "" || c.LevelField != "" || c.MessageField != "" {
+		cp.ReplaceAttr = c.fieldsReplaceAttr(cp.ReplaceAttr)
+	}
+	if c.DisableTime || c.DisableLevel {
+		cp.ReplaceAttr = omitBuiltinsReplaceAttr(c.DisableTime, c.DisableLevel, cp.ReplaceAttr)
+	}
+	return &cp
+}
+
+// logHandlerOptions returns the options of the default log handler from the Config.
+func (c *Config) logHandlerOptions() []LogHandlerOption {
+	options := []LogHandlerOption{
+		WithSourceFormat(c.SourceFormat),
+		WithSortAttrs(c.SortKeys),
+		WithMaxValueLength(c.MaxValueLength),
+		WithMaxAttrs(c.MaxAttrs),
+		WithMultilineStyle(c.Multiline),
+		WithPadLevel(c.PadLevel),
+		WithTimeLocation(c.timeLocation()),
+		WithDisableTime(c.DisableTime),
+		WithDisableLevel(c.DisableLevel),
+		WithExpandCollections(c.ExpandCollections),
+	}
+	if c.ShortLevels {
+		options = append(options, WithLevelLabels(ShortLevelLabels))
+	}
+	return options
+}
+
+// timeLocation returns the location of the record times, nil to keep them.
+// An unknown TimeZone is reported to the func set by SetErrorHandler.
+func (c *Config) timeLocation() *time.Location {
+	loc, err := c.parseTimeLocation()
+	if err != nil {
+		reportError(err)
+	}
+	return loc
+}
+
+func (c *Config) parseTimeLocation() (*time.Location, error) {
+	if c.TimeUTC {
+		return time.UTC, nil
+	}
+	if c.TimeZone == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone: %w", err)
+	}
+	return loc, nil
+}
+
+// wrapSlogHandler wraps h with the Config features that
+// the slog handlers don't support natively.
+func (c *Config) wrapSlogHandler(h Handler) Handler {
+	h = NewContextLevelHandler(h)
+	if c.MaxAttrs > 0 {
+		h = NewMaxAttrsHandler(h, c.MaxAttrs)
+	}
+	if c.SortKeys {
+		h = NewSortHandler(h)
+	}
+	return h
+}
+
+// Writer returns the writer of Output, or NewWriter if it isn't set.
 func (c *Config) Writer() io.Writer {
+	if c.Output != "" {
+		w, _ := c.outputWriter(NewRotator)
+		return w
+	}
 	return NewWriter(*c)
 }
 
+// New creates a Logger from cfg, opts override the defaults of cfg:
+//   - a Handler replaces the handler of the Format
+//   - an io.Writer replaces the output of Output and Filename
+//   - a Rotator replaces the output of Output and Filename, and is owned by the Logger
+//   - a RotatorFunc creates the Rotators of Filename and LevelFiles instead of NewRotator
+//   - *HandlerOptions, a Leveler or a ReplaceAttr func override the HandlerOptions
+//
+// Prefer the typed Options like WithWriter, the unknown opts are reported
+// to the func set by SetErrorHandler and ignored, see NewE to fail instead.
 func New(cfg Config, opts ...any) *Logger {
-	handlerOpts := cfg.HandlerOptions()
+	l, err := newLogger(cfg, opts)
+	if err != nil {
+		reportError(err)
+	}
+	return l
+}
 
-	var (
-		handler Handler
-		writer  io.Writer
-	)
+// newLogger creates the Logger of New, and returns the
+// error of the unknown opts, which are ignored.
+func newLogger(cfg Config, opts []any) (*Logger, error) {
+	o := newOptions{
+		handlerOpts: cfg.HandlerOptions(),
+		newRotator:  NewRotator,
+	}
+	var errs []error
 	for _, opt := range opts {
-		switch v := opt.(type) {
-		case io.Writer:
-			writer = v
-		case *HandlerOptions:
-			if v != nil {
-				handlerOpts = v
-			}
-		case func(groups []string, a Attr) Attr:
-			handlerOpts.ReplaceAttr = v
-		case Leveler:
-			handlerOpts.Level = v
-		case Handler:
-			handler = v
+		option, err := toOption(opt)
+		if err != nil {
+			errs = append(errs, err)
+			continue
 		}
+		option.apply(&o)
 	}
 
+	var (
+		handlerOpts = o.handlerOpts
+		handler     = o.handler
+		writer      = o.writer
+		rotators    = o.rotators
+		closers     []io.Closer
+		newRotator  = o.newRotator
+	)
 	if handler == nil {
-		writer = cfg.Writer()
-		switch strings.ToLower(cfg.Format) {
-		case "json":
-			handler = slog.NewJSONHandler(writer, handlerOpts)
-		case "text":
-			handler = slog.NewTextHandler(writer, handlerOpts)
+		switch {
+		case writer != nil:
+			handler = cfg.newHandler(writer, handlerOpts)
+		case cfg.Output != "":
+			var owned io.Closer
+			handler, owned = cfg.outputHandler(handlerOpts, newRotator)
+			switch w := owned.(type) {
+			case Rotator:
+				rotators = append(rotators, w)
+			case io.Closer:
+				closers = append(closers, w)
+			}
+		case cfg.Filename != "":
+			r := newRotator(cfg)
+			rotators = append(rotators, r)
+			handler = cfg.newHandler(r, handlerOpts)
+		case cfg.SplitStdStreams:
+			splitLevel := LevelWarn
+			if cfg.SplitLevel != "" {
+				splitLevel = cfg.SplitLevel.Level()
+			}
+			handler = NewLevelRouter(splitLevel,
+				cfg.newHandler(os.Stdout, handlerOpts),
+				cfg.newHandler(os.Stderr, handlerOpts),
+			)
 		default:
-			handler = NewLogHandler(writer, handlerOpts, cfg.DisableColor)
+			handler = cfg.newHandler(os.Stderr, handlerOpts)
+		}
+
+		handlers := []Handler{handler}
+		if cfg.AlsoStdout {
+			consoleCfg := cfg
+			consoleCfg.Format = cfg.ConsoleFormat
+			consoleCfg.DisableColor = cfg.DisableColor || !isTerminal(os.Stdout)
+			handlers = append(handlers, consoleCfg.newHandler(os.Stdout, handlerOpts))
+		}
+		for _, lf := range cfg.levelFiles() {
+			fileCfg := cfg
+			fileCfg.Filename = lf.filename
+			w := newRotator(fileCfg)
+			rotators = append(rotators, w)
+
+			fileOpts := *handlerOpts
+			fileOpts.Level = lf.level
+			handlers = append(handlers, &minLevelHandler{handler: cfg.newHandler(w, &fileOpts), level: lf.level})
+		}
+		if len(handlers) > 1 {
+			handler = NewMultiHandler(handlers...)
+		}
+	}
+	if cfg.SyncEveryWrite || cfg.SyncLevel != "" {
+		handler = cfg.newSyncHandler(handler, rotators)
+	}
+	handler = NewDedupHandler(handler, ParseDuplicateKeyPolicy(cfg.DuplicateKeys))
+	if len(cfg.RedactKeys) > 0 {
+		rules := make([]RedactRule, 0, len(cfg.RedactKeys))
+		for _, key := range cfg.RedactKeys {
+			rules = append(rules, parseRedactKey(key))
+		}
+		handler = NewRedactHandler(handler, rules...)
+	}
+	if attrs := cfg.fieldAttrs(); len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+	l := NewLogger(handler)
+	l.rotators = rotators
+	l.closers = closers
+	l.level, _ = handlerOpts.Level.(*LevelVar)
+	l.goid = cfg.GoroutineID
+	return l, errors.Join(errs...)
+}
+
+// fieldAttrs returns the attrs of Fields, AddHostname and AddPID, Fields sorted by key.
+func (c *Config) fieldAttrs() []Attr {
+	keys := make([]string, 0, len(c.Fields))
+	for key := range c.Fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]Attr, 0, len(keys)+2)
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, c.Fields[key]))
+	}
+	if c.AddHostname {
+		if hostname, err := os.Hostname(); err == nil {
+			attrs = append(attrs, slog.String(HostnameKey, hostname))
+		}
+	}
+	if c.AddPID {
+		attrs = append(attrs, slog.Int(PIDKey, os.Getpid()))
+	}
+	return attrs
+}
+
+// newSyncHandler wraps handler with the SyncEveryWrite or SyncLevel
+// of the Config. They are reported to the func set by SetErrorHandler
+// and ignored if there is no file to sync.
+func (c *Config) newSyncHandler(handler Handler, rotators []Rotator) Handler {
+	var syncers []Syncer
+	for _, r := range rotators {
+		if s, ok := r.(Syncer); ok {
+			syncers = append(syncers, s)
+		}
+	}
+	if len(syncers) == 0 {
+		reportError(errors.New("syncEveryWrite and syncLevel require a filename, ignored"))
+		return handler
+	}
+	level := Level(math.MinInt)
+	if !c.SyncEveryWrite {
+		level = c.SyncLevel.Level()
+	}
+	return newSyncHandler(handler, level, syncers)
+}
+
+// Apply updates the Logger l created by New from the changed Config c,
+// e.g. when the config file is reloaded, without losing the attrs and
+// groups of the Loggers derived from l. Only Level is hot-reloadable,
+// the other fields like Format and Filename require a new Logger.
+// It fails if l has no LevelVar, e.g. if New was passed another Leveler,
+// or if Level is unknown, leaving the level of l unchanged.
+func (c *Config) Apply(l *Logger) error {
+	if l.level == nil {
+		return errors.New("logger has no LevelVar, create it with New")
+	}
+	level := LevelInfo
+	if c.Level != "" {
+		var err error
+		if level, err = ParseLevelStrict(c.Level); err != nil {
+			return err
+		}
+	}
+	l.level.Set(level)
+	return nil
+}
+
+// newHandler creates the handler of the Format writing to writer.
+func (c *Config) newHandler(writer io.Writer, handlerOpts *HandlerOptions) Handler {
+	switch strings.ToLower(c.Format) {
+	case "json":
+		return c.wrapSlogHandler(NewJSONHandler(writer, c.slogHandlerOptions(handlerOpts), c.Indent))
+	case "json-pretty":
+		disableColor := c.DisableColor || !isTerminal(writer)
+		return c.wrapSlogHandler(NewPrettyJSONHandler(writer, c.slogHandlerOptions(handlerOpts), disableColor))
+	case "text":
+		opts := c.slogHandlerOptions(handlerOpts)
+		opts.ReplaceAttr = levelNameReplaceAttr(opts.ReplaceAttr)
+		return c.wrapSlogHandler(slog.NewTextHandler(writer, opts))
+	case "proto":
+		return c.wrapSlogHandler(NewProtoHandler(writer, c.slogHandlerOptions(handlerOpts)))
+	case "cef":
+		device := CEFDevice{Vendor: c.CEFVendor, Product: c.CEFProduct, Version: c.CEFVersion}
+		return c.wrapSlogHandler(NewCEFHandler(writer, device, c.slogHandlerOptions(handlerOpts)))
+	case "journald":
+		jh, err := NewJournaldHandler(handlerOpts)
+		if err != nil {
+			// journald isn't available, fall back to the default format
+			return NewLogHandler(writer, handlerOpts, c.DisableColor, c.logHandlerOptions()...)
 		}
+		return c.wrapSlogHandler(jh)
+	case "logfmt":
+		return NewLogfmtHandler(writer, handlerOpts, c.logHandlerOptions()...)
+	default:
+		return NewLogHandler(writer, handlerOpts, c.DisableColor, c.logHandlerOptions()...)
 	}
-	return NewLogger(handler)
+}
+
+// rotateInterval returns the duration of RotateInterval, zero if it isn't set.
+// An invalid interval is reported to the func set by SetErrorHandler.
+func (c *Config) rotateInterval() time.Duration {
+	d, err := c.parseRotateInterval()
+	if err != nil {
+		reportError(err)
+	}
+	return d
+}
+
+func (c *Config) parseRotateInterval() (time.Duration, error) {
+	switch strings.ToLower(c.RotateInterval) {
+	case "":
+		return 0, nil
+	case "daily":
+		return 24 * time.Hour, nil
+	case "hourly":
+		return time.Hour, nil
+	}
+	d, err := time.ParseDuration(c.RotateInterval)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid rotate interval: %q", c.RotateInterval)
+	}
+	return d, nil
+}
+
+// levelFile is a file receiving the records of at least level.
+type levelFile struct {
+	level    Level
+	filename string
+}
+
+// levelFiles returns the files of LevelFiles and ErrorFilename ordered by level.
+func (c *Config) levelFiles() []levelFile {
+	var files []levelFile
+	for ls, filename := range c.LevelFiles {
+		if filename != "" {
+			files = append(files, levelFile{level: ls.Level(), filename: filename})
+		}
+	}
+	if c.ErrorFilename != "" {
+		files = append(files, levelFile{level: LevelError, filename: c.ErrorFilename})
+	}
+	slices.SortFunc(files, func(a, b levelFile) int {
+		if a.level != b.level {
+			return cmp.Compare(a.level, b.level)
+		}
+		return strings.Compare(a.filename, b.filename)
+	})
+	return files
 }
 
 var defaultLogger atomic.Value
@@ -106,9 +537,19 @@ func SetDefault(l *Logger) {
 	defaultLogger.Store(l)
 }
 
+// SwapDefault makes l the default Logger like SetDefault, and returns
+// a func restoring the previous default Logger, e.g. for `defer restore()`
+// in tests.
+func SwapDefault(l *Logger) (restore func()) {
+	prev := defaultLogger.Swap(l)
+	return func() {
+		defaultLogger.Store(prev)
+	}
+}
+
 // With calls Logger.With on the default logger.
 func With(args ...any) *Logger {
-	return Default().With(args...)
+	return Default().with(args)
 }
 
 // Debug calls Logger.Debug on the default logger.
@@ -141,6 +582,11 @@ func InfoCtx(ctx context.Context, msg string, args ...any) {
 	Default().log(ctx, LevelInfo, msg, args...)
 }
 
+// InfoEvery calls Logger.InfoEvery on the default logger.
+func InfoEvery(n int, msg string, args ...any) {
+	Default().logEvery(emptyCtx, LevelInfo, n, msg, args...)
+}
+
 // Warn calls Logger.Warn on the default logger.
 func Warn(msg string, args ...any) {
 	Default().log(emptyCtx, LevelWarn, msg, args...)
@@ -171,6 +617,16 @@ func ErrorCtx(ctx context.Context, msg string, args ...any) {
 	Default().log(ctx, LevelError, msg, args...)
 }
 
+// ErrorErr calls Logger.ErrorErr on the default logger.
+func ErrorErr(err error, msg string, args ...any) {
+	Default().log(emptyCtx, LevelError, msg, appendError(args, err)...)
+}
+
+// ErrorErrCtx calls Logger.ErrorErrCtx on the default logger.
+func ErrorErrCtx(ctx context.Context, err error, msg string, args ...any) {
+	Default().log(ctx, LevelError, msg, appendError(args, err)...)
+}
+
 // Log calls Logger.Log on the default logger.
 func Log(level Level, msg string, args ...any) {
 	Default().log(emptyCtx, level, msg, args...)